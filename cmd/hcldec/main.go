@@ -7,6 +7,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/hashicorp/hcl2/ext/hcldecgen"
 	"github.com/hashicorp/hcl2/hcl"
 	"github.com/hashicorp/hcl2/hcldec"
 	"github.com/hashicorp/hcl2/hclparse"
@@ -29,6 +30,9 @@ var (
 	diagsFormat = flag.StringP("diags", "", "", "format any returned diagnostics in the given format; currently only \"json\" is accepted")
 	showVarRefs = flag.BoolP("var-refs", "", false, "rather than decoding input, produce a JSON description of the variables referenced by it")
 	withType    = flag.BoolP("with-type", "", false, "include an additional object level at the top describing the HCL-oriented type of the result value")
+	goSource    = flag.StringP("go-source", "", "", "rather than decoding input, produce Go source code for the given package name that constructs the spec")
+	docsFormat  = flag.StringP("docs", "", "", "rather than decoding input, produce reference documentation for the spec in the given format; either \"markdown\" or \"html\"")
+	jsonSchema  = flag.BoolP("json-schema", "", false, "rather than decoding input, produce a JSON Schema describing the JSON syntax of configuration accepted by the spec")
 	showVersion = flag.BoolP("version", "v", false, "show the version number and immediately exit")
 )
 
@@ -91,6 +95,29 @@ func realmain(args []string) error {
 
 	spec := specContent.RootSpec
 
+	if *goSource != "" {
+		src, err := hcldecgen.GoSource(spec, parser.Files(), *goSource, "Spec")
+		if err != nil {
+			return err
+		}
+		return writeOutput(src)
+	}
+
+	if *jsonSchema {
+		return writeOutput(hcldecgen.JSONSchema(spec))
+	}
+
+	switch *docsFormat {
+	case "":
+		// Not generating documentation
+	case "markdown":
+		return writeOutput(hcldecgen.Markdown(spec))
+	case "html":
+		return writeOutput(hcldecgen.HTML(spec))
+	default:
+		return fmt.Errorf("invalid documentation format %q: must be either \"markdown\" or \"html\"", *docsFormat)
+	}
+
 	ctx := &hcl.EvalContext{
 		Variables: map[string]cty.Value{},
 		Functions: map[string]function.Function{},
@@ -209,17 +236,21 @@ func realmain(args []string) error {
 	// out and reduce to only the non-null values.
 	out = stripJSONNullProperties(out)
 
+	return writeOutput(append(out, '\n'))
+}
+
+func writeOutput(out []byte) error {
 	target := os.Stdout
 	if *outputFile != "" {
+		var err error
 		target, err = os.OpenFile(*outputFile, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, os.ModePerm)
 		if err != nil {
 			return fmt.Errorf("can't open %s for writing: %s", *outputFile, err)
 		}
 	}
 
-	fmt.Fprintf(target, "%s\n", out)
-
-	return nil
+	_, err := target.Write(out)
+	return err
 }
 
 func usage() {
@@ -317,17 +348,7 @@ func showVarRefsJSON(vars []hcl.Traversal, ctx *hcl.EvalContext) error {
 		return fmt.Errorf("failed to marshal variable references as JSON: %s", err)
 	}
 
-	target := os.Stdout
-	if *outputFile != "" {
-		target, err = os.OpenFile(*outputFile, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, os.ModePerm)
-		if err != nil {
-			return fmt.Errorf("can't open %s for writing: %s", *outputFile, err)
-		}
-	}
-
-	fmt.Fprintf(target, "%s\n", out)
-
-	return nil
+	return writeOutput(append(out, '\n'))
 }
 
 func stripJSONNullProperties(src []byte) []byte {
@@ -138,6 +138,9 @@ func decodeSpecBlock(block *hcl.Block) (hcldec.Spec, hcl.Diagnostics) {
 	case "block_attrs":
 		return decodeBlockAttrsSpec(block.Body, impliedName)
 
+	case "block_label":
+		return decodeBlockLabelSpec(block.Body, impliedName)
+
 	case "default":
 		return decodeDefaultSpec(block.Body)
 
@@ -147,6 +150,15 @@ func decodeSpecBlock(block *hcl.Block) (hcldec.Spec, hcl.Diagnostics) {
 	case "literal":
 		return decodeLiteralSpec(block.Body)
 
+	case "validate":
+		return decodeValidateSpec(block.Body)
+
+	case "deprecated":
+		return decodeDeprecatedSpec(block.Body)
+
+	case "one_of":
+		return decodeOneOfSpec(block.Body)
+
 	default:
 		// Should never happen, because the above cases should be exhaustive
 		// for our schema.
@@ -189,9 +201,10 @@ func decodeArraySpec(body hcl.Body) (hcldec.Spec, hcl.Diagnostics) {
 
 func decodeAttrSpec(body hcl.Body, impliedName string) (hcldec.Spec, hcl.Diagnostics) {
 	type content struct {
-		Name     *string        `hcl:"name"`
-		Type     hcl.Expression `hcl:"type"`
-		Required *bool          `hcl:"required"`
+		Name        *string        `hcl:"name"`
+		Type        hcl.Expression `hcl:"type"`
+		Required    *bool          `hcl:"required"`
+		Description *string        `hcl:"description"`
 	}
 
 	var args content
@@ -207,6 +220,9 @@ func decodeAttrSpec(body hcl.Body, impliedName string) (hcldec.Spec, hcl.Diagnos
 	if args.Required != nil {
 		spec.Required = *args.Required
 	}
+	if args.Description != nil {
+		spec.Description = *args.Description
+	}
 	if args.Name != nil {
 		spec.Name = *args.Name
 	}
@@ -230,9 +246,10 @@ func decodeAttrSpec(body hcl.Body, impliedName string) (hcldec.Spec, hcl.Diagnos
 
 func decodeBlockSpec(body hcl.Body, impliedName string) (hcldec.Spec, hcl.Diagnostics) {
 	type content struct {
-		TypeName *string  `hcl:"block_type"`
-		Required *bool    `hcl:"required"`
-		Nested   hcl.Body `hcl:",remain"`
+		TypeName    *string  `hcl:"block_type"`
+		Required    *bool    `hcl:"required"`
+		Description *string  `hcl:"description"`
+		Nested      hcl.Body `hcl:",remain"`
 	}
 
 	var args content
@@ -248,6 +265,9 @@ func decodeBlockSpec(body hcl.Body, impliedName string) (hcldec.Spec, hcl.Diagno
 	if args.Required != nil {
 		spec.Required = *args.Required
 	}
+	if args.Description != nil {
+		spec.Description = *args.Description
+	}
 	if args.TypeName != nil {
 		spec.TypeName = *args.TypeName
 	}
@@ -261,10 +281,11 @@ func decodeBlockSpec(body hcl.Body, impliedName string) (hcldec.Spec, hcl.Diagno
 
 func decodeBlockListSpec(body hcl.Body, impliedName string) (hcldec.Spec, hcl.Diagnostics) {
 	type content struct {
-		TypeName *string  `hcl:"block_type"`
-		MinItems *int     `hcl:"min_items"`
-		MaxItems *int     `hcl:"max_items"`
-		Nested   hcl.Body `hcl:",remain"`
+		TypeName    *string  `hcl:"block_type"`
+		MinItems    *int     `hcl:"min_items"`
+		MaxItems    *int     `hcl:"max_items"`
+		Description *string  `hcl:"description"`
+		Nested      hcl.Body `hcl:",remain"`
 	}
 
 	var args content
@@ -283,6 +304,9 @@ func decodeBlockListSpec(body hcl.Body, impliedName string) (hcldec.Spec, hcl.Di
 	if args.MaxItems != nil {
 		spec.MaxItems = *args.MaxItems
 	}
+	if args.Description != nil {
+		spec.Description = *args.Description
+	}
 	if args.TypeName != nil {
 		spec.TypeName = *args.TypeName
 	}
@@ -306,10 +330,11 @@ func decodeBlockListSpec(body hcl.Body, impliedName string) (hcldec.Spec, hcl.Di
 
 func decodeBlockSetSpec(body hcl.Body, impliedName string) (hcldec.Spec, hcl.Diagnostics) {
 	type content struct {
-		TypeName *string  `hcl:"block_type"`
-		MinItems *int     `hcl:"min_items"`
-		MaxItems *int     `hcl:"max_items"`
-		Nested   hcl.Body `hcl:",remain"`
+		TypeName    *string  `hcl:"block_type"`
+		MinItems    *int     `hcl:"min_items"`
+		MaxItems    *int     `hcl:"max_items"`
+		Description *string  `hcl:"description"`
+		Nested      hcl.Body `hcl:",remain"`
 	}
 
 	var args content
@@ -328,6 +353,9 @@ func decodeBlockSetSpec(body hcl.Body, impliedName string) (hcldec.Spec, hcl.Dia
 	if args.MaxItems != nil {
 		spec.MaxItems = *args.MaxItems
 	}
+	if args.Description != nil {
+		spec.Description = *args.Description
+	}
 	if args.TypeName != nil {
 		spec.TypeName = *args.TypeName
 	}
@@ -351,9 +379,10 @@ func decodeBlockSetSpec(body hcl.Body, impliedName string) (hcldec.Spec, hcl.Dia
 
 func decodeBlockMapSpec(body hcl.Body, impliedName string) (hcldec.Spec, hcl.Diagnostics) {
 	type content struct {
-		TypeName *string  `hcl:"block_type"`
-		Labels   []string `hcl:"labels"`
-		Nested   hcl.Body `hcl:",remain"`
+		TypeName    *string  `hcl:"block_type"`
+		Labels      []string `hcl:"labels"`
+		Description *string  `hcl:"description"`
+		Nested      hcl.Body `hcl:",remain"`
 	}
 
 	var args content
@@ -366,6 +395,9 @@ func decodeBlockMapSpec(body hcl.Body, impliedName string) (hcldec.Spec, hcl.Dia
 		TypeName: impliedName,
 	}
 
+	if args.Description != nil {
+		spec.Description = *args.Description
+	}
 	if args.TypeName != nil {
 		spec.TypeName = *args.TypeName
 	}
@@ -446,6 +478,7 @@ func decodeBlockAttrsSpec(body hcl.Body, impliedName string) (hcldec.Spec, hcl.D
 		TypeName    *string        `hcl:"block_type"`
 		ElementType hcl.Expression `hcl:"element_type"`
 		Required    *bool          `hcl:"required"`
+		Description *string        `hcl:"description"`
 	}
 
 	var args content
@@ -461,6 +494,9 @@ func decodeBlockAttrsSpec(body hcl.Body, impliedName string) (hcldec.Spec, hcl.D
 	if args.Required != nil {
 		spec.Required = *args.Required
 	}
+	if args.Description != nil {
+		spec.Description = *args.Description
+	}
 	if args.TypeName != nil {
 		spec.TypeName = *args.TypeName
 	}
@@ -482,6 +518,40 @@ func decodeBlockAttrsSpec(body hcl.Body, impliedName string) (hcldec.Spec, hcl.D
 	return spec, diags
 }
 
+func decodeBlockLabelSpec(body hcl.Body, impliedName string) (hcldec.Spec, hcl.Diagnostics) {
+	type content struct {
+		Index int     `hcl:"index"`
+		Name  *string `hcl:"name"`
+	}
+
+	var args content
+	diags := gohcl.DecodeBody(body, nil, &args)
+	if diags.HasErrors() {
+		return errSpec, diags
+	}
+
+	spec := &hcldec.BlockLabelSpec{
+		Index: args.Index,
+		Name:  impliedName,
+	}
+
+	if args.Name != nil {
+		spec.Name = *args.Name
+	}
+
+	if spec.Name == "" {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Missing name in block_label spec",
+			Detail:   "The name attribute is required, to specify the label name that is expected in an input HCL file.",
+			Subject:  body.MissingItemRange().Ptr(),
+		})
+		return errSpec, diags
+	}
+
+	return spec, diags
+}
+
 func decodeLiteralSpec(body hcl.Body) (hcldec.Spec, hcl.Diagnostics) {
 	type content struct {
 		Value cty.Value `hcl:"value"`
@@ -593,6 +663,148 @@ func decodeTransformSpec(body hcl.Body) (hcldec.Spec, hcl.Diagnostics) {
 	return spec, diags
 }
 
+func decodeValidateSpec(body hcl.Body) (hcldec.Spec, hcl.Diagnostics) {
+	type condition struct {
+		Expr         hcl.Expression `hcl:"expr"`
+		ErrorMessage *string        `hcl:"error_message"`
+	}
+	type content struct {
+		Conditions []condition `hcl:"condition,block"`
+		Nested     hcl.Body    `hcl:",remain"`
+	}
+
+	var args content
+	diags := gohcl.DecodeBody(body, nil, &args)
+	if diags.HasErrors() {
+		return errSpec, diags
+	}
+
+	spec := &hcldec.ValidateSpec{}
+	for _, cond := range args.Conditions {
+		vc := hcldec.ValidateCondition{
+			Expr:    cond.Expr,
+			EvalCtx: specCtx,
+		}
+		if cond.ErrorMessage != nil {
+			vc.ErrorMessage = *cond.ErrorMessage
+		}
+		spec.Conditions = append(spec.Conditions, vc)
+	}
+
+	if len(spec.Conditions) == 0 {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  "Useless validate block",
+			Detail:   "A validate block with no condition blocks is equivalent to using its nested spec alone.",
+			Subject:  body.MissingItemRange().Ptr(),
+		})
+	}
+
+	nestedContent, nestedDiags := args.Nested.Content(specSchemaUnlabelled)
+	diags = append(diags, nestedDiags...)
+
+	if len(nestedContent.Blocks) != 1 {
+		if nestedDiags.HasErrors() {
+			// If we already have errors then they probably explain
+			// why we have the wrong number of blocks, so we'll skip our
+			// additional error message added below.
+			return errSpec, diags
+		}
+
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid validate spec",
+			Detail:   "A validate spec block must have exactly one nested spec block.",
+			Subject:  body.MissingItemRange().Ptr(),
+		})
+		return errSpec, diags
+	}
+
+	nestedSpec, nestedDiags := decodeSpecBlock(nestedContent.Blocks[0])
+	diags = append(diags, nestedDiags...)
+	spec.Wrapped = nestedSpec
+
+	return spec, diags
+}
+
+func decodeDeprecatedSpec(body hcl.Body) (hcldec.Spec, hcl.Diagnostics) {
+	type content struct {
+		OldName *string  `hcl:"old_name"`
+		Message *string  `hcl:"message"`
+		Nested  hcl.Body `hcl:",remain"`
+	}
+
+	var args content
+	diags := gohcl.DecodeBody(body, nil, &args)
+	if diags.HasErrors() {
+		return errSpec, diags
+	}
+
+	spec := &hcldec.DeprecatedSpec{}
+	if args.OldName != nil {
+		spec.OldName = *args.OldName
+	}
+	if args.Message != nil {
+		spec.Message = *args.Message
+	}
+
+	nestedContent, nestedDiags := args.Nested.Content(specSchemaUnlabelled)
+	diags = append(diags, nestedDiags...)
+
+	if len(nestedContent.Blocks) != 1 {
+		if nestedDiags.HasErrors() {
+			// If we already have errors then they probably explain
+			// why we have the wrong number of blocks, so we'll skip our
+			// additional error message added below.
+			return errSpec, diags
+		}
+
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid deprecated spec",
+			Detail:   "A deprecated spec block must have exactly one nested spec block.",
+			Subject:  body.MissingItemRange().Ptr(),
+		})
+		return errSpec, diags
+	}
+
+	nestedSpec, nestedDiags := decodeSpecBlock(nestedContent.Blocks[0])
+	diags = append(diags, nestedDiags...)
+	spec.Wrapped = nestedSpec
+
+	return spec, diags
+}
+
+func decodeOneOfSpec(body hcl.Body) (hcldec.Spec, hcl.Diagnostics) {
+	type content struct {
+		Required *bool    `hcl:"required"`
+		Nested   hcl.Body `hcl:",remain"`
+	}
+
+	var args content
+	diags := gohcl.DecodeBody(body, nil, &args)
+	if diags.HasErrors() {
+		return errSpec, diags
+	}
+
+	nestedContent, nestedDiags := args.Nested.Content(specSchemaLabelled)
+	diags = append(diags, nestedDiags...)
+
+	spec := &hcldec.OneOfSpec{
+		Specs: make(hcldec.ObjectSpec),
+	}
+	if args.Required != nil {
+		spec.Required = *args.Required
+	}
+	for _, block := range nestedContent.Blocks {
+		propSpec, propDiags := decodeSpecBlock(block)
+		diags = append(diags, propDiags...)
+		spec.Specs[block.Labels[0]] = propSpec
+	}
+
+	return spec, diags
+}
+
 var errSpec = &hcldec.LiteralSpec{
 	Value: cty.NullVal(cty.DynamicPseudoType),
 }
@@ -609,9 +821,14 @@ var specBlockTypes = []string{
 	"block_list",
 	"block_map",
 	"block_set",
+	"block_attrs",
+	"block_label",
 
 	"default",
 	"transform",
+	"validate",
+	"deprecated",
+	"one_of",
 }
 
 var specSchemaUnlabelled *hcl.BodySchema
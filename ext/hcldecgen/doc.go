@@ -0,0 +1,25 @@
+// Package hcldecgen generates alternative representations of hcldec specs,
+// to allow a single schema definition to be shared between applications
+// written in different languages or using different HCL APIs.
+//
+// SpecFile produces a spec file in the format accepted by the "hcldec"
+// command line tool, while GoSource produces Go source code that constructs
+// an equivalent spec using the types in package hcldec. Combined with
+// gohcl.ImpliedSpec, these allow a schema defined using gohcl struct tags to
+// be exported as a spec file, and a spec file to be used from Go code without
+// parsing it at runtime.
+//
+// Markdown and HTML produce reference documentation for the configuration
+// structure described by a spec, including the descriptions given in the
+// Description fields of the attribute and block specs.
+//
+// JSONSchema produces a JSON Schema document describing the JSON syntax of
+// configuration accepted by a spec, for use by editors and other tools that
+// validate JSON files.
+//
+// Not all specs can be represented in these formats. Specs that contain
+// Go functions, such as TransformFuncSpec and CustomSpec, produce an error
+// from the functions that return one and are otherwise omitted. The
+// expressions within TransformExprSpec and ValidateSpec are written by
+// SpecFile and GoSource using their source text, which the caller provides.
+package hcldecgen
@@ -0,0 +1,325 @@
+package hcldecgen
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/hashicorp/hcl2/ext/typeexpr"
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/hashicorp/hcl2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Markdown returns reference documentation in Markdown format for the
+// configuration structure described by the given spec.
+//
+// The documentation lists each of the arguments and nested blocks expected
+// in a body, along with their types, whether they are required, their
+// defaults and their descriptions, and then recursively documents the
+// bodies of the nested blocks. Specs that don't correspond to body content,
+// such as LiteralSpec, are not included.
+func Markdown(spec hcldec.Spec) []byte {
+	var buf bytes.Buffer
+	writeMarkdownBody(&buf, docBody(spec), nil, 2)
+	return buf.Bytes()
+}
+
+// HTML returns reference documentation as an HTML fragment for the
+// configuration structure described by the given spec, with the same content
+// as would be produced by Markdown.
+func HTML(spec hcldec.Spec) []byte {
+	var buf bytes.Buffer
+	writeHTMLBody(&buf, docBody(spec), nil, 2)
+	return buf.Bytes()
+}
+
+// docItem describes a single attribute or block type within a body, for
+// documentation purposes.
+type docItem struct {
+	Name        string
+	IsBlock     bool
+	Type        string
+	Required    bool
+	Default     string
+	Deprecated  string
+	Description string
+
+	// The remaining fields are used only for blocks.
+	Nesting  string
+	Labels   []string
+	MinItems int
+	MaxItems int
+	Body     []*docItem
+}
+
+// docBody returns the items within a body decoded by the given spec, with
+// the arguments before the blocks.
+func docBody(spec hcldec.Spec) []*docItem {
+	var attrs, blocks []*docItem
+	var visit func(spec hcldec.Spec, dflt string)
+	visit = func(spec hcldec.Spec, dflt string) {
+		switch s := spec.(type) {
+		case *hcldec.DefaultSpec:
+			if lit, ok := s.Default.(*hcldec.LiteralSpec); ok && lit.Value.IsWhollyKnown() && !lit.Value.IsNull() {
+				dflt = string(hclwrite.TokensForValue(lit.Value).Bytes())
+			}
+			visit(s.Primary, dflt)
+			return
+		case *hcldec.DeprecatedSpec:
+			attrsBefore, blocksBefore := len(attrs), len(blocks)
+			visit(s.Wrapped, dflt)
+			msg := s.Message
+			if s.OldName != "" {
+				msg = strings.TrimSpace(fmt.Sprintf("Previously named `%s`. %s", s.OldName, msg))
+			}
+			if msg == "" {
+				msg = "This is deprecated."
+			}
+			for _, item := range attrs[attrsBefore:] {
+				item.Deprecated = msg
+			}
+			for _, item := range blocks[blocksBefore:] {
+				item.Deprecated = msg
+			}
+			return
+		}
+
+		if item := docItemForSpec(spec); item != nil {
+			item.Default = dflt
+			if item.IsBlock {
+				blocks = append(blocks, item)
+			} else {
+				attrs = append(attrs, item)
+			}
+		}
+		for _, child := range hcldec.SameBodyChildren(spec) {
+			visit(child, "")
+		}
+	}
+	visit(spec, "")
+	return append(attrs, blocks...)
+}
+
+// docItemForSpec returns the item for the given spec, or nil if it doesn't
+// describe an attribute or block type of its own.
+func docItemForSpec(spec hcldec.Spec) *docItem {
+	switch s := spec.(type) {
+	case *hcldec.AttrSpec:
+		return &docItem{
+			Name:        s.Name,
+			Type:        typeString(s.Type),
+			Required:    s.Required,
+			Description: s.Description,
+		}
+	case *hcldec.BlockSpec:
+		return blockDocItem(s, s.TypeName, "single", s.Required, 0, 0, s.Description)
+	case *hcldec.BlockListSpec:
+		return blockDocItem(s, s.TypeName, "list", s.MinItems > 0, s.MinItems, s.MaxItems, s.Description)
+	case *hcldec.BlockSetSpec:
+		return blockDocItem(s, s.TypeName, "set", s.MinItems > 0, s.MinItems, s.MaxItems, s.Description)
+	case *hcldec.BlockMapSpec:
+		return blockDocItem(s, s.TypeName, "map", false, 0, 0, s.Description)
+	case *hcldec.BlockAttrsSpec:
+		return &docItem{
+			Name:        s.TypeName,
+			IsBlock:     true,
+			Type:        typeString(s.ElementType),
+			Required:    s.Required,
+			Description: s.Description,
+			Nesting:     "attrs",
+		}
+	default:
+		return nil
+	}
+}
+
+func blockDocItem(spec hcldec.Spec, typeName, nesting string, required bool, min, max int, desc string) *docItem {
+	item := &docItem{
+		Name:        typeName,
+		IsBlock:     true,
+		Required:    required,
+		Description: desc,
+		Nesting:     nesting,
+		MinItems:    min,
+		MaxItems:    max,
+	}
+	for _, blockS := range hcldec.BlockHeaderSchemata(spec) {
+		if blockS.Type == typeName {
+			item.Labels = blockS.LabelNames
+		}
+	}
+	if nested := hcldec.NestedSpec(spec); nested != nil {
+		item.Body = docBody(nested)
+	}
+	return item
+}
+
+func typeString(ty cty.Type) string {
+	if ty.IsCapsuleType() {
+		return ty.FriendlyName()
+	}
+	return typeexpr.TypeString(ty)
+}
+
+// summary returns the short description of an item that follows its name.
+func (item *docItem) summary() string {
+	var parts []string
+	if item.Required {
+		parts = append(parts, "Required")
+	} else {
+		parts = append(parts, "Optional")
+	}
+	if !item.IsBlock {
+		parts = append(parts, item.Type)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// notes returns the sentences describing an item after its description.
+func (item *docItem) notes() []string {
+	var ret []string
+	if item.Default != "" {
+		ret = append(ret, fmt.Sprintf("Defaults to `%s`.", item.Default))
+	}
+	if item.IsBlock {
+		if len(item.Labels) > 0 {
+			labels := make([]string, len(item.Labels))
+			for i, l := range item.Labels {
+				labels[i] = "`" + l + "`"
+			}
+			ret = append(ret, fmt.Sprintf("Labels: %s.", strings.Join(labels, ", ")))
+		}
+		switch item.Nesting {
+		case "single":
+			ret = append(ret, "At most one block of this type is allowed.")
+		case "list", "set":
+			ret = append(ret, itemCountNote(item.MinItems, item.MaxItems))
+		case "map":
+			ret = append(ret, "Any number of blocks of this type may be given, each with a unique set of labels.")
+		case "attrs":
+			ret = append(ret, fmt.Sprintf("At most one block of this type is allowed, containing arguments with any names and values of type `%s`.", item.Type))
+		}
+	}
+	if item.Deprecated != "" {
+		ret = append(ret, "**Deprecated:** "+item.Deprecated)
+	}
+	return ret
+}
+
+func itemCountNote(min, max int) string {
+	plural := func(n int) string {
+		if n == 1 {
+			return "1 block"
+		}
+		return fmt.Sprintf("%d blocks", n)
+	}
+	switch {
+	case min > 0 && max > 0 && min == max:
+		return fmt.Sprintf("Exactly %s of this type must be given.", plural(min))
+	case min > 0 && max > 0:
+		return fmt.Sprintf("Between %d and %d blocks of this type must be given.", min, max)
+	case min > 0:
+		return fmt.Sprintf("At least %s of this type must be given.", plural(min))
+	case max > 0:
+		return fmt.Sprintf("At most %s of this type may be given.", plural(max))
+	default:
+		return "Any number of blocks of this type may be given."
+	}
+}
+
+func writeMarkdownBody(buf *bytes.Buffer, items []*docItem, path []string, level int) {
+	var blocks []*docItem
+	if len(items) == 0 {
+		buf.WriteString("This block has no arguments.\n\n")
+		return
+	}
+	for _, item := range items {
+		fmt.Fprintf(buf, "* `%s` (%s)", item.Name, item.summary())
+		text := item.notes()
+		if item.Description != "" {
+			text = append([]string{item.Description}, text...)
+		}
+		if len(text) > 0 {
+			fmt.Fprintf(buf, " - %s", strings.Join(text, " "))
+		}
+		buf.WriteString("\n")
+		if item.IsBlock && item.Nesting != "attrs" {
+			blocks = append(blocks, item)
+		}
+	}
+	buf.WriteString("\n")
+
+	for _, item := range blocks {
+		itemPath := append(append([]string(nil), path...), item.Name)
+		fmt.Fprintf(buf, "%s `%s` block\n\n", strings.Repeat("#", headingLevel(level)), strings.Join(itemPath, "."))
+		writeMarkdownBody(buf, item.Body, itemPath, level+1)
+	}
+}
+
+func writeHTMLBody(buf *bytes.Buffer, items []*docItem, path []string, level int) {
+	var blocks []*docItem
+	if len(items) == 0 {
+		buf.WriteString("<p>This block has no arguments.</p>\n")
+		return
+	}
+	buf.WriteString("<ul>\n")
+	for _, item := range items {
+		fmt.Fprintf(buf, "<li><code>%s</code> (%s)", html.EscapeString(item.Name), html.EscapeString(item.summary()))
+		text := item.notes()
+		for i, note := range text {
+			text[i] = markdownCodeToHTML(note)
+		}
+		if item.Description != "" {
+			text = append([]string{html.EscapeString(item.Description)}, text...)
+		}
+		if len(text) > 0 {
+			fmt.Fprintf(buf, " - %s", strings.Join(text, " "))
+		}
+		buf.WriteString("</li>\n")
+		if item.IsBlock && item.Nesting != "attrs" {
+			blocks = append(blocks, item)
+		}
+	}
+	buf.WriteString("</ul>\n")
+
+	for _, item := range blocks {
+		itemPath := append(append([]string(nil), path...), item.Name)
+		lvl := headingLevel(level)
+		fmt.Fprintf(buf, "<h%d><code>%s</code> block</h%d>\n", lvl, html.EscapeString(strings.Join(itemPath, ".")), lvl)
+		writeHTMLBody(buf, item.Body, itemPath, level+1)
+	}
+}
+
+// markdownCodeToHTML escapes the given text for HTML and converts the
+// Markdown code spans and strong emphasis used in notes into HTML elements.
+func markdownCodeToHTML(s string) string {
+	s = html.EscapeString(s)
+	s = strings.Replace(s, "**Deprecated:**", "<strong>Deprecated:</strong>", 1)
+	var buf strings.Builder
+	open := false
+	for _, r := range s {
+		if r != '`' {
+			buf.WriteRune(r)
+			continue
+		}
+		if open {
+			buf.WriteString("</code>")
+		} else {
+			buf.WriteString("<code>")
+		}
+		open = !open
+	}
+	if open {
+		buf.WriteString("</code>")
+	}
+	return buf.String()
+}
+
+func headingLevel(level int) int {
+	if level > 6 {
+		return 6
+	}
+	return level
+}
@@ -0,0 +1,482 @@
+package hcldecgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// GoSource returns the source code of a Go file in the given package that
+// declares a variable of the given name whose value is the given spec.
+//
+// The expressions of any TransformExprSpec or ValidateSpec are written as
+// calls to a function declared in the same file, which parses their source
+// text. The source text is found in the given files, keyed by filename as
+// returned from hclparse.Parser.Files, which may be nil if the spec has no
+// such expressions. The generated specs have no evaluation contexts, so the
+// calling program must set them if the expressions use any variables or
+// functions.
+//
+// An error is returned if the spec contains any specs that cannot be
+// represented as Go source code, or expressions whose source text is not
+// available.
+func GoSource(spec hcldec.Spec, files map[string]*hcl.File, pkgName, varName string) ([]byte, error) {
+	exprs := &goExprs{
+		files:    files,
+		funcName: "parse" + strings.ToUpper(varName[:1]) + varName[1:] + "Expr",
+	}
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "var %s hcldec.Spec = ", varName)
+	if err := writeGoSpec(&body, spec, exprs); err != nil {
+		return nil, err
+	}
+	body.WriteString("\n")
+	if exprs.used {
+		fmt.Fprintf(&body, "\nfunc %s(src string) hcl.Expression {\n", exprs.funcName)
+		fmt.Fprintf(&body, "expr, diags := hclsyntax.ParseExpression([]byte(src), %s, hcl.Pos{Line: 1, Column: 1})\n", strconv.Quote(varName))
+		body.WriteString("if diags.HasErrors() {\npanic(diags.Error())\n}\n")
+		body.WriteString("return expr\n}\n")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "package %s\n\n", pkgName)
+	buf.WriteString("import (\n")
+	if exprs.used {
+		buf.WriteString("\t\"github.com/hashicorp/hcl2/hcl\"\n")
+		buf.WriteString("\t\"github.com/hashicorp/hcl2/hcl/hclsyntax\"\n")
+	}
+	buf.WriteString("\t\"github.com/hashicorp/hcl2/hcldec\"\n")
+	// The cty import is unused if no types or values appear in the spec,
+	// which would make the result invalid.
+	if usesCty(spec) {
+		buf.WriteString("\t\"github.com/zclconf/go-cty/cty\"\n")
+	}
+	buf.WriteString(")\n\n")
+	buf.Write(body.Bytes())
+
+	return format.Source(buf.Bytes())
+}
+
+// goExprs writes expressions as calls to a function that parses their
+// source text, recording whether any were written.
+type goExprs struct {
+	files    map[string]*hcl.File
+	funcName string
+	used     bool
+}
+
+func (e *goExprs) write(buf *bytes.Buffer, expr hcl.Expression) error {
+	src, err := exprSource(expr, e.files)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(buf, "%s(%s)", e.funcName, strconv.Quote(src))
+	e.used = true
+	return nil
+}
+
+// usesCty returns true if the Go source code for the given spec refers to
+// package cty, because it includes types or values.
+func usesCty(spec hcldec.Spec) bool {
+	switch s := spec.(type) {
+	case hcldec.ObjectSpec:
+		for _, child := range s {
+			if usesCty(child) {
+				return true
+			}
+		}
+	case hcldec.TupleSpec:
+		for _, child := range s {
+			if usesCty(child) {
+				return true
+			}
+		}
+	case *hcldec.AttrSpec, *hcldec.BlockAttrsSpec, *hcldec.LiteralSpec:
+		return true
+	case *hcldec.BlockSpec:
+		return s.Nested != nil && usesCty(s.Nested)
+	case *hcldec.BlockListSpec:
+		return s.Nested != nil && usesCty(s.Nested)
+	case *hcldec.BlockSetSpec:
+		return s.Nested != nil && usesCty(s.Nested)
+	case *hcldec.BlockMapSpec:
+		return s.Nested != nil && usesCty(s.Nested)
+	case *hcldec.DefaultSpec:
+		return usesCty(s.Primary) || usesCty(s.Default)
+	case *hcldec.TransformExprSpec:
+		return usesCty(s.Wrapped)
+	case *hcldec.ValidateSpec:
+		return usesCty(s.Wrapped)
+	case *hcldec.DeprecatedSpec:
+		return usesCty(s.Wrapped)
+	case *hcldec.OneOfSpec:
+		return usesCty(s.Specs)
+	}
+	return false
+}
+
+func writeGoSpec(buf *bytes.Buffer, spec hcldec.Spec, exprs *goExprs) error {
+	switch s := spec.(type) {
+
+	case hcldec.ObjectSpec:
+		buf.WriteString("hcldec.ObjectSpec{\n")
+		for _, k := range sortedKeys(s) {
+			fmt.Fprintf(buf, "%s: ", strconv.Quote(k))
+			if err := writeGoSpec(buf, s[k], exprs); err != nil {
+				return err
+			}
+			buf.WriteString(",\n")
+		}
+		buf.WriteString("}")
+
+	case hcldec.TupleSpec:
+		buf.WriteString("hcldec.TupleSpec{\n")
+		for _, elem := range s {
+			if err := writeGoSpec(buf, elem, exprs); err != nil {
+				return err
+			}
+			buf.WriteString(",\n")
+		}
+		buf.WriteString("}")
+
+	case *hcldec.AttrSpec:
+		buf.WriteString("&hcldec.AttrSpec{\n")
+		fmt.Fprintf(buf, "Name: %s,\n", strconv.Quote(s.Name))
+		fmt.Fprintf(buf, "Type: %s,\n", goType(s.Type))
+		if s.Required {
+			buf.WriteString("Required: true,\n")
+		}
+		writeGoDescription(buf, s.Description)
+		buf.WriteString("}")
+
+	case *hcldec.BlockSpec:
+		buf.WriteString("&hcldec.BlockSpec{\n")
+		fmt.Fprintf(buf, "TypeName: %s,\n", strconv.Quote(s.TypeName))
+		if err := writeGoNested(buf, s.Nested, exprs); err != nil {
+			return err
+		}
+		if s.Required {
+			buf.WriteString("Required: true,\n")
+		}
+		writeGoDescription(buf, s.Description)
+		buf.WriteString("}")
+
+	case *hcldec.BlockListSpec:
+		buf.WriteString("&hcldec.BlockListSpec{\n")
+		fmt.Fprintf(buf, "TypeName: %s,\n", strconv.Quote(s.TypeName))
+		if err := writeGoNested(buf, s.Nested, exprs); err != nil {
+			return err
+		}
+		writeGoItems(buf, s.MinItems, s.MaxItems)
+		writeGoDescription(buf, s.Description)
+		buf.WriteString("}")
+
+	case *hcldec.BlockSetSpec:
+		buf.WriteString("&hcldec.BlockSetSpec{\n")
+		fmt.Fprintf(buf, "TypeName: %s,\n", strconv.Quote(s.TypeName))
+		if err := writeGoNested(buf, s.Nested, exprs); err != nil {
+			return err
+		}
+		writeGoItems(buf, s.MinItems, s.MaxItems)
+		writeGoDescription(buf, s.Description)
+		buf.WriteString("}")
+
+	case *hcldec.BlockMapSpec:
+		buf.WriteString("&hcldec.BlockMapSpec{\n")
+		fmt.Fprintf(buf, "TypeName: %s,\n", strconv.Quote(s.TypeName))
+		buf.WriteString("LabelNames: []string{")
+		for i, name := range s.LabelNames {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(strconv.Quote(name))
+		}
+		buf.WriteString("},\n")
+		if err := writeGoNested(buf, s.Nested, exprs); err != nil {
+			return err
+		}
+		writeGoDescription(buf, s.Description)
+		buf.WriteString("}")
+
+	case *hcldec.BlockAttrsSpec:
+		buf.WriteString("&hcldec.BlockAttrsSpec{\n")
+		fmt.Fprintf(buf, "TypeName: %s,\n", strconv.Quote(s.TypeName))
+		fmt.Fprintf(buf, "ElementType: %s,\n", goType(s.ElementType))
+		if s.Required {
+			buf.WriteString("Required: true,\n")
+		}
+		writeGoDescription(buf, s.Description)
+		buf.WriteString("}")
+
+	case *hcldec.BlockLabelSpec:
+		buf.WriteString("&hcldec.BlockLabelSpec{\n")
+		fmt.Fprintf(buf, "Index: %d,\n", s.Index)
+		fmt.Fprintf(buf, "Name: %s,\n", strconv.Quote(s.Name))
+		buf.WriteString("}")
+
+	case *hcldec.LiteralSpec:
+		val, err := goValue(s.Value)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "&hcldec.LiteralSpec{\nValue: %s,\n}", val)
+
+	case *hcldec.DefaultSpec:
+		buf.WriteString("&hcldec.DefaultSpec{\nPrimary: ")
+		if err := writeGoSpec(buf, s.Primary, exprs); err != nil {
+			return err
+		}
+		buf.WriteString(",\nDefault: ")
+		if err := writeGoSpec(buf, s.Default, exprs); err != nil {
+			return err
+		}
+		buf.WriteString(",\n}")
+
+	case *hcldec.TransformExprSpec:
+		buf.WriteString("&hcldec.TransformExprSpec{\nWrapped: ")
+		if err := writeGoSpec(buf, s.Wrapped, exprs); err != nil {
+			return err
+		}
+		buf.WriteString(",\nExpr: ")
+		if err := exprs.write(buf, s.Expr); err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, ",\nVarName: %s,\n}", strconv.Quote(s.VarName))
+
+	case *hcldec.ValidateSpec:
+		buf.WriteString("&hcldec.ValidateSpec{\nWrapped: ")
+		if err := writeGoSpec(buf, s.Wrapped, exprs); err != nil {
+			return err
+		}
+		buf.WriteString(",\n")
+		if len(s.Conditions) > 0 {
+			buf.WriteString("Conditions: []hcldec.ValidateCondition{\n")
+			for _, cond := range s.Conditions {
+				if cond.Func != nil {
+					return fmt.Errorf("cannot write a validation condition with a Go function as Go source code")
+				}
+				buf.WriteString("{\nExpr: ")
+				if err := exprs.write(buf, cond.Expr); err != nil {
+					return err
+				}
+				buf.WriteString(",\n")
+				if cond.ErrorMessage != "" {
+					fmt.Fprintf(buf, "ErrorMessage: %s,\n", strconv.Quote(cond.ErrorMessage))
+				}
+				buf.WriteString("},\n")
+			}
+			buf.WriteString("},\n")
+		}
+		buf.WriteString("}")
+
+	case *hcldec.DeprecatedSpec:
+		buf.WriteString("&hcldec.DeprecatedSpec{\nWrapped: ")
+		if err := writeGoSpec(buf, s.Wrapped, exprs); err != nil {
+			return err
+		}
+		buf.WriteString(",\n")
+		if s.OldName != "" {
+			fmt.Fprintf(buf, "OldName: %s,\n", strconv.Quote(s.OldName))
+		}
+		if s.Message != "" {
+			fmt.Fprintf(buf, "Message: %s,\n", strconv.Quote(s.Message))
+		}
+		buf.WriteString("}")
+
+	case *hcldec.OneOfSpec:
+		buf.WriteString("&hcldec.OneOfSpec{\nSpecs: ")
+		if err := writeGoSpec(buf, s.Specs, exprs); err != nil {
+			return err
+		}
+		buf.WriteString(",\n")
+		if s.Required {
+			buf.WriteString("Required: true,\n")
+		}
+		buf.WriteString("}")
+
+	default:
+		return fmt.Errorf("cannot write %T as Go source code", spec)
+	}
+
+	return nil
+}
+
+func writeGoNested(buf *bytes.Buffer, nested hcldec.Spec, exprs *goExprs) error {
+	if nested == nil {
+		return nil
+	}
+	buf.WriteString("Nested: ")
+	if err := writeGoSpec(buf, nested, exprs); err != nil {
+		return err
+	}
+	buf.WriteString(",\n")
+	return nil
+}
+
+func writeGoDescription(buf *bytes.Buffer, desc string) {
+	if desc != "" {
+		fmt.Fprintf(buf, "Description: %s,\n", strconv.Quote(desc))
+	}
+}
+
+func writeGoItems(buf *bytes.Buffer, min, max int) {
+	if min != 0 {
+		fmt.Fprintf(buf, "MinItems: %d,\n", min)
+	}
+	if max != 0 {
+		fmt.Fprintf(buf, "MaxItems: %d,\n", max)
+	}
+}
+
+// goType returns a Go expression that produces the given type.
+func goType(ty cty.Type) string {
+	switch {
+	case ty == cty.String:
+		return "cty.String"
+	case ty == cty.Number:
+		return "cty.Number"
+	case ty == cty.Bool:
+		return "cty.Bool"
+	case ty == cty.DynamicPseudoType:
+		return "cty.DynamicPseudoType"
+	case ty.IsListType():
+		return fmt.Sprintf("cty.List(%s)", goType(ty.ElementType()))
+	case ty.IsSetType():
+		return fmt.Sprintf("cty.Set(%s)", goType(ty.ElementType()))
+	case ty.IsMapType():
+		return fmt.Sprintf("cty.Map(%s)", goType(ty.ElementType()))
+	case ty.IsObjectType():
+		atys := ty.AttributeTypes()
+		if len(atys) == 0 {
+			return "cty.EmptyObject"
+		}
+		var buf bytes.Buffer
+		buf.WriteString("cty.Object(map[string]cty.Type{\n")
+		for _, name := range sortedTypeKeys(atys) {
+			fmt.Fprintf(&buf, "%s: %s,\n", strconv.Quote(name), goType(atys[name]))
+		}
+		buf.WriteString("})")
+		return buf.String()
+	case ty.IsTupleType():
+		etys := ty.TupleElementTypes()
+		if len(etys) == 0 {
+			return "cty.EmptyTuple"
+		}
+		var buf bytes.Buffer
+		buf.WriteString("cty.Tuple([]cty.Type{\n")
+		for _, ety := range etys {
+			fmt.Fprintf(&buf, "%s,\n", goType(ety))
+		}
+		buf.WriteString("})")
+		return buf.String()
+	default:
+		// Capsule types have no general Go representation, but they can't
+		// appear in a spec decoded from a spec file anyway.
+		return "cty.DynamicPseudoType"
+	}
+}
+
+// goValue returns a Go expression that produces the given value.
+func goValue(val cty.Value) (string, error) {
+	ty := val.Type()
+	switch {
+	case !val.IsKnown():
+		return "", fmt.Errorf("cannot write an unknown value as Go source code")
+	case val.IsNull():
+		return fmt.Sprintf("cty.NullVal(%s)", goType(ty)), nil
+	case ty == cty.String:
+		return fmt.Sprintf("cty.StringVal(%s)", strconv.Quote(val.AsString())), nil
+	case ty == cty.Bool:
+		if val.True() {
+			return "cty.True", nil
+		}
+		return "cty.False", nil
+	case ty == cty.Number:
+		bf := val.AsBigFloat()
+		if i, acc := bf.Int64(); acc == big.Exact {
+			return fmt.Sprintf("cty.NumberIntVal(%d)", i), nil
+		}
+		return fmt.Sprintf("cty.MustParseNumberVal(%s)", strconv.Quote(bf.Text('g', -1))), nil
+	case ty.IsListType() || ty.IsSetType() || ty.IsTupleType():
+		if val.LengthInt() == 0 {
+			switch {
+			case ty.IsListType():
+				return fmt.Sprintf("cty.ListValEmpty(%s)", goType(ty.ElementType())), nil
+			case ty.IsSetType():
+				return fmt.Sprintf("cty.SetValEmpty(%s)", goType(ty.ElementType())), nil
+			default:
+				return "cty.EmptyTupleVal", nil
+			}
+		}
+		fn := "cty.TupleVal"
+		switch {
+		case ty.IsListType():
+			fn = "cty.ListVal"
+		case ty.IsSetType():
+			fn = "cty.SetVal"
+		}
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "%s([]cty.Value{\n", fn)
+		for it := val.ElementIterator(); it.Next(); {
+			_, ev := it.Element()
+			src, err := goValue(ev)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&buf, "%s,\n", src)
+		}
+		buf.WriteString("})")
+		return buf.String(), nil
+	case ty.IsMapType() || ty.IsObjectType():
+		if val.LengthInt() == 0 {
+			if ty.IsMapType() {
+				return fmt.Sprintf("cty.MapValEmpty(%s)", goType(ty.ElementType())), nil
+			}
+			return "cty.EmptyObjectVal", nil
+		}
+		fn := "cty.ObjectVal"
+		if ty.IsMapType() {
+			fn = "cty.MapVal"
+		}
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "%s(map[string]cty.Value{\n", fn)
+		for it := val.ElementIterator(); it.Next(); {
+			k, ev := it.Element()
+			src, err := goValue(ev)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&buf, "%s: %s,\n", strconv.Quote(k.AsString()), src)
+		}
+		buf.WriteString("})")
+		return buf.String(), nil
+	default:
+		return "", fmt.Errorf("cannot write a value of type %s as Go source code", ty.FriendlyName())
+	}
+}
+
+func sortedKeys(spec hcldec.ObjectSpec) []string {
+	keys := make([]string, 0, len(spec))
+	for k := range spec {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedTypeKeys(atys map[string]cty.Type) []string {
+	keys := make([]string, 0, len(atys))
+	for k := range atys {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
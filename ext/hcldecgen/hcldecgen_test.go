@@ -0,0 +1,657 @@
+package hcldecgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl2/hcl"
+	hcljson "github.com/hashicorp/hcl2/hcl/json"
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/hashicorp/hcl2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var testSpec = hcldec.ObjectSpec{
+	"name": &hcldec.AttrSpec{
+		Name:     "name",
+		Type:     cty.String,
+		Required: true,
+	},
+	"size": &hcldec.DefaultSpec{
+		Primary: &hcldec.AttrSpec{
+			Name: "size_in_mb",
+			Type: cty.Number,
+		},
+		Default: &hcldec.LiteralSpec{
+			Value: cty.NumberIntVal(512),
+		},
+	},
+	"rules": &hcldec.BlockListSpec{
+		TypeName: "rule",
+		MaxItems: 4,
+		Nested: hcldec.ObjectSpec{
+			"name": &hcldec.BlockLabelSpec{
+				Index: 0,
+				Name:  "name",
+			},
+			"ports": &hcldec.AttrSpec{
+				Name: "ports",
+				Type: cty.List(cty.Number),
+			},
+		},
+	},
+	"tags": &hcldec.BlockAttrsSpec{
+		TypeName:    "tags",
+		ElementType: cty.String,
+	},
+}
+
+func TestSpecFile(t *testing.T) {
+	got, err := SpecFile(testSpec, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `object {
+  attr "name" {
+    type     = string
+    required = true
+  }
+  block_list "rules" {
+    block_type = "rule"
+    max_items  = 4
+    object {
+      block_label "name" {
+        index = 0
+      }
+      attr "ports" {
+        type = list(number)
+      }
+    }
+  }
+  default "size" {
+    attr {
+      name = "size_in_mb"
+      type = number
+    }
+    literal {
+      value = 512
+    }
+  }
+  block_attrs "tags" {
+    element_type = string
+  }
+}
+`
+	if string(got) != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGoSource(t *testing.T) {
+	got, err := GoSource(testSpec, nil, "config", "Spec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `package config
+
+import (
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var Spec hcldec.Spec = hcldec.ObjectSpec{
+	"name": &hcldec.AttrSpec{
+		Name:     "name",
+		Type:     cty.String,
+		Required: true,
+	},
+	"rules": &hcldec.BlockListSpec{
+		TypeName: "rule",
+		Nested: hcldec.ObjectSpec{
+			"name": &hcldec.BlockLabelSpec{
+				Index: 0,
+				Name:  "name",
+			},
+			"ports": &hcldec.AttrSpec{
+				Name: "ports",
+				Type: cty.List(cty.Number),
+			},
+		},
+		MaxItems: 4,
+	},
+	"size": &hcldec.DefaultSpec{
+		Primary: &hcldec.AttrSpec{
+			Name: "size_in_mb",
+			Type: cty.Number,
+		},
+		Default: &hcldec.LiteralSpec{
+			Value: cty.NumberIntVal(512),
+		},
+	},
+	"tags": &hcldec.BlockAttrsSpec{
+		TypeName:    "tags",
+		ElementType: cty.String,
+	},
+}
+`
+	if string(got) != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// exprSpec returns a spec that uses expressions parsed from a source file,
+// along with the files that contain their source text.
+func exprSpec(t *testing.T) (hcldec.Spec, map[string]*hcl.File) {
+	parser := hclparse.NewParser()
+	f, diags := parser.ParseHCL([]byte(`
+result = nested * 1024
+cond   = self > 0
+`), "exprs.hcl")
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+	attrs, diags := f.Body.JustAttributes()
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	spec := hcldec.ObjectSpec{
+		"size": &hcldec.TransformExprSpec{
+			Wrapped: &hcldec.DeprecatedSpec{
+				Wrapped: &hcldec.AttrSpec{
+					Name: "size_in_kb",
+					Type: cty.Number,
+				},
+				OldName: "size",
+				Message: "Use size_in_kb instead.",
+			},
+			Expr:    attrs["result"].Expr,
+			VarName: "nested",
+		},
+		"port": &hcldec.ValidateSpec{
+			Wrapped: &hcldec.AttrSpec{
+				Name: "port",
+				Type: cty.Number,
+			},
+			Conditions: []hcldec.ValidateCondition{
+				{
+					Expr:         attrs["cond"].Expr,
+					ErrorMessage: "The port must be positive.",
+				},
+			},
+		},
+		"source": hcldec.ExactlyOneOf(hcldec.ObjectSpec{
+			"file": &hcldec.AttrSpec{
+				Name: "source_file",
+				Type: cty.String,
+			},
+			"${inline}": &hcldec.AttrSpec{
+				Name: "source_inline",
+				Type: cty.String,
+			},
+		}),
+	}
+	return spec, parser.Files()
+}
+
+func TestSpecFileExpressions(t *testing.T) {
+	spec, files := exprSpec(t)
+	got, err := SpecFile(spec, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `object {
+  validate "port" {
+    attr {
+      name = "port"
+      type = number
+    }
+    condition {
+      expr          = self > 0
+      error_message = "The port must be positive."
+    }
+  }
+  transform "size" {
+    result = nested * 1024
+    deprecated {
+      old_name = "size"
+      message  = "Use size_in_kb instead."
+      attr {
+        name = "size_in_kb"
+        type = number
+      }
+    }
+  }
+  one_of "source" {
+    required = true
+    attr "$${inline}" {
+      name = "source_inline"
+      type = string
+    }
+    attr "file" {
+      name = "source_file"
+      type = string
+    }
+  }
+}
+`
+	if string(got) != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := SpecFile(spec, nil); err == nil {
+		t.Errorf("SpecFile succeeded without the source files; want error")
+	}
+}
+
+func TestGoSourceExpressions(t *testing.T) {
+	spec, files := exprSpec(t)
+	got, err := GoSource(spec, files, "config", "Spec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `package config
+
+import (
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var Spec hcldec.Spec = hcldec.ObjectSpec{
+	"port": &hcldec.ValidateSpec{
+		Wrapped: &hcldec.AttrSpec{
+			Name: "port",
+			Type: cty.Number,
+		},
+		Conditions: []hcldec.ValidateCondition{
+			{
+				Expr:         parseSpecExpr("self > 0"),
+				ErrorMessage: "The port must be positive.",
+			},
+		},
+	},
+	"size": &hcldec.TransformExprSpec{
+		Wrapped: &hcldec.DeprecatedSpec{
+			Wrapped: &hcldec.AttrSpec{
+				Name: "size_in_kb",
+				Type: cty.Number,
+			},
+			OldName: "size",
+			Message: "Use size_in_kb instead.",
+		},
+		Expr:    parseSpecExpr("nested * 1024"),
+		VarName: "nested",
+	},
+	"source": &hcldec.OneOfSpec{
+		Specs: hcldec.ObjectSpec{
+			"${inline}": &hcldec.AttrSpec{
+				Name: "source_inline",
+				Type: cty.String,
+			},
+			"file": &hcldec.AttrSpec{
+				Name: "source_file",
+				Type: cty.String,
+			},
+		},
+		Required: true,
+	},
+}
+
+func parseSpecExpr(src string) hcl.Expression {
+	expr, diags := hclsyntax.ParseExpression([]byte(src), "Spec", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		panic(diags.Error())
+	}
+	return expr
+}
+`
+	if string(got) != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGoSourceWithoutCty(t *testing.T) {
+	spec := &hcldec.BlockListSpec{
+		TypeName: "item",
+		Nested: hcldec.ObjectSpec{
+			"name": &hcldec.BlockLabelSpec{
+				Index: 0,
+				Name:  "name",
+			},
+		},
+	}
+	got, err := GoSource(spec, nil, "config", "Spec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(got), "go-cty") {
+		t.Errorf("result imports cty, but does not use it:\n%s", got)
+	}
+}
+
+func TestUnsupportedSpec(t *testing.T) {
+	spec := hcldec.ObjectSpec{
+		"custom": &hcldec.CustomSpec{},
+	}
+	if _, err := SpecFile(spec, nil); err == nil {
+		t.Errorf("SpecFile succeeded; want error")
+	}
+	if _, err := GoSource(spec, nil, "config", "Spec"); err == nil {
+		t.Errorf("GoSource succeeded; want error")
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	spec := hcldec.ObjectSpec{
+		"name": &hcldec.AttrSpec{
+			Name:        "name",
+			Type:        cty.String,
+			Required:    true,
+			Description: "The name of the server.",
+		},
+		"size": &hcldec.DefaultSpec{
+			Primary: &hcldec.AttrSpec{
+				Name: "size",
+				Type: cty.Number,
+			},
+			Default: &hcldec.LiteralSpec{
+				Value: cty.NumberIntVal(512),
+			},
+		},
+		"disk_size": &hcldec.DeprecatedSpec{
+			Wrapped: &hcldec.AttrSpec{
+				Name: "disk_size",
+				Type: cty.Number,
+			},
+			Message: "Use a `disk` block instead.",
+		},
+		"disks": &hcldec.BlockListSpec{
+			TypeName:    "disk",
+			MinItems:    1,
+			MaxItems:    4,
+			Description: "A disk to attach.",
+			Nested: hcldec.ObjectSpec{
+				"name": &hcldec.BlockLabelSpec{
+					Index: 0,
+					Name:  "name",
+				},
+				"size": &hcldec.AttrSpec{
+					Name:     "size",
+					Type:     cty.Number,
+					Required: true,
+				},
+			},
+		},
+		"tags": &hcldec.BlockAttrsSpec{
+			TypeName:    "tags",
+			ElementType: cty.String,
+		},
+	}
+
+	got := string(Markdown(spec))
+	want := "* `disk_size` (Optional, number) - **Deprecated:** Use a `disk` block instead.\n" +
+		"* `name` (Required, string) - The name of the server.\n" +
+		"* `size` (Optional, number) - Defaults to `512`.\n" +
+		"* `disk` (Required) - A disk to attach. Labels: `name`. Between 1 and 4 blocks of this type must be given.\n" +
+		"* `tags` (Optional) - At most one block of this type is allowed, containing arguments with any names and values of type `string`.\n" +
+		"\n" +
+		"## `disk` block\n" +
+		"\n" +
+		"* `size` (Required, number)\n" +
+		"\n"
+	if got != want {
+		t.Errorf("wrong Markdown result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	got = string(HTML(spec))
+	want = "<ul>\n" +
+		"<li><code>disk_size</code> (Optional, number) - <strong>Deprecated:</strong> Use a <code>disk</code> block instead.</li>\n" +
+		"<li><code>name</code> (Required, string) - The name of the server.</li>\n" +
+		"<li><code>size</code> (Optional, number) - Defaults to <code>512</code>.</li>\n" +
+		"<li><code>disk</code> (Required) - A disk to attach. Labels: <code>name</code>. Between 1 and 4 blocks of this type must be given.</li>\n" +
+		"<li><code>tags</code> (Optional) - At most one block of this type is allowed, containing arguments with any names and values of type <code>string</code>.</li>\n" +
+		"</ul>\n" +
+		"<h2><code>disk</code> block</h2>\n" +
+		"<ul>\n" +
+		"<li><code>size</code> (Required, number)</li>\n" +
+		"</ul>\n"
+	if got != want {
+		t.Errorf("wrong HTML result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	spec := hcldec.ObjectSpec{
+		"name": &hcldec.AttrSpec{
+			Name:        "name",
+			Type:        cty.String,
+			Required:    true,
+			Description: "The name of the server.",
+		},
+		"size": &hcldec.DefaultSpec{
+			Primary: &hcldec.AttrSpec{
+				Name: "size",
+				Type: cty.Number,
+			},
+			Default: &hcldec.LiteralSpec{
+				Value: cty.NumberIntVal(512),
+			},
+		},
+		"resources": &hcldec.BlockListSpec{
+			TypeName: "resource",
+			Nested: hcldec.ObjectSpec{
+				"type": &hcldec.BlockLabelSpec{
+					Index: 0,
+					Name:  "type",
+				},
+				"name": &hcldec.BlockLabelSpec{
+					Index: 1,
+					Name:  "name",
+				},
+				"ports": &hcldec.AttrSpec{
+					Name: "ports",
+					Type: cty.List(cty.Number),
+				},
+			},
+		},
+		"network": &hcldec.BlockSpec{
+			TypeName: "network",
+			Nested: hcldec.ObjectSpec{
+				"cidr": &hcldec.AttrSpec{
+					Name:     "cidr",
+					Type:     cty.String,
+					Required: true,
+				},
+			},
+		},
+		"rules": &hcldec.BlockListSpec{
+			TypeName: "rule",
+			MaxItems: 2,
+			Nested: hcldec.ObjectSpec{
+				"port": &hcldec.AttrSpec{
+					Name: "port",
+					Type: cty.Number,
+				},
+			},
+		},
+		"tags": &hcldec.BlockAttrsSpec{
+			TypeName:    "tags",
+			ElementType: cty.String,
+		},
+	}
+
+	var schema map[string]interface{}
+	if err := json.Unmarshal(JSONSchema(spec), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	obj := schema["anyOf"].([]interface{})[0].(map[string]interface{})
+	props := obj["properties"].(map[string]interface{})
+	if got, want := props["name"].(map[string]interface{})["description"], "The name of the server."; got != want {
+		t.Errorf("wrong description %#v; want %#v", got, want)
+	}
+	if got, want := props["size"].(map[string]interface{})["default"], 512.0; got != want {
+		t.Errorf("wrong default %#v; want %#v", got, want)
+	}
+
+	tests := []struct {
+		Src   string
+		Valid bool
+	}{
+		{`{"name": "a"}`, true},
+		{`[{"name": "a"}, {"size": 2}]`, true},
+		{`{"name": null}`, true},
+		{`{"name": "a", "size": "2", "//": "comment"}`, true},
+		{`{"name": "a", "resource": {"web": {"primary": {"ports": [80]}}}}`, true},
+		{`{"name": "a", "resource": [{"web": {"primary": {}}}, {"db": {"main": {}}}]}`, true},
+		{`{"name": "a", "resource": {"web": [{"primary": {}}, {"secondary": {}}]}}`, true},
+		{`{"name": "a", "resource": {"web": {"primary": [{"ports": [80]}, {"ports": null}]}}}`, true},
+		{`{"name": "a", "resource": {"web": {"primary": [[{"ports": [80]}], null]}}}`, true},
+		{`{"name": "a", "resource": {"web": {"primary": null}}}`, true},
+		{`{"name": "a", "network": {"cidr": "10.0.0.0/8"}}`, true},
+		{`{"name": "a", "network": [{"cidr": "10.0.0.0/8"}]}`, true},
+		{`{"name": "a", "network": [[{"cidr": "10.0.0.0/8"}]]}`, true},
+		{`{"name": "a", "network": null}`, true},
+		{`{"name": "a", "rule": [{"port": 1}, {"port": null}]}`, true},
+		{`{"name": "a", "tags": {"env": "prod"}}`, true},
+		{`{"name": "a", "tags": [{"env": "prod"}]}`, true},
+
+		{`{}`, false},
+		{`{"name": "a", "other": 1}`, false},
+		{`{"name": "a", "size": true}`, false},
+		{`{"name": "a", "network": {}}`, false},
+		{`{"name": "a", "network": 1}`, false},
+		{`{"name": "a", "network": [{"cidr": "a"}, {"cidr": "b"}]}`, false},
+		{`{"name": "a", "rule": [{}, {}, {}]}`, false},
+		{`{"name": "a", "resource": {"web": {"primary": {"other": 1}}}}`, false},
+		{`{"name": "a", "resource": {"web": [1]}}`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Src, func(t *testing.T) {
+			f, diags := hcljson.Parse([]byte(test.Src), "test.json")
+			if !diags.HasErrors() {
+				_, diags = hcldec.Decode(f.Body, spec, nil)
+			}
+			if got := !diags.HasErrors(); got != test.Valid {
+				t.Fatalf("hcl/json fixture is valid = %v; want %v\n%s", got, test.Valid, diags.Error())
+			}
+
+			var val interface{}
+			if err := json.Unmarshal([]byte(test.Src), &val); err != nil {
+				t.Fatal(err)
+			}
+			err := validateJSONSchema(schema, val)
+			if got := err == nil; got != test.Valid {
+				t.Errorf("schema accepts fixture = %v; want %v (%v)", got, test.Valid, err)
+			}
+		})
+	}
+}
+
+// validateJSONSchema checks a value against a JSON Schema, supporting only
+// the keywords that JSONSchema generates.
+func validateJSONSchema(schema map[string]interface{}, val interface{}) error {
+	if alts, ok := schema["anyOf"].([]interface{}); ok {
+		var errs []error
+		for _, alt := range alts {
+			err := validateJSONSchema(alt.(map[string]interface{}), val)
+			if err == nil {
+				errs = nil
+				break
+			}
+			errs = append(errs, err)
+		}
+		if errs != nil {
+			return fmt.Errorf("no alternative matches: %v", errs)
+		}
+	}
+
+	if ty, ok := schema["type"]; ok {
+		types, ok := ty.([]interface{})
+		if !ok {
+			types = []interface{}{ty}
+		}
+		var got string
+		switch val.(type) {
+		case nil:
+			got = "null"
+		case bool:
+			got = "boolean"
+		case float64:
+			got = "number"
+		case string:
+			got = "string"
+		case []interface{}:
+			got = "array"
+		case map[string]interface{}:
+			got = "object"
+		}
+		match := false
+		for _, want := range types {
+			match = match || want == got
+		}
+		if !match {
+			return fmt.Errorf("%s is not of type %v", got, ty)
+		}
+	}
+
+	switch tv := val.(type) {
+	case map[string]interface{}:
+		props, _ := schema["properties"].(map[string]interface{})
+		for k, v := range tv {
+			propSchema, ok := props[k].(map[string]interface{})
+			if !ok {
+				switch extra := schema["additionalProperties"].(type) {
+				case bool:
+					if !extra {
+						return fmt.Errorf("unexpected property %q", k)
+					}
+					continue
+				case map[string]interface{}:
+					propSchema = extra
+				default:
+					continue
+				}
+			}
+			if err := validateJSONSchema(propSchema, v); err != nil {
+				return fmt.Errorf("%s: %s", k, err)
+			}
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, k := range required {
+			if _, ok := tv[k.(string)]; !ok {
+				return fmt.Errorf("missing property %q", k)
+			}
+		}
+	case []interface{}:
+		if min, ok := schema["minItems"].(float64); ok && float64(len(tv)) < min {
+			return fmt.Errorf("too few items")
+		}
+		if max, ok := schema["maxItems"].(float64); ok && float64(len(tv)) > max {
+			return fmt.Errorf("too many items")
+		}
+		for i, v := range tv {
+			var itemSchema map[string]interface{}
+			switch items := schema["items"].(type) {
+			case map[string]interface{}:
+				itemSchema = items
+			case []interface{}:
+				if i < len(items) {
+					itemSchema = items[i].(map[string]interface{})
+				}
+			}
+			if itemSchema == nil {
+				continue
+			}
+			if err := validateJSONSchema(itemSchema, v); err != nil {
+				return fmt.Errorf("[%d]: %s", i, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package hcldecgen
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// JSONSchema returns a JSON Schema document describing the JSON syntax of
+// configuration accepted by the given spec, as parsed by package hcl/json.
+//
+// The schema models the JSON block encoding as accepted by hcl/json, where
+// each block type is a property whose value has one level of nested objects
+// for each block label, and where the innermost value is either an object
+// representing a single block body, an array of bodies or null. At each
+// label level and for each body, an array of objects may be given instead of
+// a single object, in which case the properties of all of the objects are
+// combined. Attribute values and bodies may also be null.
+//
+// Since strings in the JSON syntax are interpreted as templates, which can
+// produce values of any type, the schema accepts a string wherever a number,
+// bool or collection is expected. The schema does not capture constraints
+// that can't be expressed in JSON Schema, such as those of a OneOfSpec or
+// ValidateSpec, so a config that conforms to the schema might still fail to
+// decode.
+func JSONSchema(spec hcldec.Spec) []byte {
+	schema := jsonBodyValueSchema(jsonBodySchema(spec))
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	src, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		// Should never happen, since we only build maps, slices and
+		// primitive values above.
+		panic(err)
+	}
+	return append(src, '\n')
+}
+
+type jsonObj = map[string]interface{}
+
+// jsonBodySchema returns the schema for a JSON object representing a body
+// decoded by the given spec.
+func jsonBodySchema(spec hcldec.Spec) jsonObj {
+	props := jsonObj{
+		// hcl/json ignores "//" properties, so they can be used as comments.
+		"//": jsonObj{},
+	}
+	var required []string
+
+	var visit func(spec hcldec.Spec, dflt *cty.Value)
+	visit = func(spec hcldec.Spec, dflt *cty.Value) {
+		switch s := spec.(type) {
+		case *hcldec.DefaultSpec:
+			if lit, ok := s.Default.(*hcldec.LiteralSpec); ok && lit.Value.IsWhollyKnown() && !lit.Value.IsNull() {
+				dflt = &lit.Value
+			}
+			visit(s.Primary, dflt)
+			return
+
+		case *hcldec.DeprecatedSpec:
+			before := make(map[string]bool, len(props))
+			for name := range props {
+				before[name] = true
+			}
+			visit(s.Wrapped, dflt)
+			var added []string
+			for name := range props {
+				if !before[name] {
+					added = append(added, name)
+				}
+			}
+			for _, name := range added {
+				prop := props[name].(jsonObj)
+				prop["deprecated"] = true
+				if s.OldName != "" {
+					// The same value is accepted under the old name too,
+					// so neither name can be required by the schema.
+					props[s.OldName] = prop
+					required = removeString(required, name)
+				}
+			}
+			return
+
+		case *hcldec.AttrSpec:
+			prop := jsonAttrSchema(s.Type)
+			setDescription(prop, s.Description)
+			if dflt != nil {
+				if src, err := ctyjson.Marshal(*dflt, dflt.Type()); err == nil {
+					prop["default"] = json.RawMessage(src)
+				}
+			}
+			props[s.Name] = prop
+			if s.Required {
+				required = append(required, s.Name)
+			}
+
+		case *hcldec.BlockSpec:
+			props[s.TypeName] = jsonBlockSchema(jsonNestedBodySchema(s), blockLabels(s, s.TypeName), false, 0, 0, s.Description)
+			if s.Required {
+				required = append(required, s.TypeName)
+			}
+
+		case *hcldec.BlockListSpec:
+			props[s.TypeName] = jsonBlockSchema(jsonNestedBodySchema(s), blockLabels(s, s.TypeName), true, s.MinItems, s.MaxItems, s.Description)
+			if s.MinItems > 0 {
+				required = append(required, s.TypeName)
+			}
+
+		case *hcldec.BlockSetSpec:
+			props[s.TypeName] = jsonBlockSchema(jsonNestedBodySchema(s), blockLabels(s, s.TypeName), true, s.MinItems, s.MaxItems, s.Description)
+			if s.MinItems > 0 {
+				required = append(required, s.TypeName)
+			}
+
+		case *hcldec.BlockMapSpec:
+			props[s.TypeName] = jsonBlockSchema(jsonNestedBodySchema(s), blockLabels(s, s.TypeName), true, 0, 0, s.Description)
+
+		case *hcldec.BlockAttrsSpec:
+			body := jsonObj{
+				"type":                 "object",
+				"additionalProperties": jsonAttrSchema(s.ElementType),
+			}
+			props[s.TypeName] = jsonBlockSchema(body, nil, false, 0, 0, s.Description)
+			if s.Required {
+				required = append(required, s.TypeName)
+			}
+		}
+
+		for _, child := range hcldec.SameBodyChildren(spec) {
+			visit(child, nil)
+		}
+	}
+	visit(spec, nil)
+
+	ret := jsonObj{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		ret["required"] = required
+	}
+	return ret
+}
+
+// jsonBodyValueSchema returns the schema for a JSON value that hcl/json
+// accepts as a body whose object form has the given schema: a single object,
+// an array of objects whose properties are combined, or null for an empty
+// body.
+func jsonBodyValueSchema(obj jsonObj) jsonObj {
+	// A required property may appear in any one of the objects in an array,
+	// which JSON Schema can't express, so the array items require nothing.
+	item := make(jsonObj, len(obj))
+	for k, v := range obj {
+		if k != "required" {
+			item[k] = v
+		}
+	}
+	alts := []interface{}{
+		obj,
+		jsonObj{
+			"type":  "array",
+			"items": item,
+		},
+	}
+	if _, ok := obj["required"]; !ok {
+		alts = append(alts, jsonObj{"type": "null"})
+	}
+	return jsonObj{"anyOf": alts}
+}
+
+// jsonNestedBodySchema returns the schema for the object form of the bodies
+// of blocks decoded by the given block spec.
+func jsonNestedBodySchema(spec hcldec.Spec) jsonObj {
+	if nested := hcldec.NestedSpec(spec); nested != nil {
+		return jsonBodySchema(nested)
+	}
+	return jsonObj{"type": "object"}
+}
+
+// blockLabels returns the names of the labels of the blocks of the given
+// type decoded by the given spec.
+func blockLabels(spec hcldec.Spec, typeName string) []string {
+	for _, blockS := range hcldec.BlockHeaderSchemata(spec) {
+		if blockS.Type == typeName {
+			return blockS.LabelNames
+		}
+	}
+	return nil
+}
+
+// jsonBlockSchema returns the schema for the value of the property
+// representing the blocks of a particular type, given the schema for the
+// object form of their bodies.
+func jsonBlockSchema(body jsonObj, labels []string, multi bool, min, max int, desc string) jsonObj {
+	// Once any labels have been dealt with, an object represents a single
+	// block, an array represents any number of blocks, each with a body that
+	// may itself be an array of objects, and null represents no blocks.
+	arr := jsonObj{
+		"type":  "array",
+		"items": jsonBodyValueSchema(body),
+	}
+	if len(labels) == 0 {
+		if !multi {
+			max = 1
+		}
+		if min > 0 {
+			arr["minItems"] = min
+		}
+		if max > 0 {
+			arr["maxItems"] = max
+		}
+	}
+	ret := jsonObj{
+		"anyOf": []interface{}{body, arr, jsonObj{"type": "null"}},
+	}
+
+	// Each label adds one level of object nesting, with the label values
+	// as property names, and each level may also be an array of objects.
+	for range labels {
+		level := jsonObj{
+			"type":                 "object",
+			"additionalProperties": ret,
+		}
+		ret = jsonObj{
+			"anyOf": []interface{}{
+				level,
+				jsonObj{
+					"type":  "array",
+					"items": level,
+				},
+			},
+		}
+	}
+
+	setDescription(ret, desc)
+	return ret
+}
+
+// jsonAttrSchema returns the schema for the JSON value of an attribute of
+// the given type.
+func jsonAttrSchema(ty cty.Type) jsonObj {
+	var ret jsonObj
+	switch {
+	case ty == cty.String:
+		return jsonObj{"type": []interface{}{"string", "null"}}
+	case ty == cty.DynamicPseudoType:
+		return jsonObj{}
+	case ty == cty.Number:
+		ret = jsonObj{"type": "number"}
+	case ty == cty.Bool:
+		ret = jsonObj{"type": "boolean"}
+	case ty.IsListType() || ty.IsSetType():
+		ret = jsonObj{
+			"type":  "array",
+			"items": jsonAttrSchema(ty.ElementType()),
+		}
+		if ty.IsSetType() {
+			ret["uniqueItems"] = true
+		}
+	case ty.IsTupleType():
+		etys := ty.TupleElementTypes()
+		items := make([]interface{}, len(etys))
+		for i, ety := range etys {
+			items[i] = jsonAttrSchema(ety)
+		}
+		ret = jsonObj{
+			"type":     "array",
+			"items":    items,
+			"minItems": len(etys),
+			"maxItems": len(etys),
+		}
+	case ty.IsMapType():
+		ret = jsonObj{
+			"type":                 "object",
+			"additionalProperties": jsonAttrSchema(ty.ElementType()),
+		}
+	case ty.IsObjectType():
+		atys := ty.AttributeTypes()
+		props := make(jsonObj, len(atys))
+		for name, aty := range atys {
+			props[name] = jsonAttrSchema(aty)
+		}
+		ret = jsonObj{
+			"type":       "object",
+			"properties": props,
+		}
+	default:
+		return jsonObj{}
+	}
+
+	// A string is always acceptable, since it's interpreted as a template
+	// that could produce a value of any type, and so is null.
+	return jsonObj{
+		"anyOf": []interface{}{ret, jsonObj{"type": []interface{}{"string", "null"}}},
+	}
+}
+
+func setDescription(schema jsonObj, desc string) {
+	if desc != "" {
+		schema["description"] = desc
+	}
+}
+
+func removeString(list []string, s string) []string {
+	ret := list[:0]
+	for _, v := range list {
+		if v != s {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,260 @@
+package hcldecgen
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/hashicorp/hcl2/ext/typeexpr"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/hashicorp/hcl2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// SpecFile returns the source code of a spec file in the format accepted by
+// the "hcldec" command line tool, describing the given spec.
+//
+// The expressions of any TransformExprSpec or ValidateSpec are written using
+// their source text, which is found in the given files, keyed by filename as
+// returned from hclparse.Parser.Files. The files may be nil if the spec has
+// no such expressions.
+//
+// An error is returned if the spec contains any specs that cannot be
+// represented in the spec file format, or expressions whose source text is
+// not available.
+func SpecFile(spec hcldec.Spec, files map[string]*hcl.File) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeSpec(&buf, spec, "", files); err != nil {
+		return nil, err
+	}
+	return hclwrite.Format(buf.Bytes()), nil
+}
+
+// writeSpec writes a spec block for the given spec to the given buffer. If
+// key is not empty then it is used as the block label, as required for the
+// children of an object spec.
+func writeSpec(buf *bytes.Buffer, spec hcldec.Spec, key string, files map[string]*hcl.File) error {
+	switch s := spec.(type) {
+
+	case hcldec.ObjectSpec:
+		writeHeader(buf, "object", key)
+		for _, k := range sortedKeys(s) {
+			if err := writeSpec(buf, s[k], k, files); err != nil {
+				return err
+			}
+		}
+
+	case hcldec.TupleSpec:
+		writeHeader(buf, "array", key)
+		for _, elem := range s {
+			if err := writeSpec(buf, elem, "", files); err != nil {
+				return err
+			}
+		}
+
+	case *hcldec.AttrSpec:
+		writeHeader(buf, "attr", key)
+		writeName(buf, "name", s.Name, key)
+		writeArg(buf, "type", typeexpr.TypeString(s.Type))
+		writeBool(buf, "required", s.Required)
+		writeDescription(buf, s.Description)
+
+	case *hcldec.BlockSpec:
+		writeHeader(buf, "block", key)
+		writeName(buf, "block_type", s.TypeName, key)
+		writeBool(buf, "required", s.Required)
+		writeDescription(buf, s.Description)
+		if err := writeSpec(buf, s.Nested, "", files); err != nil {
+			return err
+		}
+
+	case *hcldec.BlockListSpec:
+		writeHeader(buf, "block_list", key)
+		writeName(buf, "block_type", s.TypeName, key)
+		writeInt(buf, "min_items", s.MinItems)
+		writeInt(buf, "max_items", s.MaxItems)
+		writeDescription(buf, s.Description)
+		if err := writeSpec(buf, s.Nested, "", files); err != nil {
+			return err
+		}
+
+	case *hcldec.BlockSetSpec:
+		writeHeader(buf, "block_set", key)
+		writeName(buf, "block_type", s.TypeName, key)
+		writeInt(buf, "min_items", s.MinItems)
+		writeInt(buf, "max_items", s.MaxItems)
+		writeDescription(buf, s.Description)
+		if err := writeSpec(buf, s.Nested, "", files); err != nil {
+			return err
+		}
+
+	case *hcldec.BlockMapSpec:
+		writeHeader(buf, "block_map", key)
+		writeName(buf, "block_type", s.TypeName, key)
+		labels := make([]cty.Value, len(s.LabelNames))
+		for i, name := range s.LabelNames {
+			labels[i] = cty.StringVal(name)
+		}
+		writeArg(buf, "labels", string(hclwrite.TokensForValue(cty.TupleVal(labels)).Bytes()))
+		writeDescription(buf, s.Description)
+		if err := writeSpec(buf, s.Nested, "", files); err != nil {
+			return err
+		}
+
+	case *hcldec.BlockAttrsSpec:
+		writeHeader(buf, "block_attrs", key)
+		writeName(buf, "block_type", s.TypeName, key)
+		writeArg(buf, "element_type", typeexpr.TypeString(s.ElementType))
+		writeBool(buf, "required", s.Required)
+		writeDescription(buf, s.Description)
+
+	case *hcldec.BlockLabelSpec:
+		writeHeader(buf, "block_label", key)
+		writeName(buf, "name", s.Name, key)
+		writeArg(buf, "index", strconv.Itoa(s.Index))
+
+	case *hcldec.LiteralSpec:
+		if !s.Value.IsWhollyKnown() {
+			return fmt.Errorf("cannot write a literal spec with an unknown value")
+		}
+		writeHeader(buf, "literal", key)
+		writeArg(buf, "value", string(hclwrite.TokensForValue(s.Value).Bytes()))
+
+	case *hcldec.DefaultSpec:
+		writeHeader(buf, "default", key)
+		for _, candidate := range flattenDefault(s) {
+			if err := writeSpec(buf, candidate, "", files); err != nil {
+				return err
+			}
+		}
+
+	case *hcldec.TransformExprSpec:
+		// The spec file format always names the nested result "nested".
+		if s.VarName != "nested" {
+			return fmt.Errorf("cannot write a transform spec whose variable is not named \"nested\" to a spec file")
+		}
+		src, err := exprSource(s.Expr, files)
+		if err != nil {
+			return err
+		}
+		writeHeader(buf, "transform", key)
+		writeArg(buf, "result", src)
+		if err := writeSpec(buf, s.Wrapped, "", files); err != nil {
+			return err
+		}
+
+	case *hcldec.ValidateSpec:
+		writeHeader(buf, "validate", key)
+		if err := writeSpec(buf, s.Wrapped, "", files); err != nil {
+			return err
+		}
+		for _, cond := range s.Conditions {
+			if cond.Func != nil {
+				return fmt.Errorf("cannot write a validation condition with a Go function to a spec file")
+			}
+			src, err := exprSource(cond.Expr, files)
+			if err != nil {
+				return err
+			}
+			buf.WriteString("condition {\n")
+			writeArg(buf, "expr", src)
+			if cond.ErrorMessage != "" {
+				writeArg(buf, "error_message", string(hclwrite.TokensForValue(cty.StringVal(cond.ErrorMessage)).Bytes()))
+			}
+			buf.WriteString("}\n")
+		}
+
+	case *hcldec.DeprecatedSpec:
+		writeHeader(buf, "deprecated", key)
+		if s.OldName != "" {
+			writeArg(buf, "old_name", string(hclwrite.TokensForValue(cty.StringVal(s.OldName)).Bytes()))
+		}
+		if s.Message != "" {
+			writeArg(buf, "message", string(hclwrite.TokensForValue(cty.StringVal(s.Message)).Bytes()))
+		}
+		if err := writeSpec(buf, s.Wrapped, "", files); err != nil {
+			return err
+		}
+
+	case *hcldec.OneOfSpec:
+		writeHeader(buf, "one_of", key)
+		writeBool(buf, "required", s.Required)
+		for _, k := range sortedKeys(s.Specs) {
+			if err := writeSpec(buf, s.Specs[k], k, files); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("cannot write %T to a spec file", spec)
+	}
+
+	buf.WriteString("}\n")
+	return nil
+}
+
+func writeHeader(buf *bytes.Buffer, blockType, key string) {
+	buf.WriteString(blockType)
+	if key != "" {
+		buf.WriteByte(' ')
+		buf.Write(hclwrite.TokensForValue(cty.StringVal(key)).Bytes())
+	}
+	buf.WriteString(" {\n")
+}
+
+// writeName writes a name argument, unless it is already implied by the
+// block label.
+func writeName(buf *bytes.Buffer, argName, name, key string) {
+	if name == key {
+		return
+	}
+	writeArg(buf, argName, string(hclwrite.TokensForValue(cty.StringVal(name)).Bytes()))
+}
+
+func writeDescription(buf *bytes.Buffer, desc string) {
+	if desc != "" {
+		writeArg(buf, "description", string(hclwrite.TokensForValue(cty.StringVal(desc)).Bytes()))
+	}
+}
+
+func writeBool(buf *bytes.Buffer, name string, v bool) {
+	if v {
+		writeArg(buf, name, "true")
+	}
+}
+
+func writeInt(buf *bytes.Buffer, name string, v int) {
+	if v != 0 {
+		writeArg(buf, name, strconv.Itoa(v))
+	}
+}
+
+func writeArg(buf *bytes.Buffer, name, expr string) {
+	fmt.Fprintf(buf, "%s = %s\n", name, expr)
+}
+
+// flattenDefault returns the candidate specs of a chain of default specs, in
+// the order they are tried.
+func flattenDefault(spec hcldec.Spec) []hcldec.Spec {
+	s, ok := spec.(*hcldec.DefaultSpec)
+	if !ok {
+		return []hcldec.Spec{spec}
+	}
+	return append(flattenDefault(s.Primary), flattenDefault(s.Default)...)
+}
+
+// exprSource returns the source text of the given native syntax expression,
+// which must be present in the given files.
+func exprSource(expr hcl.Expression, files map[string]*hcl.File) (string, error) {
+	rng := expr.Range()
+	if _, isNative := expr.(hclsyntax.Expression); !isNative {
+		return "", fmt.Errorf("cannot write the expression at %s, because it is not in the native syntax", rng)
+	}
+	f := files[rng.Filename]
+	if f == nil || !rng.CanSliceBytes(f.Bytes) {
+		return "", fmt.Errorf("cannot write the expression at %s, because its source text is not available", rng)
+	}
+	return string(rng.SliceBytes(f.Bytes)), nil
+}
@@ -3,10 +3,23 @@
 // structure:
 //
 //     include {
-//       path = "./foo.hcl"
+//       path   = "./foo.hcl"
+//       sha256 = "..." # optional
 //     }
 //
 // The processing of the given path is delegated to the calling application,
 // allowing it to decide how to interpret the path and which syntaxes to
-// support for referenced files.
+// support for referenced files. FileResolver provides a typical
+// implementation that reads files from disk, relative to the file containing
+// the include block, with support for glob patterns, and URLResolver reads
+// sources given as URLs using pluggable fetchers.
+//
+// The optional "sha256" argument gives the expected SHA-256 checksum of the
+// included content, which is verified by resolvers that implement
+// ChecksumResolver. Including a body with a checksum is an error for other
+// resolvers.
+//
+// Included bodies may themselves contain include blocks. Include cycles and
+// excessively deep nesting of includes are reported as errors, with
+// diagnostics that describe the chain of includes that led to the error.
 package include
@@ -0,0 +1,189 @@
+package include
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// A Fetcher retrieves the raw content of an include source given as a URL,
+// for use by the resolver returned by URLResolver.
+//
+// The source given to a fetcher selected by a forced getter prefix, such as
+// "git::", has that prefix removed.
+type Fetcher interface {
+	Fetch(source string) ([]byte, error)
+}
+
+// FetcherFunc is a function type that implements Fetcher.
+type FetcherFunc func(source string) ([]byte, error)
+
+// Fetch is an implementation of Fetcher.Fetch.
+func (f FetcherFunc) Fetch(source string) ([]byte, error) {
+	return f(source)
+}
+
+// DefaultFetchers returns a new map of the fetchers that URLResolver uses
+// when none are configured: FileFetcher for the "file" scheme, HTTPFetcher
+// with the default HTTP client for "http" and "https", and GitFetcher for
+// the "git" forced getter.
+func DefaultFetchers() map[string]Fetcher {
+	return map[string]Fetcher{
+		"file":  FileFetcher(),
+		"http":  HTTPFetcher(nil),
+		"https": HTTPFetcher(nil),
+		"git":   GitFetcher(),
+	}
+}
+
+// FileFetcher returns a Fetcher that reads local files given as "file" URLs,
+// such as "file:///etc/app/shared.hcl".
+func FileFetcher() Fetcher {
+	return FetcherFunc(func(source string) ([]byte, error) {
+		u, err := url.Parse(source)
+		if err != nil {
+			return nil, err
+		}
+		if u.Host != "" && u.Host != "localhost" {
+			return nil, fmt.Errorf("file URLs for remote hosts are not supported")
+		}
+		return ioutil.ReadFile(filepath.FromSlash(u.Path))
+	})
+}
+
+// maxHTTPFetchSize is the largest response body that HTTPFetcher accepts,
+// so that a misbehaving server cannot exhaust our memory.
+const maxHTTPFetchSize = 10 << 20
+
+// HTTPFetcher returns a Fetcher that retrieves sources using HTTP GET
+// requests sent by the given client, or by http.DefaultClient if the client
+// is nil. Any response status other than 200 OK is an error, as is a
+// response body larger than 10 MiB.
+func HTTPFetcher(client *http.Client) Fetcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return FetcherFunc(func(source string) ([]byte, error) {
+		resp, err := client.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("server responded with %s", resp.Status)
+		}
+		src, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxHTTPFetchSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(src) > maxHTTPFetchSize {
+			return nil, fmt.Errorf("response is larger than %d bytes", maxHTTPFetchSize)
+		}
+		return src, nil
+	})
+}
+
+// GitFetcher returns a Fetcher that retrieves files from git repositories,
+// using the git command line tool, which must be available in the PATH.
+//
+// Sources take the form "REPOSITORY//PATH?ref=REF", such as
+// "https://example.com/config.git//shared/logging.hcl?ref=v1.2.0", where
+// REPOSITORY is any URL accepted by "git clone", PATH is the path of the file
+// within the repository, and the optional REF is a branch or tag to check out
+// instead of the default branch.
+func GitFetcher() Fetcher {
+	return FetcherFunc(func(source string) ([]byte, error) {
+		repo, subPath, ref, err := splitGitSource(source)
+		if err != nil {
+			return nil, err
+		}
+
+		dir, err := ioutil.TempDir("", "hcl-include-git")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+
+		args := []string{"clone", "--quiet", "--depth", "1"}
+		if ref != "" {
+			args = append(args, "--branch", ref)
+		}
+		args = append(args, "--", repo, dir)
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("git clone failed: %s", strings.TrimSpace(string(out)))
+		}
+
+		// The repository may contain symlinks, which must not lead us to
+		// read files from outside of the clone.
+		root, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return nil, err
+		}
+		filename, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(subPath)))
+		if err != nil {
+			return nil, err
+		}
+		if rel, err := filepath.Rel(root, filename); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("path %q refers to a file outside of the repository", subPath)
+		}
+		return ioutil.ReadFile(filename)
+	})
+}
+
+// splitGitSource splits a source for GitFetcher into the repository URL, the
+// path of the file within the repository and the ref to check out.
+func splitGitSource(source string) (repo, subPath, ref string, err error) {
+	// The "//" that introduces the path must not be confused with the one
+	// that follows the scheme, if any.
+	start := 0
+	if idx := strings.Index(source, "://"); idx >= 0 {
+		start = idx + 3
+	}
+	idx := strings.Index(source[start:], "//")
+	if idx < 0 {
+		return "", "", "", fmt.Errorf("no file path is given after \"//\"")
+	}
+	repo = source[:start+idx]
+	subPath = source[start+idx+2:]
+	if strings.HasPrefix(repo, "-") {
+		// Sources may come from untrusted bodies, so we must not allow a
+		// repository to be mistaken for an option to git.
+		return "", "", "", fmt.Errorf("repository %q must not start with \"-\"", repo)
+	}
+
+	if idx := strings.Index(subPath, "?"); idx >= 0 {
+		query, err := url.ParseQuery(subPath[idx+1:])
+		if err != nil {
+			return "", "", "", err
+		}
+		ref = query.Get("ref")
+		subPath = subPath[:idx]
+		if strings.HasPrefix(ref, "-") {
+			return "", "", "", fmt.Errorf("ref %q must not start with \"-\"", ref)
+		}
+	}
+	if subPath == "" {
+		return "", "", "", fmt.Errorf("no file path is given after \"//\"")
+	}
+	if strings.HasPrefix(subPath, "/") || filepath.IsAbs(filepath.FromSlash(subPath)) {
+		return "", "", "", fmt.Errorf("file path %q must be relative to the repository", subPath)
+	}
+	for _, segment := range strings.FieldsFunc(subPath, isPathSeparator) {
+		if segment == ".." {
+			return "", "", "", fmt.Errorf("file path %q must not contain \"..\"", subPath)
+		}
+	}
+	return repo, subPath, ref, nil
+}
+
+func isPathSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
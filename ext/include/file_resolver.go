@@ -1,52 +1,122 @@
 package include
 
 import (
+	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/hashicorp/hcl2/hcl"
 	"github.com/hashicorp/hcl2/hclparse"
 )
 
-// FileResolver creates and returns a Resolver that interprets include paths
-// as filesystem paths relative to the calling configuration file.
+// FileResolver returns a Resolver that reads and parses files from disk using
+// the given parser. Files whose names end in ".json" are parsed as JSON, and
+// all others as native syntax.
 //
-// When an include is requested, the source filename of the calling config
-// file is first interpreted relative to the given basePath, and then the
-// path given in configuration is interpreted relative to the resulting
-// absolute caller configuration directory.
+// Include paths are relative to the directory containing the file that the
+// include appears in. The filenames of the files given to the transformer are
+// taken to be relative to the given base directory, while the files read by
+// the resolver are recorded in the parser with their actual paths.
 //
-// This resolver assumes that all calling bodies are loaded from local files
-// and that the paths to these files were correctly provided to the parser,
-// either absolute or relative to the given basePath.
-//
-// If the path given in configuration ends with ".json" then the referenced
-// file is interpreted as JSON. Otherwise, it is interpreted as HCL native
-// syntax.
+// A path may be a glob pattern as accepted by filepath.Match, such as
+// "conf.d/*.hcl", in which case the result is a merge of the bodies of all of
+// the matching files, in lexical order. It is not an error for a pattern to
+// match no files.
 func FileResolver(baseDir string, parser *hclparse.Parser) Resolver {
 	return &fileResolver{
 		BaseDir: baseDir,
 		Parser:  parser,
+		read:    map[string]bool{},
 	}
 }
 
 type fileResolver struct {
 	BaseDir string
 	Parser  *hclparse.Parser
+
+	mu   sync.Mutex
+	read map[string]bool
 }
 
-func (r fileResolver) ResolveBodyPath(path string, refRange hcl.Range) (hcl.Body, hcl.Diagnostics) {
-	callerFile := filepath.Join(r.BaseDir, refRange.Filename)
-	callerDir := filepath.Dir(callerFile)
-	targetFile := filepath.Join(callerDir, path)
+func (r *fileResolver) ResolveBodyPath(path string, refRange hcl.Range) (hcl.Body, hcl.Diagnostics) {
+	target := r.target(path, refRange)
 
-	var f *hcl.File
-	var diags hcl.Diagnostics
-	if strings.HasSuffix(targetFile, ".json") {
-		f, diags = r.Parser.ParseJSONFile(targetFile)
+	var targets []string
+	if strings.ContainsAny(path, "*?[") {
+		matches, err := filepath.Glob(target)
+		if err != nil {
+			return hcl.EmptyBody(), hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid include pattern",
+					Detail:   fmt.Sprintf("The include path %q is not a valid pattern: %s.", path, err),
+					Subject:  &refRange,
+				},
+			}
+		}
+		targets = matches
 	} else {
-		f, diags = r.Parser.ParseHCLFile(targetFile)
+		if _, err := os.Stat(target); err != nil {
+			return hcl.EmptyBody(), hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  "Include file not found",
+					Detail:   fmt.Sprintf("The file %q could not be read.", target),
+					Subject:  &refRange,
+				},
+			}
+		}
+		targets = []string{target}
 	}
 
-	return f.Body, diags
+	r.mu.Lock()
+	for _, target := range targets {
+		r.read[target] = true
+	}
+	r.mu.Unlock()
+
+	files, diags := r.Parser.ParseFiles(targets)
+	bodies := make([]hcl.Body, 0, len(files))
+	for _, f := range files {
+		if f != nil {
+			bodies = append(bodies, f.Body)
+		}
+	}
+	return hcl.MergeBodies(bodies), diags
+}
+
+func (r *fileResolver) CanonicalPath(path string, refRange hcl.Range) string {
+	return canonicalFilePath(r.target(path, refRange))
+}
+
+func (r *fileResolver) CanonicalFilename(filename string) string {
+	return canonicalFilePath(r.callerFile(filename))
+}
+
+// target returns the path of the file, or the pattern, that the given
+// include path refers to.
+func (r *fileResolver) target(path string, refRange hcl.Range) string {
+	return filepath.Join(filepath.Dir(r.callerFile(refRange.Filename)), path)
+}
+
+// callerFile returns the path of the file with the given name, as given in
+// a source range.
+func (r *fileResolver) callerFile(filename string) string {
+	r.mu.Lock()
+	read := r.read[filename]
+	r.mu.Unlock()
+	if read || filepath.IsAbs(filename) {
+		return filename
+	}
+	return filepath.Join(r.BaseDir, filename)
+}
+
+func canonicalFilePath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	return abs
 }
@@ -0,0 +1,211 @@
+package include
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl2/ext/transform"
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hclparse"
+)
+
+type fileResolverTestConfig struct {
+	Items []fileResolverTestItem `hcl:"item,block"`
+}
+
+type fileResolverTestItem struct {
+	Name  string                 `hcl:"name,label"`
+	Items []fileResolverTestItem `hcl:"item,block"`
+}
+
+func loadTestFile(t *testing.T, dir, filename string, opts Options) (*fileResolverTestConfig, hcl.Diagnostics) {
+	parser := hclparse.NewParser()
+	src, err := ioutil.ReadFile(filepath.Join(dir, filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, diags := parser.ParseHCL(src, filename)
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	transformer := TransformerWithOptions("include", nil, FileResolver(dir, parser), opts)
+	body := transform.Deep(f.Body, transformer)
+
+	var config fileResolverTestConfig
+	diags = gohcl.DecodeBody(body, nil, &config)
+	return &config, diags
+}
+
+func itemNames(config *fileResolverTestConfig) string {
+	var names []string
+	for _, item := range config.Items {
+		names = append(names, item.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestFileResolver(t *testing.T) {
+	files := map[string]string{
+		"main.hcl": `
+item "main" {}
+include {
+  path = "sub/a.hcl"
+}
+include {
+  path = "conf.d/*.hcl"
+}
+include {
+  path = "empty.d/*.hcl"
+}
+`,
+		"sub/a.hcl": `
+item "a" {}
+include {
+  path = "b.hcl"
+}
+`,
+		"sub/b.hcl":       `item "b" {}`,
+		"conf.d/2.hcl":    `item "conf2" {}`,
+		"conf.d/1.hcl":    `item "conf1" {}`,
+		"conf.d/ignored":  `item "ignored" {}`,
+		"empty.d/ignored": `item "ignored" {}`,
+	}
+	dir := t.TempDir()
+	for name, src := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config, diags := loadTestFile(t, dir, "main.hcl", Options{})
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+	if got, want := itemNames(config), "main,a,b,conf1,conf2"; got != want {
+		t.Errorf("wrong items\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+func TestFileResolverErrors(t *testing.T) {
+	tests := map[string]struct {
+		Files   map[string]string
+		Opts    Options
+		Summary string
+		Chain   []string
+	}{
+		"cycle": {
+			map[string]string{
+				"main.hcl": `include { path = "a.hcl" }`,
+				"a.hcl":    `include { path = "sub/b.hcl" }`,
+				"sub/b.hcl": `include { path = "../a.hcl" }
+item "b" {}`,
+			},
+			Options{},
+			"Include cycle",
+			[]string{`includes "a.hcl"`, `includes "sub/b.hcl"`, `includes "../a.hcl"`},
+		},
+		"cycle in nested block": {
+			map[string]string{
+				"main.hcl": `include { path = "a.hcl" }`,
+				"a.hcl": `item "a" {
+  include { path = "a.hcl" }
+}`,
+			},
+			Options{},
+			"Include cycle",
+			[]string{`includes "a.hcl"`, `includes "a.hcl"`},
+		},
+		"self glob": {
+			map[string]string{
+				"main.hcl":     `include { path = "conf.d/*.hcl" }`,
+				"conf.d/a.hcl": `include { path = "*.hcl" }`,
+			},
+			Options{},
+			"Include cycle",
+			[]string{`includes "conf.d/*.hcl"`, `includes "*.hcl"`},
+		},
+		"cycle to original file": {
+			map[string]string{
+				"main.hcl": `include { path = "b.hcl" }`,
+				"b.hcl":    `include { path = "./main.hcl" }`,
+			},
+			Options{},
+			"Include cycle",
+			[]string{`includes "b.hcl"`, `includes "./main.hcl"`},
+		},
+		"cycle through unclean path": {
+			map[string]string{
+				"main.hcl":  `include { path = "b.hcl" }`,
+				"b.hcl":     `include { path = "sub/../b.hcl" }`,
+				"sub/.keep": ``,
+			},
+			Options{},
+			"Include cycle",
+			[]string{`includes "b.hcl"`, `includes "sub/../b.hcl"`},
+		},
+		"missing file": {
+			map[string]string{
+				"main.hcl": `include { path = "a.hcl" }`,
+				"a.hcl":    `include { path = "missing.hcl" }`,
+			},
+			Options{},
+			"Include file not found",
+			[]string{`includes "a.hcl"`, `includes "missing.hcl"`},
+		},
+		"too deep": {
+			map[string]string{
+				"main.hcl": `include { path = "a.hcl" }`,
+				"a.hcl":    `include { path = "b.hcl" }`,
+				"b.hcl":    `include { path = "c.hcl" }`,
+				"c.hcl":    `item "c" {}`,
+			},
+			Options{MaxDepth: 2},
+			"Too many nested includes",
+			[]string{`includes "a.hcl"`, `includes "b.hcl"`, `includes "c.hcl"`},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, src := range test.Files {
+				filename := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, diags := loadTestFile(t, dir, "main.hcl", test.Opts)
+			if len(diags) != 1 {
+				t.Fatalf("wrong number of diagnostics %d; want 1\n%s", len(diags), diags.Error())
+			}
+			diag := diags[0]
+			if diag.Summary != test.Summary {
+				t.Errorf("wrong summary %q; want %q", diag.Summary, test.Summary)
+			}
+
+			var chain []string
+			lines := strings.Split(diag.Detail, "\n")
+			for _, line := range lines {
+				if idx := strings.Index(line, " includes "); idx >= 0 {
+					chain = append(chain, line[idx+1:])
+				}
+			}
+			if got, want := strings.Join(chain, "; "), strings.Join(test.Chain, "; "); got != want {
+				t.Errorf("wrong include chain\ngot:  %s\nwant: %s\ndetail:\n%s", got, want, diag.Detail)
+			}
+		})
+	}
+}
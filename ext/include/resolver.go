@@ -26,3 +26,38 @@ type ResolverFunc func(path string, refRange hcl.Range) (hcl.Body, hcl.Diagnosti
 func (f ResolverFunc) ResolveBodyPath(path string, refRange hcl.Range) (hcl.Body, hcl.Diagnostics) {
 	return f(path, refRange)
 }
+
+// A ChecksumResolver is a Resolver that can also verify that the content it
+// resolves has an expected SHA-256 checksum, as given by the optional
+// "sha256" argument of an include block.
+//
+// The transformer calls ResolveBodyPathChecksum instead of ResolveBodyPath
+// for any include block that has a checksum, and it returns an error for such
+// blocks if its resolver does not implement this interface.
+type ChecksumResolver interface {
+	Resolver
+	ResolveBodyPathChecksum(path string, sha256 string, refRange hcl.Range) (hcl.Body, hcl.Diagnostics)
+}
+
+// A CanonicalResolver is a Resolver that can identify the sources that
+// include paths refer to, so that the transformer can detect an include
+// cycle as soon as a source is included again, however its path is written.
+//
+// Resolvers that do not implement this interface can still be used, but a
+// cycle is then detected only once the same include block is reached again.
+type CanonicalResolver interface {
+	Resolver
+
+	// CanonicalPath returns a string that uniquely identifies the source
+	// that the given path refers to, such as a cleaned absolute path or a
+	// URL, or an empty string if the source cannot be identified. For a
+	// pattern that matches several sources, the result identifies the
+	// pattern.
+	CanonicalPath(path string, refRange hcl.Range) string
+
+	// CanonicalFilename returns the identity of the source with the given
+	// filename, as it appears in source ranges, in the same form as the
+	// results of CanonicalPath, or an empty string if the source cannot be
+	// identified.
+	CanonicalFilename(filename string) string
+}
@@ -1,36 +1,46 @@
 package include
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/hashicorp/hcl2/ext/transform"
 	"github.com/hashicorp/hcl2/gohcl"
 	"github.com/hashicorp/hcl2/hcl"
 )
 
-// Transformer builds a transformer that finds any "include" blocks in a body
-// and produces a merged body that contains the original content plus the
-// content of the other bodies referenced by the include blocks.
-//
-// blockType specifies the type of block to interpret. The conventional type name
-// is "include".
-//
-// ctx provides an evaluation context for the path expressions in include blocks.
-// If nil, path expressions may not reference variables nor functions.
-//
-// The given resolver is used to translate path strings (after expression
-// evaluation) into bodies. FileResolver returns a reasonable implementation for
-// applications that read configuration files from local disk.
-//
-// The returned Transformer can either be used directly to process includes
-// in a shallow fashion on a single body, or it can be used with
-// transform.Deep (from the sibling transform package) to allow includes
-// at all levels of a nested block structure:
-//
-//    transformer = include.Transformer("include", nil, include.FileResolver(".", parser))
-//    body = transform.Deep(body, transformer)
-//    // "body" will now have includes resolved in its own content and that
-//    // of any descendent blocks.
+// DefaultMaxDepth is the maximum depth of nested includes permitted by
+// transformers created with a zero MaxDepth option.
+const DefaultMaxDepth = 16
+
+// Options customizes the behavior of a transformer created by
+// TransformerWithOptions.
+type Options struct {
+	// MaxDepth is the maximum number of includes that may be nested inside
+	// one another, counting the include in the original body as the first.
+	// If this is zero then DefaultMaxDepth is used.
+	MaxDepth int
+}
+
+// Transformer returns a transformer that replaces any blocks of the given
+// type in a body with the bodies they refer to, as returned by the given
+// resolver.
 //
+// Included bodies are themselves transformed, so that they may include other
+// bodies in turn. An error diagnostic is produced if an include would create
+// a cycle or if includes are nested more deeply than DefaultMaxDepth, and the
+// diagnostics for such errors and for any errors returned by the resolver
+// describe the chain of includes that led to them.
 func Transformer(blockType string, ctx *hcl.EvalContext, resolver Resolver) transform.Transformer {
+	return TransformerWithOptions(blockType, ctx, resolver, Options{})
+}
+
+// TransformerWithOptions is like Transformer except that it accepts options
+// to customize its behavior.
+func TransformerWithOptions(blockType string, ctx *hcl.EvalContext, resolver Resolver, opts Options) transform.Transformer {
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = DefaultMaxDepth
+	}
 	return &transformer{
 		Schema: &hcl.BodySchema{
 			Blocks: []hcl.BlockHeaderSchema{
@@ -41,6 +51,7 @@ func Transformer(blockType string, ctx *hcl.EvalContext, resolver Resolver) tran
 		},
 		Ctx:      ctx,
 		Resolver: resolver,
+		MaxDepth: opts.MaxDepth,
 	}
 }
 
@@ -48,17 +59,60 @@ type transformer struct {
 	Schema   *hcl.BodySchema
 	Ctx      *hcl.EvalContext
 	Resolver Resolver
+	MaxDepth int
+
+	// Chain is the sequence of includes that led to the body being
+	// transformed, which is empty for the original body.
+	Chain []includeStep
+}
+
+// includeStep describes an include in a chain of includes.
+type includeStep struct {
+	Path  string
+	Range hcl.Range
+
+	// Caller and Source are the canonical identities of the file containing
+	// the include and of the source that it refers to, if the resolver is a
+	// CanonicalResolver that can identify them, or empty otherwise.
+	Caller, Source string
+}
+
+// isCycle returns true if the given include, following the given chain of
+// includes, would include a source that is already being processed.
+func isCycle(chain []includeStep, step includeStep) bool {
+	if step.Source != "" && step.Source == step.Caller {
+		return true
+	}
+	for _, prev := range chain {
+		switch {
+		case step.Source != "":
+			if step.Source == prev.Source || step.Source == prev.Caller {
+				return true
+			}
+		default:
+			// Without canonical identities, the best we can do is to notice
+			// when the same include is reached again: one with the same path
+			// in the same file, which must resolve to the same body.
+			if step.Path == prev.Path && step.Range.Filename == prev.Range.Filename {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (t *transformer) TransformBody(in hcl.Body) hcl.Body {
 	content, remain, diags := in.PartialContent(t.Schema)
 
 	if content == nil || len(content.Blocks) == 0 {
-		// Nothing to do!
-		return transform.BodyWithDiagnostics(remain, diags)
+		// We return the original body rather than the remaining body here
+		// because the remaining body of a body produced by transform.Deep is
+		// not itself wrapped, and so would lose the context of any include
+		// chain that an earlier transform attached to the nested blocks.
+		return transform.BodyWithDiagnostics(in, diags)
 	}
 
-	bodies := make([]hcl.Body, 1, len(content.Blocks)+1)
+	bodies := make([]hcl.Body, 1, len(content.Blocks)+2)
 	bodies[0] = remain // content in "remain" takes priority over includes
 	for _, block := range content.Blocks {
 		incContent, incDiags := block.Body.Content(includeBlockSchema)
@@ -75,18 +129,116 @@ func (t *transformer) TransformBody(in hcl.Body) hcl.Body {
 			continue
 		}
 
-		incBody, incDiags := t.Resolver.ResolveBodyPath(path, pathExpr.Range())
-		bodies = append(bodies, transform.BodyWithDiagnostics(incBody, incDiags))
+		step := includeStep{
+			Path:  path,
+			Range: pathExpr.Range(),
+		}
+		if resolver, ok := t.Resolver.(CanonicalResolver); ok {
+			step.Caller = resolver.CanonicalFilename(step.Range.Filename)
+			step.Source = resolver.CanonicalPath(path, step.Range)
+		}
+		chain := make([]includeStep, len(t.Chain), len(t.Chain)+1)
+		copy(chain, t.Chain)
+		chain = append(chain, step)
+
+		if isCycle(t.Chain, step) {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Include cycle",
+				Detail:   fmt.Sprintf("Including %q here would create a cycle, because it is already being processed.%s", path, chainDetail(chain)),
+				Subject:  step.Range.Ptr(),
+			})
+			continue
+		}
+		if len(chain) > t.MaxDepth {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Too many nested includes",
+				Detail:   fmt.Sprintf("Includes may be nested at most %d levels deep.%s", t.MaxDepth, chainDetail(chain)),
+				Subject:  step.Range.Ptr(),
+			})
+			continue
+		}
+
+		var checksum string
+		if attr, ok := incContent.Attributes["sha256"]; ok {
+			incDiags = gohcl.DecodeExpression(attr.Expr, t.Ctx, &checksum)
+			diags = append(diags, incDiags...)
+			if incDiags.HasErrors() {
+				continue
+			}
+		}
+
+		var incBody hcl.Body
+		if checksum == "" {
+			incBody, incDiags = t.Resolver.ResolveBodyPath(path, step.Range)
+		} else if resolver, ok := t.Resolver.(ChecksumResolver); ok {
+			incBody, incDiags = resolver.ResolveBodyPathChecksum(path, checksum, step.Range)
+		} else {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unsupported include checksum",
+				Detail:   "Checksums cannot be verified for the includes in this configuration.",
+				Subject:  incContent.Attributes["sha256"].Expr.Range().Ptr(),
+			})
+			continue
+		}
+		if len(chain) > 1 {
+			incDiags = withChain(incDiags, chain)
+		}
+		if incBody == nil {
+			incBody = hcl.EmptyBody()
+		}
+
+		child := *t
+		child.Chain = chain
+		bodies = append(bodies, transform.BodyWithDiagnostics(transform.Deep(incBody, &child), incDiags))
+	}
+
+	if len(diags) != 0 {
+		bodies = append(bodies, transform.BodyWithDiagnostics(hcl.EmptyBody(), diags))
 	}
 
 	return hcl.MergeBodies(bodies)
 }
 
+// chainDetail returns a description of the given chain of includes for use
+// at the end of a diagnostic's detail message.
+func chainDetail(chain []includeStep) string {
+	var buf strings.Builder
+	buf.WriteString("\n\nInclude chain:")
+	for _, step := range chain {
+		fmt.Fprintf(&buf, "\n  %s includes %q", step.Range.String(), step.Path)
+	}
+	return buf.String()
+}
+
+// withChain returns a copy of the given diagnostics with a description of
+// the given chain of includes added to the detail of any errors.
+func withChain(diags hcl.Diagnostics, chain []includeStep) hcl.Diagnostics {
+	if !diags.HasErrors() {
+		return diags
+	}
+	ret := make(hcl.Diagnostics, len(diags))
+	for i, diag := range diags {
+		if diag.Severity == hcl.DiagError {
+			copied := *diag
+			copied.Detail += chainDetail(chain)
+			diag = &copied
+		}
+		ret[i] = diag
+	}
+	return ret
+}
+
 var includeBlockSchema = &hcl.BodySchema{
 	Attributes: []hcl.AttributeSchema{
 		{
 			Name:     "path",
 			Required: true,
 		},
+		{
+			Name: "sha256",
+		},
 	},
 }
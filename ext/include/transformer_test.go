@@ -2,11 +2,13 @@ package include
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/hashicorp/hcl2/gohcl"
 	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
 	"github.com/hashicorp/hcl2/hcltest"
 	"github.com/zclconf/go-cty/cty"
 )
@@ -110,3 +112,32 @@ func TestTransformer(t *testing.T) {
 		t.Errorf("wrong result\ngot: %swant: %s", spew.Sdump(got), spew.Sdump(want))
 	}
 }
+
+func TestTransformerCycle(t *testing.T) {
+	// MapResolver can't identify the bodies it returns, so the cycle is
+	// detected only when the same include is reached again.
+	parse := func(src, filename string) hcl.Body {
+		f, diags := hclsyntax.ParseConfig([]byte(src), filename, hcl.Pos{Line: 1, Column: 1})
+		if diags.HasErrors() {
+			t.Fatal(diags.Error())
+		}
+		return f.Body
+	}
+	resolver := MapResolver(map[string]hcl.Body{
+		"a": parse(`include { path = "b" }`, "a.hcl"),
+		"b": parse(`include { path = "a" }`, "b.hcl"),
+	})
+	caller := parse(`include { path = "a" }`, "main.hcl")
+
+	merged := Transformer("include", nil, resolver).TransformBody(caller)
+	_, diags := merged.Content(&hcl.BodySchema{})
+	if len(diags) != 1 {
+		t.Fatalf("wrong number of diagnostics %d; want 1\n%s", len(diags), diags.Error())
+	}
+	if got, want := diags[0].Summary, "Include cycle"; got != want {
+		t.Errorf("wrong summary %q; want %q", got, want)
+	}
+	if got, want := strings.Count(diags[0].Detail, " includes "), 4; got != want {
+		t.Errorf("wrong include chain length %d; want %d\n%s", got, want, diags[0].Detail)
+	}
+}
@@ -0,0 +1,400 @@
+package include
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hclparse"
+)
+
+// URLResolverConfig configures the resolver returned by URLResolver.
+type URLResolverConfig struct {
+	// Parser is used to parse the fetched sources, which are recorded in it
+	// with their URLs as their filenames. Sources whose paths end in ".json"
+	// are parsed as JSON, and all others as native syntax.
+	Parser *hclparse.Parser
+
+	// Fetchers maps URL schemes and forced getter names to the fetchers that
+	// retrieve sources of each kind. If this is nil, the result of
+	// DefaultFetchers is used.
+	Fetchers map[string]Fetcher
+
+	// CacheDir is a directory in which to cache fetched sources, named by
+	// the hex-encoded SHA-256 hash of their content. If this is empty then
+	// nothing is cached.
+	CacheDir string
+
+	// Fallback resolves any paths that are not URLs. If this is nil then
+	// such paths are an error.
+	Fallback Resolver
+}
+
+// URLResolver returns a resolver for include paths that are URL-like
+// sources, which it retrieves using the configured fetchers.
+//
+// The fetcher for a source is chosen by its URL scheme, such as "https" in
+// "https://example.com/shared.hcl", or by a forced getter prefix separated
+// from the rest of the source by "::", such as "git" in
+// "git::https://example.com/config.git//shared.hcl". A relative path in a
+// body that was itself fetched from a URL without a forced getter is
+// resolved relative to that URL. Such a body may not include local paths or
+// "file" URLs, nor sources fetched over a less secure transport than its
+// own, such as "http" sources within a body fetched over "https".
+//
+// The resolver implements ChecksumResolver, verifying the content of a
+// source against the checksum given in its include block. If a cache
+// directory is configured then sources with checksums are served from the
+// cache whenever possible, without fetching them. Sources without checksums
+// are always fetched, since their content may have changed.
+func URLResolver(config URLResolverConfig) Resolver {
+	if config.Fetchers == nil {
+		config.Fetchers = DefaultFetchers()
+	}
+	return &urlResolver{
+		config: config,
+	}
+}
+
+type urlResolver struct {
+	config URLResolverConfig
+}
+
+func (r *urlResolver) ResolveBodyPath(path string, refRange hcl.Range) (hcl.Body, hcl.Diagnostics) {
+	return r.ResolveBodyPathChecksum(path, "", refRange)
+}
+
+func (r *urlResolver) ResolveBodyPathChecksum(path string, checksum string, refRange hcl.Range) (hcl.Body, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+
+	if checksum != "" {
+		checksum = strings.ToLower(checksum)
+		if raw, err := hex.DecodeString(checksum); err != nil || len(raw) != sha256.Size {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid include checksum",
+				Detail:   "The sha256 argument must be a SHA-256 checksum, given as 64 hexadecimal digits.",
+				Subject:  &refRange,
+			})
+			return nil, diags
+		}
+	}
+
+	source, filename, fetcher, fetchDiags := r.fetcher(path, refRange)
+	diags = append(diags, fetchDiags...)
+	if fetchDiags.HasErrors() {
+		return nil, diags
+	}
+	if fetcher == nil {
+		// Not a URL, so it's for our fallback resolver to deal with.
+		switch {
+		case r.config.Fallback == nil:
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid include path",
+				Detail:   fmt.Sprintf("The include path %q is not a URL.", path),
+				Subject:  &refRange,
+			})
+			return nil, diags
+		case checksum == "":
+			return r.config.Fallback.ResolveBodyPath(path, refRange)
+		}
+		if fallback, ok := r.config.Fallback.(ChecksumResolver); ok {
+			return fallback.ResolveBodyPathChecksum(path, checksum, refRange)
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Unsupported include checksum",
+			Detail:   fmt.Sprintf("The include path %q is not a URL, and its checksum cannot be verified.", path),
+			Subject:  &refRange,
+		})
+		return nil, diags
+	}
+
+	var src []byte
+	if checksum != "" {
+		src = r.cached(checksum)
+	}
+	if src == nil {
+		var err error
+		src, err = fetcher.Fetch(source)
+		if err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Failed to fetch include",
+				Detail:   fmt.Sprintf("The include source %q could not be fetched: %s.", path, err),
+				Subject:  &refRange,
+			})
+			return nil, diags
+		}
+
+		sum := sha256.Sum256(src)
+		got := hex.EncodeToString(sum[:])
+		if checksum != "" && got != checksum {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Include checksum mismatch",
+				Detail:   fmt.Sprintf("The content of %q has the SHA-256 checksum %s, but the include block requires %s.", path, got, checksum),
+				Subject:  &refRange,
+			})
+			return nil, diags
+		}
+
+		if err := r.store(got, src); err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagWarning,
+				Summary:  "Failed to cache include",
+				Detail:   fmt.Sprintf("The content of %q could not be saved in the cache directory: %s.", path, err),
+				Subject:  &refRange,
+			})
+		}
+	}
+
+	var f *hcl.File
+	var parseDiags hcl.Diagnostics
+	if sourceExt(source) == ".json" {
+		f, parseDiags = r.config.Parser.ParseJSON(src, filename)
+	} else {
+		f, parseDiags = r.config.Parser.ParseHCL(src, filename)
+	}
+	diags = append(diags, parseDiags...)
+	if f == nil {
+		return nil, diags
+	}
+
+	// The parser returns any file it already has with the same name, which
+	// may have been fetched earlier, without verification, with different
+	// content from what we verified above.
+	if checksum != "" && !bytes.Equal(f.Bytes, src) {
+		sum := sha256.Sum256(f.Bytes)
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Include checksum mismatch",
+			Detail:   fmt.Sprintf("The content of %q was already loaded by another include, and has the SHA-256 checksum %s, but this include block requires %s.", path, hex.EncodeToString(sum[:]), checksum),
+			Subject:  &refRange,
+		})
+		return nil, diags
+	}
+	return f.Body, diags
+}
+
+func (r *urlResolver) CanonicalPath(path string, refRange hcl.Range) string {
+	_, filename, fetcher, diags := r.fetcher(path, refRange)
+	switch {
+	case diags.HasErrors():
+		return ""
+	case fetcher != nil:
+		return filename
+	}
+	if fallback, ok := r.config.Fallback.(CanonicalResolver); ok {
+		return fallback.CanonicalPath(path, refRange)
+	}
+	return ""
+}
+
+func (r *urlResolver) CanonicalFilename(filename string) string {
+	if isURL(filename) {
+		return filename
+	}
+	if fallback, ok := r.config.Fallback.(CanonicalResolver); ok {
+		return fallback.CanonicalFilename(filename)
+	}
+	return ""
+}
+
+// fetcher returns the fetcher for the given path, along with the source to
+// give it and the filename to use for the fetched body. If the path is not
+// a URL then the fetcher is nil.
+//
+// A body that was itself fetched from a remote source may only include
+// sources whose origin is at least as trustworthy as its own, so that it
+// cannot read local files or switch to a less secure transport.
+func (r *urlResolver) fetcher(path string, refRange hcl.Range) (source, filename string, fetcher Fetcher, diags hcl.Diagnostics) {
+	source, filename, fetcher, diags = r.findFetcher(path, refRange)
+	if diags.HasErrors() {
+		return source, filename, fetcher, diags
+	}
+	if origin := sourceOrigin(refRange.Filename); origin > originLocal && sourceOrigin(filename) < origin {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Untrusted include source",
+			Detail:   fmt.Sprintf("The include source %q cannot be used in a body fetched from %q, because it is local or uses a less secure transport.", path, refRange.Filename),
+			Subject:  &refRange,
+		})
+		return "", "", nil, diags
+	}
+	return source, filename, fetcher, diags
+}
+
+func (r *urlResolver) findFetcher(path string, refRange hcl.Range) (source, filename string, fetcher Fetcher, diags hcl.Diagnostics) {
+	if idx := strings.Index(path, "::"); idx > 0 && isSchemeName(path[:idx]) {
+		getter := path[:idx]
+		fetcher = r.config.Fetchers[getter]
+		if fetcher == nil {
+			diags = append(diags, unsupportedSourceDiag(path, getter, refRange))
+		}
+		return path[idx+2:], path, fetcher, diags
+	}
+
+	u, err := url.Parse(path)
+	if err == nil && isURLScheme(u.Scheme) {
+		fetcher = r.config.Fetchers[u.Scheme]
+		if fetcher == nil {
+			diags = append(diags, unsupportedSourceDiag(path, u.Scheme, refRange))
+		}
+		return path, path, fetcher, diags
+	}
+
+	// A relative path in a body that was fetched from a URL is relative to
+	// that URL, as long as we know how to fetch other URLs like it.
+	if err == nil && !strings.Contains(refRange.Filename, "::") {
+		base, baseErr := url.Parse(refRange.Filename)
+		if baseErr == nil && isURLScheme(base.Scheme) && r.config.Fetchers[base.Scheme] != nil {
+			source = base.ResolveReference(u).String()
+			return source, source, r.config.Fetchers[base.Scheme], nil
+		}
+	}
+
+	return "", "", nil, nil
+}
+
+// cached returns the cached content with the given checksum, or nil if there
+// is no such content in the cache.
+func (r *urlResolver) cached(checksum string) []byte {
+	if r.config.CacheDir == "" {
+		return nil
+	}
+	src, err := ioutil.ReadFile(filepath.Join(r.config.CacheDir, checksum))
+	if err != nil {
+		return nil
+	}
+	// If the cached file has been corrupted then we'll just fetch it again.
+	sum := sha256.Sum256(src)
+	if hex.EncodeToString(sum[:]) != checksum {
+		return nil
+	}
+	return src
+}
+
+// store saves the given content in the cache, if there is one. The file is
+// written under a temporary name and then renamed, so that other processes
+// sharing the cache never see partially-written content.
+func (r *urlResolver) store(checksum string, src []byte) error {
+	if r.config.CacheDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(r.config.CacheDir, 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(r.config.CacheDir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(src)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(r.config.CacheDir, checksum))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func unsupportedSourceDiag(path, kind string, refRange hcl.Range) *hcl.Diagnostic {
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Unsupported include source",
+		Detail:   fmt.Sprintf("The include source %q cannot be fetched, because %q sources are not supported.", path, kind),
+		Subject:  &refRange,
+	}
+}
+
+// sourceExt returns the extension of the path part of the given source,
+// ignoring any query string or fragment.
+func sourceExt(source string) string {
+	if idx := strings.IndexAny(source, "?#"); idx >= 0 {
+		source = source[:idx]
+	}
+	return filepath.Ext(source)
+}
+
+// isURL returns true if the given path is a URL, possibly with a forced
+// getter prefix.
+func isURL(path string) bool {
+	if idx := strings.Index(path, "::"); idx > 0 && isSchemeName(path[:idx]) {
+		return true
+	}
+	u, err := url.Parse(path)
+	return err == nil && isURLScheme(u.Scheme)
+}
+
+// The origins of include sources, in increasing order of trust when used as
+// the origin of a body that includes other sources.
+const (
+	originLocal    = iota // local paths and "file" URLs
+	originInsecure        // remote sources fetched over plain "http" or "git"
+	originSecure          // remote sources fetched over any other transport
+)
+
+// sourceOrigin returns the origin of the given include source or filename.
+func sourceOrigin(path string) int {
+	var scheme string
+	if idx := strings.Index(path, "::"); idx > 0 && isSchemeName(path[:idx]) {
+		scheme = path[:idx]
+		path = path[idx+2:]
+		if u, err := url.Parse(path); err == nil && isURLScheme(u.Scheme) {
+			scheme = u.Scheme
+		} else {
+			// Without a URL scheme, a forced getter's source is a local
+			// path unless it uses the scp-like "host:path" syntax for ssh.
+			host := path
+			if idx := strings.Index(host, "/"); idx >= 0 {
+				host = host[:idx]
+			}
+			if !strings.Contains(host, ":") || filepath.VolumeName(path) != "" {
+				return originLocal
+			}
+			scheme = "ssh"
+		}
+	} else if u, err := url.Parse(path); err == nil && isURLScheme(u.Scheme) {
+		scheme = u.Scheme
+	}
+
+	switch strings.ToLower(scheme) {
+	case "", "file":
+		return originLocal
+	case "http", "git":
+		return originInsecure
+	default:
+		return originSecure
+	}
+}
+
+// isURLScheme returns true if the given URL scheme indicates that a path is
+// a URL. Single-letter schemes are excluded so that Windows paths with drive
+// letters are not mistaken for URLs.
+func isURLScheme(scheme string) bool {
+	return len(scheme) > 1
+}
+
+func isSchemeName(s string) bool {
+	for i, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case i > 0 && (c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
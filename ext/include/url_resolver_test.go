@@ -0,0 +1,381 @@
+package include
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/hcl2/ext/transform"
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hclparse"
+)
+
+func loadTestSource(t *testing.T, src string, config URLResolverConfig) (*fileResolverTestConfig, hcl.Diagnostics) {
+	if config.Parser == nil {
+		config.Parser = hclparse.NewParser()
+	}
+	f, diags := config.Parser.ParseHCL([]byte(src), "main.hcl")
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	body := transform.Deep(f.Body, Transformer("include", nil, URLResolver(config)))
+
+	var ret fileResolverTestConfig
+	diags = gohcl.DecodeBody(body, nil, &ret)
+	return &ret, diags
+}
+
+func checksum(src string) string {
+	sum := sha256.Sum256([]byte(src))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestURLResolverHTTP(t *testing.T) {
+	files := map[string]string{
+		"/shared/a.hcl": `item "a" {}
+include { path = "b.hcl.json" }`,
+		"/shared/b.hcl.json": `{"item": {"b": {}}}`,
+	}
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		src, ok := files[req.URL.Path]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		fmt.Fprint(w, src)
+	}))
+	defer server.Close()
+
+	cacheDir := t.TempDir()
+
+	fetchers := map[string]Fetcher{
+		"http": HTTPFetcher(server.Client()),
+	}
+	src := fmt.Sprintf(`
+item "main" {}
+include {
+  path   = "%s/shared/a.hcl"
+  sha256 = "%s"
+}
+`, server.URL, checksum(files["/shared/a.hcl"]))
+
+	config, diags := loadTestSource(t, src, URLResolverConfig{
+		Fetchers: fetchers,
+		CacheDir: cacheDir,
+	})
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+	if got, want := itemNames(config), "main,a,b"; got != want {
+		t.Errorf("wrong items\ngot:  %s\nwant: %s", got, want)
+	}
+	if got, want := atomic.LoadInt32(&requests), int32(2); got != want {
+		t.Errorf("wrong number of requests %d; want %d", got, want)
+	}
+
+	cached, err := ioutil.ReadFile(filepath.Join(cacheDir, checksum(files["/shared/a.hcl"])))
+	if err != nil {
+		t.Fatalf("content was not cached: %s", err)
+	}
+	if got, want := string(cached), files["/shared/a.hcl"]; got != want {
+		t.Errorf("wrong cached content\ngot:  %s\nwant: %s", got, want)
+	}
+
+	// The checksummed include is now served from the cache, while its own
+	// include, which has no checksum, is fetched again.
+	atomic.StoreInt32(&requests, 0)
+	_, diags = loadTestSource(t, src, URLResolverConfig{
+		Fetchers: fetchers,
+		CacheDir: cacheDir,
+	})
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+	if got, want := atomic.LoadInt32(&requests), int32(1); got != want {
+		t.Errorf("wrong number of requests with cache %d; want %d", got, want)
+	}
+}
+
+func TestURLResolverErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/a.hcl":
+			fmt.Fprint(w, `item "a" {}`)
+		case "/cycle.hcl":
+			fmt.Fprint(w, `include { path = "./cycle.hcl" }`)
+		case "/huge.hcl":
+			fmt.Fprint(w, strings.Repeat("#", maxHTTPFetchSize+1))
+		case "/local.hcl":
+			fmt.Fprint(w, `include { path = "file:///etc/hostname" }`)
+		case "/local-git.hcl":
+			fmt.Fprint(w, `include { path = "git::/srv/config.git//a.hcl" }`)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer server.Close()
+
+	tests := map[string]struct {
+		Include string
+		Summary string
+	}{
+		"checksum mismatch": {
+			fmt.Sprintf(`path = "%s/a.hcl"
+sha256 = "%s"`, server.URL, checksum("something else")),
+			"Include checksum mismatch",
+		},
+		"invalid checksum": {
+			fmt.Sprintf(`path = "%s/a.hcl"
+sha256 = "abc"`, server.URL),
+			"Invalid include checksum",
+		},
+		"not found": {
+			fmt.Sprintf(`path = "%s/missing.hcl"`, server.URL),
+			"Failed to fetch include",
+		},
+		"too large": {
+			fmt.Sprintf(`path = "%s/huge.hcl"`, server.URL),
+			"Failed to fetch include",
+		},
+		"cycle": {
+			fmt.Sprintf(`path = "%s/cycle.hcl"`, server.URL),
+			"Include cycle",
+		},
+		"local file from remote body": {
+			fmt.Sprintf(`path = "%s/local.hcl"`, server.URL),
+			"Untrusted include source",
+		},
+		"local repository from remote body": {
+			fmt.Sprintf(`path = "%s/local-git.hcl"`, server.URL),
+			"Untrusted include source",
+		},
+		"unsupported scheme": {
+			`path = "ftp://example.com/a.hcl"`,
+			"Unsupported include source",
+		},
+		"unsupported getter": {
+			`path = "s3::https://example.com/a.hcl"`,
+			"Unsupported include source",
+		},
+		"not a URL": {
+			`path = "a.hcl"`,
+			"Invalid include path",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			src := fmt.Sprintf("include {\n%s\n}\n", test.Include)
+			_, diags := loadTestSource(t, src, URLResolverConfig{
+				Fetchers: map[string]Fetcher{
+					"file": FileFetcher(),
+					"http": HTTPFetcher(server.Client()),
+					"git":  GitFetcher(),
+				},
+			})
+			if len(diags) != 1 {
+				t.Fatalf("wrong number of diagnostics %d; want 1\n%s", len(diags), diags.Error())
+			}
+			if got := diags[0].Summary; got != test.Summary {
+				t.Errorf("wrong summary %q; want %q", got, test.Summary)
+			}
+		})
+	}
+}
+
+func TestURLResolverChecksumAfterUnverified(t *testing.T) {
+	// The server's content changes after the first request, so the second
+	// include fetches content that matches its checksum, but the content the
+	// parser already has for the same URL does not.
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, `item "v%d" {}`, atomic.AddInt32(&requests, 1))
+	}))
+	defer server.Close()
+
+	src := fmt.Sprintf(`
+include { path = "%s/a.hcl" }
+include {
+  path   = "%s/a.hcl"
+  sha256 = "%s"
+}
+`, server.URL, server.URL, checksum(`item "v2" {}`))
+
+	_, diags := loadTestSource(t, src, URLResolverConfig{
+		Fetchers: map[string]Fetcher{
+			"http": HTTPFetcher(server.Client()),
+		},
+	})
+	if len(diags) != 1 {
+		t.Fatalf("wrong number of diagnostics %d; want 1\n%s", len(diags), diags.Error())
+	}
+	if got, want := diags[0].Summary, "Include checksum mismatch"; got != want {
+		t.Errorf("wrong summary %q; want %q", got, want)
+	}
+}
+
+func TestURLResolverFile(t *testing.T) {
+	files := map[string]string{
+		"shared/a.hcl": `item "a" {}`,
+		"local.hcl":    `item "local" {}`,
+	}
+	dir := t.TempDir()
+	for name, src := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	parser := hclparse.NewParser()
+	src := fmt.Sprintf(`
+include { path = "file://%s" }
+include { path = "local.hcl" }
+`, filepath.ToSlash(filepath.Join(dir, "shared", "a.hcl")))
+
+	config, diags := loadTestSource(t, src, URLResolverConfig{
+		Parser:   parser,
+		Fallback: FileResolver(dir, parser),
+	})
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+	if got, want := itemNames(config), "a,local"; got != want {
+		t.Errorf("wrong items\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+func TestURLResolverGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	files := map[string]string{
+		"shared/a.hcl": `item "a" {}`,
+	}
+	dir := t.TempDir()
+	for name, src := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s failed: %s\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	git("init", "--quiet")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "initial")
+	git("tag", "v1")
+
+	src := fmt.Sprintf(`include { path = "git::file://%s//shared/a.hcl?ref=v1" }`, filepath.ToSlash(dir))
+	config, diags := loadTestSource(t, src, URLResolverConfig{})
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+	if got, want := itemNames(config), "a"; got != want {
+		t.Errorf("wrong items\ngot:  %s\nwant: %s", got, want)
+	}
+
+	// A symlink committed to the repository must not lead outside of it.
+	secret := filepath.Join(t.TempDir(), "secret.hcl")
+	if err := ioutil.WriteFile(secret, []byte(`item "secret" {}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(secret, filepath.Join(dir, "shared", "link.hcl")); err != nil {
+		t.Skipf("cannot create symlink: %s", err)
+	}
+	git("add", ".")
+	git("commit", "--quiet", "-m", "link")
+
+	src = fmt.Sprintf(`include { path = "git::file://%s//shared/link.hcl" }`, filepath.ToSlash(dir))
+	_, diags = loadTestSource(t, src, URLResolverConfig{})
+	if len(diags) != 1 {
+		t.Fatalf("wrong number of diagnostics %d; want 1\n%s", len(diags), diags.Error())
+	}
+	if got, want := diags[0].Summary, "Failed to fetch include"; got != want {
+		t.Errorf("wrong summary %q; want %q", got, want)
+	}
+}
+
+func TestSourceOrigin(t *testing.T) {
+	tests := []struct {
+		Source string
+		Want   int
+	}{
+		{"main.hcl", originLocal},
+		{"/etc/app/main.hcl", originLocal},
+		{"file:///etc/app/main.hcl", originLocal},
+		{"git::file:///srv/config.git//a.hcl", originLocal},
+		{"git::/srv/config.git//a.hcl", originLocal},
+		{"http://example.com/a.hcl", originInsecure},
+		{"git::git://example.com/config.git//a.hcl", originInsecure},
+		{"https://example.com/a.hcl", originSecure},
+		{"HTTPS://example.com/a.hcl", originSecure},
+		{"git::https://example.com/config.git//a.hcl", originSecure},
+		{"git::git@example.com:config.git//a.hcl", originSecure},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Source, func(t *testing.T) {
+			if got := sourceOrigin(test.Source); got != test.Want {
+				t.Errorf("wrong origin %d; want %d", got, test.Want)
+			}
+		})
+	}
+}
+
+func TestSplitGitSource(t *testing.T) {
+	tests := []struct {
+		Source             string
+		Repo, SubPath, Ref string
+		Err                bool
+	}{
+		{"https://example.com/config.git//shared/a.hcl", "https://example.com/config.git", "shared/a.hcl", "", false},
+		{"https://example.com/config.git//a.hcl?ref=v1.2.0", "https://example.com/config.git", "a.hcl", "v1.2.0", false},
+		{"git@example.com:config.git//a.hcl", "git@example.com:config.git", "a.hcl", "", false},
+		{"https://example.com/config.git", "", "", "", true},
+		{"--upload-pack=touch /tmp/pwned//a.hcl", "", "", "", true},
+		{"https://example.com/config.git//a.hcl?ref=--upload-pack=x", "", "", "", true},
+		{"https://example.com/config.git//?ref=v1", "", "", "", true},
+		{"https://example.com/config.git///etc/passwd", "", "", "", true},
+		{"https://example.com/config.git//shared/../../a.hcl", "", "", "", true},
+		{"https://example.com/config.git//shared\\..\\a.hcl", "", "", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Source, func(t *testing.T) {
+			repo, subPath, ref, err := splitGitSource(test.Source)
+			if (err != nil) != test.Err {
+				t.Fatalf("wrong error %v", err)
+			}
+			if repo != test.Repo || subPath != test.SubPath || ref != test.Ref {
+				t.Errorf("wrong result %q, %q, %q; want %q, %q, %q", repo, subPath, ref, test.Repo, test.SubPath, test.Ref)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+// Package depgraph contains the dependency graph algorithms shared by the
+// extensions that evaluate interdependent items in dependency order.
+//
+// A graph is given as a slice with an element for each node, giving the
+// indices of the nodes that it depends on.
+package depgraph
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Components returns the strongly-connected components of the given graph in
+// an order where each component appears only after all of the components it
+// depends on. The members of each component are in ascending index order.
+//
+// Each component that contains only one node and no dependency on itself is
+// free of cycles, while all other components each contain at least one cycle.
+func Components(deps [][]int) [][]int {
+	// This is Tarjan's strongly-connected components algorithm, which
+	// conveniently produces the components in reverse topological order
+	// of the graph, which for our "depends on" edges is the order in which
+	// the nodes must be evaluated.
+	var ret [][]int
+	var stack []int
+	index := make([]int, len(deps))
+	lowLink := make([]int, len(deps))
+	onStack := make([]bool, len(deps))
+	for i := range index {
+		index[i] = -1
+	}
+	nextIndex := 0
+
+	var visit func(n int)
+	visit = func(n int) {
+		index[n] = nextIndex
+		lowLink[n] = nextIndex
+		nextIndex++
+		stack = append(stack, n)
+		onStack[n] = true
+
+		for _, dep := range deps[n] {
+			if index[dep] == -1 {
+				visit(dep)
+				if lowLink[dep] < lowLink[n] {
+					lowLink[n] = lowLink[dep]
+				}
+			} else if onStack[dep] && index[dep] < lowLink[n] {
+				lowLink[n] = index[dep]
+			}
+		}
+
+		if lowLink[n] == index[n] {
+			var component []int
+			for {
+				member := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[member] = false
+				component = append(component, member)
+				if member == n {
+					break
+				}
+			}
+			// The members were popped in reverse order of discovery, so
+			// we'll put them back in index order.
+			for i := 1; i < len(component); i++ {
+				for j := i; j > 0 && component[j] < component[j-1]; j-- {
+					component[j], component[j-1] = component[j-1], component[j]
+				}
+			}
+			ret = append(ret, component)
+		}
+	}
+
+	for n := range deps {
+		if index[n] == -1 {
+			visit(n)
+		}
+	}
+
+	return ret
+}
+
+// FindCycle returns a sequence of node indices forming a cycle within the
+// given strongly-connected component, starting and ending with the same
+// node, or nil if the component has no cycles.
+func FindCycle(component []int, deps [][]int) []int {
+	start := component[0]
+	if len(component) == 1 {
+		for _, dep := range deps[start] {
+			if dep == start {
+				return []int{start, start}
+			}
+		}
+		return nil
+	}
+
+	inComponent := make(map[int]bool, len(component))
+	for _, n := range component {
+		inComponent[n] = true
+	}
+
+	// A breadth-first search from the start back to itself finds the
+	// shortest cycle through it, which is the easiest one to understand in
+	// an error message.
+	prev := make(map[int]int)
+	queue := []int{start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, dep := range deps[current] {
+			if !inComponent[dep] {
+				continue
+			}
+			if dep == start {
+				cycle := []int{start}
+				for n := current; n != start; n = prev[n] {
+					cycle = append(cycle, n)
+				}
+				cycle = append(cycle, start)
+				// We built the cycle backwards, so we'll reverse it.
+				for i, j := 0, len(cycle)-1; i < j; i, j = i+1, j-1 {
+					cycle[i], cycle[j] = cycle[j], cycle[i]
+				}
+				return cycle
+			}
+			if _, seen := prev[dep]; !seen {
+				prev[dep] = current
+				queue = append(queue, dep)
+			}
+		}
+	}
+
+	// Should never get here, because every strongly-connected component
+	// with more than one member contains a cycle through every member.
+	return nil
+}
+
+// CycleChain describes the given cycle for the detail of a diagnostic, as a
+// list with an item for each step produced by the given function.
+func CycleChain(cycle []int, step func(from, to int) string) string {
+	var buf strings.Builder
+	for i := 0; i < len(cycle)-1; i++ {
+		fmt.Fprintf(&buf, "\n  - %s", step(cycle[i], cycle[i+1]))
+	}
+	return buf.String()
+}
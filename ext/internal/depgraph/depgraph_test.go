@@ -0,0 +1,31 @@
+package depgraph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestComponents(t *testing.T) {
+	// 0 depends on 1, 1 and 2 depend on each other, and 3 depends on itself.
+	deps := [][]int{
+		{1},
+		{2},
+		{1},
+		{3},
+	}
+
+	got := Components(deps)
+	want := [][]int{{1, 2}, {0}, {3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrong components\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	cycles := make([][]int, len(got))
+	for i, component := range got {
+		cycles[i] = FindCycle(component, deps)
+	}
+	wantCycles := [][]int{{1, 2, 1}, nil, {3, 3}}
+	if !reflect.DeepEqual(cycles, wantCycles) {
+		t.Fatalf("wrong cycles\ngot:  %#v\nwant: %#v", cycles, wantCycles)
+	}
+}
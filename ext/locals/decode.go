@@ -0,0 +1,281 @@
+package locals
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl2/ext/internal/depgraph"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func decodeLocals(body hcl.Body, blockType string, rootName string, ctx *hcl.EvalContext) (cty.Value, hcl.Body, hcl.Diagnostics) {
+	schema := &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{
+				Type: blockType,
+			},
+		},
+	}
+
+	content, remain, diags := body.PartialContent(schema)
+	if diags.HasErrors() {
+		return cty.DynamicVal, remain, diags
+	}
+
+	attrs := make(hcl.Attributes)
+	for _, block := range content.Blocks {
+		blockAttrs, blockDiags := block.Body.JustAttributes()
+		diags = append(diags, blockDiags...)
+		for name, attr := range blockAttrs {
+			if existing, exists := attrs[name]; exists {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Duplicate named value",
+					Detail: fmt.Sprintf(
+						"A named value %q was already declared at %s. Each named value must have a unique name.",
+						name, existing.NameRange,
+					),
+					Subject: &attr.NameRange,
+				})
+				continue
+			}
+			attrs[name] = attr
+		}
+	}
+
+	vals, valDiags := evalLocals(attrs, rootName, ctx)
+	diags = append(diags, valDiags...)
+	return vals, remain, diags
+}
+
+func evalLocals(attrs hcl.Attributes, rootName string, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	deps, diags := findDependencies(attrs, rootName)
+
+	names, graph := dependencyGraph(deps)
+
+	vals := make(map[string]cty.Value, len(attrs))
+	for _, level := range dependencyLevels(depgraph.Components(graph), graph) {
+		// All of the values that those in this level depend on are in
+		// earlier levels, so they can share an object of the values so far.
+		// NewChild is safe to call on a nil context, producing a context
+		// with no parent.
+		evalCtx := ctx.NewChild()
+		evalCtx.Variables = map[string]cty.Value{
+			rootName: cty.ObjectVal(vals),
+		}
+
+		levelVals := make(map[string]cty.Value)
+		for _, component := range level {
+			if cycle := depgraph.FindCycle(component, graph); cycle != nil {
+				diags = append(diags, cycleDiagnostic(cycle, names, rootName, deps))
+				for _, idx := range component {
+					levelVals[names[idx]] = cty.DynamicVal
+				}
+				continue
+			}
+
+			name := names[component[0]]
+			if deps[name].invalid {
+				// We already reported an error for a bad reference in this
+				// expression, so we'll skip evaluating it to avoid reporting
+				// redundant errors about the same problem.
+				levelVals[name] = cty.DynamicVal
+				continue
+			}
+
+			val, valDiags := attrs[name].Expr.Value(evalCtx)
+			diags = append(diags, valDiags...)
+			if valDiags.HasErrors() {
+				val = cty.DynamicVal
+			}
+			levelVals[name] = val
+		}
+
+		for name, val := range levelVals {
+			vals[name] = val
+		}
+	}
+
+	return cty.ObjectVal(vals), diags
+}
+
+// dependencyLevels groups the given strongly-connected components, which
+// must be in dependency order, into levels such that each component depends
+// only on components in earlier levels. The components in each level keep
+// their relative order.
+func dependencyLevels(components [][]int, graph [][]int) [][][]int {
+	var ret [][][]int
+	nodeLevels := make([]int, len(graph))
+	for _, component := range components {
+		inComponent := make(map[int]bool, len(component))
+		for _, n := range component {
+			inComponent[n] = true
+		}
+		level := 0
+		for _, n := range component {
+			for _, dep := range graph[n] {
+				if !inComponent[dep] && nodeLevels[dep]+1 > level {
+					level = nodeLevels[dep] + 1
+				}
+			}
+		}
+		for _, n := range component {
+			nodeLevels[n] = level
+		}
+		if level == len(ret) {
+			ret = append(ret, nil)
+		}
+		ret[level] = append(ret[level], component)
+	}
+	return ret
+}
+
+// dependencies describes the named values that a particular named value
+// refers to in its expression.
+type dependencies struct {
+	attr *hcl.Attribute
+
+	// refs maps the names of the named values that are referenced to the
+	// source range of the first reference to each one.
+	refs map[string]hcl.Range
+
+	// invalid is set if the expression contains at least one reference that
+	// could not be resolved to a named value.
+	invalid bool
+}
+
+func findDependencies(attrs hcl.Attributes, rootName string) (map[string]*dependencies, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	ret := make(map[string]*dependencies, len(attrs))
+
+	for _, name := range sortedNames(attrs) {
+		attr := attrs[name]
+		deps := &dependencies{
+			attr: attr,
+			refs: make(map[string]hcl.Range),
+		}
+		ret[name] = deps
+
+		for _, traversal := range attr.Expr.Variables() {
+			if traversal.RootName() != rootName {
+				continue
+			}
+
+			refName, ok := referencedName(traversal)
+			if !ok {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid reference",
+					Detail: fmt.Sprintf(
+						"A reference to a named value must include the name of the value, like %s.example.",
+						rootName,
+					),
+					Subject: traversal.SourceRange().Ptr(),
+				})
+				deps.invalid = true
+				continue
+			}
+
+			if _, exists := attrs[refName]; !exists {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Reference to undeclared named value",
+					Detail: fmt.Sprintf(
+						"A named value %q has not been declared.",
+						refName,
+					),
+					Subject: traversal.SourceRange().Ptr(),
+				})
+				deps.invalid = true
+				continue
+			}
+
+			if _, exists := deps.refs[refName]; !exists {
+				deps.refs[refName] = traversal.SourceRange()
+			}
+		}
+	}
+
+	return ret, diags
+}
+
+// referencedName returns the name of the named value that the given
+// traversal refers to, which is the attribute or string key given in the
+// step immediately after the root name.
+func referencedName(traversal hcl.Traversal) (string, bool) {
+	if len(traversal) < 2 {
+		return "", false
+	}
+
+	switch step := traversal[1].(type) {
+	case hcl.TraverseAttr:
+		return step.Name, true
+	case hcl.TraverseIndex:
+		if !step.Key.IsKnown() || step.Key.IsNull() || step.Key.Type() != cty.String {
+			return "", false
+		}
+		return step.Key.AsString(), true
+	default:
+		return "", false
+	}
+}
+
+// dependencyGraph returns the names of the named values in sorted order,
+// along with the graph of the dependencies between them in the form used by
+// package depgraph, with each name represented by its index.
+func dependencyGraph(deps map[string]*dependencies) ([]string, [][]int) {
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	indices := make(map[string]int, len(names))
+	for i, name := range names {
+		indices[name] = i
+	}
+
+	graph := make([][]int, len(names))
+	for i, name := range names {
+		for _, ref := range sortedRefs(deps[name].refs) {
+			graph[i] = append(graph[i], indices[ref])
+		}
+	}
+	return names, graph
+}
+
+func cycleDiagnostic(cycle []int, names []string, rootName string, deps map[string]*dependencies) *hcl.Diagnostic {
+	chain := depgraph.CycleChain(cycle, func(from, to int) string {
+		return fmt.Sprintf("%s.%s refers to %s.%s at %s", rootName, names[from], rootName, names[to], deps[names[from]].refs[names[to]])
+	})
+
+	first := deps[names[cycle[0]]]
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Dependency cycle between named values",
+		Detail: fmt.Sprintf(
+			"The named value %s.%s depends on itself through the following chain of references:%s",
+			rootName, names[cycle[0]], chain,
+		),
+		Subject: first.attr.NameRange.Ptr(),
+		Context: first.attr.Range.Ptr(),
+	}
+}
+
+func sortedNames(attrs hcl.Attributes) []string {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedRefs(refs map[string]hcl.Range) []string {
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,187 @@
+package locals
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestDecodeLocals(t *testing.T) {
+	tests := []struct {
+		src       string
+		baseCtx   *hcl.EvalContext
+		want      cty.Value
+		diagCount int
+	}{
+		{
+			`
+locals {
+  greeting = "Hello, ${local.name}!"
+  name     = "world"
+}
+`,
+			nil,
+			cty.ObjectVal(map[string]cty.Value{
+				"greeting": cty.StringVal("Hello, world!"),
+				"name":     cty.StringVal("world"),
+			}),
+			0,
+		},
+		{
+			`
+locals {
+  a = local.b + 1
+}
+locals {
+  b = local.c * 2
+  c = base
+}
+`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"base": cty.NumberIntVal(3),
+				},
+			},
+			cty.ObjectVal(map[string]cty.Value{
+				"a": cty.NumberIntVal(7),
+				"b": cty.NumberIntVal(6),
+				"c": cty.NumberIntVal(3),
+			}),
+			0,
+		},
+		{
+			`
+locals {
+  a = local.b
+  b = local.c
+  c = local.a
+  d = "ok"
+  e = local.a
+}
+`,
+			nil,
+			cty.ObjectVal(map[string]cty.Value{
+				"a": cty.DynamicVal,
+				"b": cty.DynamicVal,
+				"c": cty.DynamicVal,
+				"d": cty.StringVal("ok"),
+				"e": cty.DynamicVal,
+			}),
+			1, // dependency cycle
+		},
+		{
+			`
+locals {
+  a = local.a
+}
+`,
+			nil,
+			cty.ObjectVal(map[string]cty.Value{
+				"a": cty.DynamicVal,
+			}),
+			1, // self-reference
+		},
+		{
+			`
+locals {
+  a = local.nope
+  b = local
+}
+`,
+			nil,
+			cty.ObjectVal(map[string]cty.Value{
+				"a": cty.DynamicVal,
+				"b": cty.DynamicVal,
+			}),
+			2, // undeclared name and invalid reference
+		},
+		{
+			`
+locals {
+  a = 1
+}
+locals {
+  a = 2
+}
+`,
+			nil,
+			cty.ObjectVal(map[string]cty.Value{
+				"a": cty.NumberIntVal(1),
+			}),
+			1, // duplicate name
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%02d", i), func(t *testing.T) {
+			f, diags := hclsyntax.ParseConfig([]byte(test.src), "config", hcl.Pos{Line: 1, Column: 1})
+			if f == nil || f.Body == nil {
+				t.Fatalf("got nil file or body")
+			}
+			if diags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", diags.Error())
+			}
+
+			got, remain, diags := DecodeLocals(f.Body, "locals", "local", test.baseCtx)
+			if remain == nil {
+				t.Errorf("got nil remain body")
+			}
+			if len(diags) != test.diagCount {
+				t.Errorf("wrong number of diagnostics %d; want %d", len(diags), test.diagCount)
+				for _, diag := range diags {
+					t.Logf("- %s", diag.Error())
+				}
+			}
+
+			if !got.RawEquals(test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodeLocalsCycleDetail(t *testing.T) {
+	src := `
+locals {
+  a = local.b
+  b = local.a
+}
+`
+	f, _ := hclsyntax.ParseConfig([]byte(src), "config", hcl.Pos{Line: 1, Column: 1})
+	_, _, diags := DecodeLocals(f.Body, "locals", "local", nil)
+	if len(diags) != 1 {
+		t.Fatalf("wrong number of diagnostics %d; want 1", len(diags))
+	}
+
+	want := `The named value local.a depends on itself through the following chain of references:
+  - local.a refers to local.b at config:3,7-14
+  - local.b refers to local.a at config:4,7-14`
+	if got := diags[0].Detail; got != want {
+		t.Errorf("wrong detail\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	if got, want := diags[0].Subject.String(), "config:3,3-4"; got != want {
+		t.Errorf("wrong subject %s; want %s", got, want)
+	}
+}
+
+func TestDependencyLevels(t *testing.T) {
+	// 0 and 1 have no dependencies, 2 depends on 0, 3 depends on 1 and 2,
+	// and 4 depends on 1.
+	graph := [][]int{
+		{},
+		{},
+		{0},
+		{1, 2},
+		{1},
+	}
+	components := [][]int{{0}, {1}, {2}, {3}, {4}}
+
+	got := fmt.Sprint(dependencyLevels(components, graph))
+	want := "[[[0] [1]] [[2] [4]] [[3]]]"
+	if got != want {
+		t.Errorf("wrong levels\ngot:  %s\nwant: %s", got, want)
+	}
+}
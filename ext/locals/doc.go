@@ -0,0 +1,25 @@
+// Package locals implements a HCL extension that allows a configuration to
+// declare named values that can be referred to from elsewhere in the same
+// configuration, including from other named values.
+//
+// Using this extension requires some integration effort on the part of the
+// calling application, to pass the resulting values into a HCL evaluation
+// context after processing.
+//
+// The declaration syntax looks like this:
+//
+//     locals {
+//       greeting = "Hello, ${local.name}!"
+//       name     = "world"
+//     }
+//
+// Named values may refer to one another in any order, using the traversal
+// syntax with a root name chosen by the calling application. The values are
+// evaluated in an order that ensures each value is evaluated only after all
+// of the values it depends on, and dependency cycles are reported as errors.
+//
+// The block type "locals" and the root name "local" used in the example
+// above are not fixed by this package. The calling application chooses both
+// names and passes them to DecodeLocals, so that they need not conflict with
+// existing names in the application.
+package locals
@@ -0,0 +1,36 @@
+package locals
+
+import (
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// DecodeLocals looks for blocks of the given type in the given body and
+// interprets each attribute within them as a named value, whose expression
+// may refer to other named values via traversals starting with the given
+// root name.
+//
+// On success, the result is an object value with one attribute per named
+// value, suitable for inclusion in the Variables map of an hcl.EvalContext
+// under the given root name, along with a new body that represents the
+// remaining content of the given body which can be used for further
+// processing.
+//
+// The given context, which may be nil, provides the variables and functions
+// available to the named value expressions, in addition to the named values
+// themselves.
+//
+// If the returned diagnostics set has errors then the object may contain
+// unknown values for any named values that could not be evaluated, and the
+// remain body may be nil.
+func DecodeLocals(body hcl.Body, blockType string, rootName string, ctx *hcl.EvalContext) (vals cty.Value, remain hcl.Body, diags hcl.Diagnostics) {
+	return decodeLocals(body, blockType, rootName, ctx)
+}
+
+// EvalLocals is a lower-level variant of DecodeLocals that accepts
+// attributes that were already extracted from one or more bodies by the
+// calling application, and evaluates them as named values in the same way
+// as DecodeLocals.
+func EvalLocals(attrs hcl.Attributes, rootName string, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	return evalLocals(attrs, rootName, ctx)
+}
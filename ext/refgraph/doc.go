@@ -0,0 +1,21 @@
+// Package refgraph analyzes the references between the top-level blocks of
+// a HCL body, producing a dependency graph that applications can use to
+// decide in which order to evaluate those blocks.
+//
+// The calling application describes which block types participate in the
+// graph and how each one is addressed in expressions. For example, given a
+// block type "resource" with two labels, a block like this:
+//
+//     resource "instance" "web" {
+//       subnet_id = resource.subnet.main.id
+//     }
+//
+// has the address resource.instance.web and depends on the block whose
+// address is resource.subnet.main.
+//
+// References are found using the Variables method of each expression in the
+// block's body, including those in nested blocks and in the "for_each" and
+// "labels" arguments of any dynamic blocks as defined by the dynblock
+// extension. The structure of the nested blocks must therefore be described
+// using either an hcldec specification or a struct type annotated for gohcl.
+package refgraph
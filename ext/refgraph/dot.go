@@ -0,0 +1,39 @@
+package refgraph
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteDOT writes a description of the graph to the given writer in the
+// DOT language used by Graphviz, with an edge from each node to each of the
+// nodes it depends on.
+//
+// Nodes are identified by their position in the graph and labelled with
+// their addresses, since distinct addresses may have the same string form.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+
+	buf.WriteString("digraph {\n")
+	for i, node := range g.nodes {
+		fmt.Fprintf(buf, "  n%d [label=%s];\n", i, dotQuote(node.Addr.String()))
+	}
+	for from, deps := range g.deps {
+		for _, to := range deps {
+			fmt.Fprintf(buf, "  n%d -> n%d;\n", from, to)
+		}
+	}
+	buf.WriteString("}\n")
+
+	return buf.Flush()
+}
+
+// dotEscaper escapes the characters that are special within a DOT quoted
+// string. Unlike Go string literals, DOT has no other escape sequences.
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func dotQuote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
@@ -0,0 +1,265 @@
+package refgraph
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Address identifies a block in the graph, as the reference root name of
+// its block type followed by its labels.
+type Address []string
+
+// String returns the address in the dotted form used to refer to it in
+// expressions, like resource.instance.web. This is for display only, since
+// labels may themselves contain dots.
+func (a Address) String() string {
+	return strings.Join(a, ".")
+}
+
+// key returns a string that uniquely identifies the address, for use as a
+// map key.
+func (a Address) key() string {
+	return strings.Join(a, "\x00")
+}
+
+// hasPrefix returns true if the given address is a prefix of the receiver.
+func (a Address) hasPrefix(prefix Address) bool {
+	if len(prefix) > len(a) {
+		return false
+	}
+	for i := range prefix {
+		if a[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Node is a single block in the graph.
+type Node struct {
+	Addr  Address
+	Block *hcl.Block
+
+	// References are the references to other blocks found in the body of
+	// this node's block, in the order they were found.
+	References []Reference
+
+	index int
+}
+
+// Reference is a traversal within a block's body that refers to one or
+// more other blocks.
+type Reference struct {
+	Traversal hcl.Traversal
+
+	// Targets are the nodes that the traversal refers to. A traversal that
+	// gives only some of the labels of a block type refers to all of the
+	// blocks whose addresses start with the given labels.
+	Targets []*Node
+}
+
+// Graph is a dependency graph between blocks, where a block depends on each
+// of the blocks that it refers to.
+type Graph struct {
+	nodes  []*Node
+	byAddr map[string]*Node
+
+	// deps has an element for each node, giving the indices of the nodes
+	// it depends on in the order they were first referenced.
+	deps [][]int
+}
+
+// Build finds the blocks of the given types in the given body and analyzes
+// the references between them to produce a dependency graph.
+//
+// Blocks of other types are ignored and returned in the remain body, which
+// can be used for further processing.
+//
+// Error diagnostics are returned for duplicate block addresses and for
+// references to blocks that do not exist. Dependency cycles are not
+// detected during Build, and are instead reported by methods of the graph
+// such as TopologicalOrder.
+func Build(body hcl.Body, blockTypes []BlockType) (*Graph, hcl.Body, hcl.Diagnostics) {
+	schema := &hcl.BodySchema{
+		Blocks: make([]hcl.BlockHeaderSchema, len(blockTypes)),
+	}
+	typesByName := make(map[string]*BlockType, len(blockTypes))
+	typesByRoot := make(map[string][]*BlockType, len(blockTypes))
+	for i := range blockTypes {
+		bt := &blockTypes[i]
+		schema.Blocks[i] = hcl.BlockHeaderSchema{
+			Type:       bt.Name,
+			LabelNames: bt.LabelNames,
+		}
+		typesByName[bt.Name] = bt
+		typesByRoot[bt.refRoot()] = append(typesByRoot[bt.refRoot()], bt)
+	}
+
+	content, remain, diags := body.PartialContent(schema)
+
+	g := &Graph{
+		byAddr: make(map[string]*Node, len(content.Blocks)),
+	}
+	for _, block := range content.Blocks {
+		bt := typesByName[block.Type]
+		addr := make(Address, 0, len(block.Labels)+1)
+		addr = append(addr, bt.refRoot())
+		addr = append(addr, block.Labels...)
+
+		if existing, exists := g.byAddr[addr.key()]; exists {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate block",
+				Detail: fmt.Sprintf(
+					"A block with the address %s was already declared at %s. Each block must have a unique address.",
+					addr, existing.Block.DefRange,
+				),
+				Subject: &block.DefRange,
+			})
+			continue
+		}
+
+		node := &Node{
+			Addr:  addr,
+			Block: block,
+			index: len(g.nodes),
+		}
+		g.nodes = append(g.nodes, node)
+		g.byAddr[addr.key()] = node
+	}
+
+	g.deps = make([][]int, len(g.nodes))
+	for _, node := range g.nodes {
+		bt := typesByName[node.Block.Type]
+		if bt.Variables == nil {
+			continue
+		}
+
+		seen := map[int]bool{}
+		for _, traversal := range bt.Variables(node.Block.Body) {
+			candidates, isBlockRef := typesByRoot[traversal.RootName()]
+			if !isBlockRef {
+				continue
+			}
+
+			targets := g.referenceTargets(traversal, candidates)
+			if len(targets) == 0 {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Reference to undeclared block",
+					Detail: fmt.Sprintf(
+						"There is no block matching the reference %s.",
+						referencePrefix(traversal, candidates),
+					),
+					Subject: traversal.SourceRange().Ptr(),
+				})
+				continue
+			}
+
+			node.References = append(node.References, Reference{
+				Traversal: traversal,
+				Targets:   targets,
+			})
+			for _, target := range targets {
+				if !seen[target.index] {
+					seen[target.index] = true
+					g.deps[node.index] = append(g.deps[node.index], target.index)
+				}
+			}
+		}
+	}
+
+	return g, remain, diags
+}
+
+// referenceTargets returns the nodes that the given traversal refers to,
+// given the block types that share the traversal's root name.
+func (g *Graph) referenceTargets(traversal hcl.Traversal, candidates []*BlockType) []*Node {
+	prefix := referencePrefix(traversal, candidates)
+
+	var ret []*Node
+	for _, node := range g.nodes {
+		matchesType := false
+		for _, bt := range candidates {
+			if bt.Name == node.Block.Type {
+				matchesType = true
+				break
+			}
+		}
+		if matchesType && node.Addr.hasPrefix(prefix) {
+			ret = append(ret, node)
+		}
+	}
+	return ret
+}
+
+// referencePrefix returns the leading portion of the given traversal that
+// identifies blocks, which is its root name followed by as many attribute
+// or string index steps as the candidate block types have labels.
+func referencePrefix(traversal hcl.Traversal, candidates []*BlockType) Address {
+	maxLabels := 0
+	for _, bt := range candidates {
+		if len(bt.LabelNames) > maxLabels {
+			maxLabels = len(bt.LabelNames)
+		}
+	}
+
+	ret := Address{traversal.RootName()}
+	for _, step := range traversal[1:] {
+		if len(ret) > maxLabels {
+			break
+		}
+		switch step := step.(type) {
+		case hcl.TraverseAttr:
+			ret = append(ret, step.Name)
+		case hcl.TraverseIndex:
+			if !step.Key.IsKnown() || step.Key.IsNull() || step.Key.Type() != cty.String {
+				return ret
+			}
+			ret = append(ret, step.Key.AsString())
+		default:
+			return ret
+		}
+	}
+	return ret
+}
+
+// Nodes returns all of the nodes in the graph, in the order their blocks
+// were declared.
+func (g *Graph) Nodes() []*Node {
+	return g.nodes
+}
+
+// Node returns the node with the given address, or nil if there is no such
+// node.
+func (g *Graph) Node(addr Address) *Node {
+	return g.byAddr[addr.key()]
+}
+
+// Dependencies returns the nodes that the given node refers to, in the order
+// they are first referenced.
+func (g *Graph) Dependencies(node *Node) []*Node {
+	ret := make([]*Node, len(g.deps[node.index]))
+	for i, idx := range g.deps[node.index] {
+		ret[i] = g.nodes[idx]
+	}
+	return ret
+}
+
+// Dependents returns the nodes that refer to the given node, in the order
+// their blocks were declared.
+func (g *Graph) Dependents(node *Node) []*Node {
+	var ret []*Node
+	for from, deps := range g.deps {
+		for _, to := range deps {
+			if to == node.index {
+				ret = append(ret, g.nodes[from])
+				break
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,337 @@
+package refgraph
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/hashicorp/hcl2/hcldec"
+)
+
+func parseTestBody(t *testing.T, src string) hcl.Body {
+	t.Helper()
+	f, diags := hclsyntax.ParseConfig([]byte(src), "config", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatalf("unexpected parse errors: %s", diags.Error())
+	}
+	return f.Body
+}
+
+func addrStrings(nodes []*Node) []string {
+	ret := make([]string, len(nodes))
+	for i, node := range nodes {
+		ret[i] = node.Addr.String()
+	}
+	return ret
+}
+
+func assertStrings(t *testing.T, got, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+		}
+	}
+}
+
+var testSpecBlockTypes = []BlockType{
+	{
+		Name:       "resource",
+		LabelNames: []string{"type", "name"},
+		Variables: SpecVariables(&hcldec.ObjectSpec{
+			"value": &hcldec.AttrSpec{
+				Name: "value",
+			},
+			"nested": &hcldec.BlockListSpec{
+				TypeName: "nested",
+				Nested: &hcldec.AttrSpec{
+					Name: "value",
+				},
+			},
+		}),
+	},
+	{
+		Name:       "data",
+		LabelNames: []string{"name"},
+		Variables: SpecVariables(&hcldec.AttrSpec{
+			Name: "value",
+		}),
+	},
+}
+
+func TestBuildSpec(t *testing.T) {
+	body := parseTestBody(t, `
+resource "a" "one" {
+  value = data.input.value
+}
+resource "b" "two" {
+  nested {
+    value = resource.a.one.id
+  }
+  dynamic "nested" {
+    for_each = resource.c
+    content {
+      value = nested.value
+    }
+  }
+}
+resource "c" "three" {
+  value = var.external
+}
+data "input" {
+  value = "hello"
+}
+other {
+}
+`)
+
+	g, remain, diags := Build(body, testSpecBlockTypes)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+
+	content, _ := remain.Content(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "other"}},
+	})
+	if got, want := len(content.Blocks), 1; got != want {
+		t.Errorf("wrong number of remaining blocks %d; want %d", got, want)
+	}
+
+	assertStrings(t, addrStrings(g.Nodes()), []string{
+		"resource.a.one",
+		"resource.b.two",
+		"resource.c.three",
+		"data.input",
+	})
+
+	two := g.Node(Address{"resource", "b", "two"})
+	if two == nil {
+		t.Fatalf("no node for resource.b.two")
+	}
+	assertStrings(t, addrStrings(g.Dependencies(two)), []string{
+		"resource.c.three", // from the dynamic block's for_each
+		"resource.a.one",
+	})
+
+	input := g.Node(Address{"data", "input"})
+	assertStrings(t, addrStrings(g.Dependents(input)), []string{
+		"resource.a.one",
+	})
+
+	order, diags := g.TopologicalOrder()
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+	assertStrings(t, addrStrings(order), []string{
+		"data.input",
+		"resource.a.one",
+		"resource.c.three",
+		"resource.b.two",
+	})
+
+	if cycles := g.Cycles(); len(cycles) != 0 {
+		t.Errorf("unexpected cycles: %#v", cycles)
+	}
+}
+
+func TestBuildUndeclared(t *testing.T) {
+	body := parseTestBody(t, `
+resource "a" "one" {
+  value = resource.a.two
+}
+`)
+
+	_, _, diags := Build(body, testSpecBlockTypes)
+	if len(diags) != 1 {
+		t.Fatalf("wrong number of diagnostics %d; want 1", len(diags))
+	}
+	if got, want := diags[0].Summary, "Reference to undeclared block"; got != want {
+		t.Errorf("wrong summary %q; want %q", got, want)
+	}
+	if got, want := diags[0].Subject.String(), "config:3,11-25"; got != want {
+		t.Errorf("wrong subject %s; want %s", got, want)
+	}
+}
+
+func TestBuildDottedLabels(t *testing.T) {
+	// Labels containing dots must not make distinct addresses collide.
+	body := parseTestBody(t, `
+resource "a.b" "c" {
+}
+resource "a" "b.c" {
+}
+`)
+
+	g, _, diags := Build(body, testSpecBlockTypes)
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %s", diags.Error())
+	}
+	if got, want := len(g.Nodes()), 2; got != want {
+		t.Fatalf("got %d nodes; want %d", got, want)
+	}
+	if got := g.Node(Address{"resource", "a", "b.c"}); got != g.Nodes()[1] {
+		t.Errorf("wrong node for resource.a.b.c")
+	}
+}
+
+func TestCycles(t *testing.T) {
+	body := parseTestBody(t, `
+resource "a" "one" {
+  value = resource.a.two.id
+}
+resource "a" "two" {
+  value = data.three.value
+}
+data "three" {
+  value = resource.a.one.id
+}
+data "four" {
+  value = data.four.value
+}
+`)
+
+	g, _, diags := Build(body, testSpecBlockTypes)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+
+	cycles := g.Cycles()
+	if got, want := len(cycles), 2; got != want {
+		t.Fatalf("wrong number of cycles %d; want %d", got, want)
+	}
+	assertStrings(t, addrStrings(cycles[0]), []string{
+		"resource.a.one",
+		"resource.a.two",
+		"data.three",
+		"resource.a.one",
+	})
+	assertStrings(t, addrStrings(cycles[1]), []string{
+		"data.four",
+		"data.four",
+	})
+
+	_, diags = g.TopologicalOrder()
+	if got, want := len(diags), 2; got != want {
+		t.Fatalf("wrong number of diagnostics %d; want %d", got, want)
+	}
+	wantDetail := `The block resource.a.one depends on itself through the following chain of references:
+  - resource.a.one refers to resource.a.two at config:3,11-28
+  - resource.a.two refers to data.three at config:6,11-27
+  - data.three refers to resource.a.one at config:9,11-28`
+	if got := diags[0].Detail; got != wantDetail {
+		t.Errorf("wrong detail\ngot:\n%s\nwant:\n%s", got, wantDetail)
+	}
+}
+
+func TestBuildGoStruct(t *testing.T) {
+	type Nested struct {
+		Value hcl.Expression `hcl:"value"`
+	}
+	type Service struct {
+		Name    string         `hcl:"name,label"`
+		Command hcl.Expression `hcl:"command"`
+		Nested  []Nested       `hcl:"nested,block"`
+	}
+	type Config struct {
+		Services []Service `hcl:"service,block"`
+	}
+
+	body := parseTestBody(t, `
+service "web" {
+  command = "serve"
+  nested {
+    value = service.db.port
+  }
+}
+service "db" {
+  command = "db"
+}
+`)
+
+	blockTypes := BlockTypesForGoStruct(&Config{})
+	if got, want := len(blockTypes), 1; got != want {
+		t.Fatalf("wrong number of block types %d; want %d", got, want)
+	}
+
+	g, _, diags := Build(body, blockTypes)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+
+	order, diags := g.TopologicalOrder()
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+	assertStrings(t, addrStrings(order), []string{
+		"service.db",
+		"service.web",
+	})
+}
+
+func TestWriteDOT(t *testing.T) {
+	body := parseTestBody(t, `
+resource "a" "one" {
+  value = data.input.value
+}
+data "input" {
+  value = "hello"
+}
+`)
+
+	g, _, diags := Build(body, testSpecBlockTypes)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+
+	var buf bytes.Buffer
+	if err := g.WriteDOT(&buf); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := `digraph {
+  n0 [label="resource.a.one"];
+  n1 [label="data.input"];
+  n0 -> n1;
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteDOTAmbiguousAddresses(t *testing.T) {
+	// Distinct addresses with the same string form must remain distinct
+	// nodes, and quotes and backslashes in labels must be escaped for DOT.
+	body := parseTestBody(t, `
+resource "a.b" "c" {
+}
+resource "a" "b.c" {
+}
+resource "q\"x" "y\\z" {
+}
+`)
+
+	g, _, diags := Build(body, testSpecBlockTypes)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+
+	var buf bytes.Buffer
+	if err := g.WriteDOT(&buf); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := `digraph {
+  n0 [label="resource.a.b.c"];
+  n1 [label="resource.a.b.c"];
+  n2 [label="resource.q\"x.y\\z"];
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,82 @@
+package refgraph
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl2/ext/internal/depgraph"
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// TopologicalOrder returns all of the nodes in the graph in an order where
+// each node appears only after all of the nodes it depends on, which is a
+// suitable order in which to evaluate the blocks.
+//
+// If the graph contains dependency cycles then an error diagnostic is
+// returned for each one. The nodes are still all returned in that case, but
+// the nodes in each cycle appear in declaration order relative to one
+// another, since no valid order exists for them.
+func (g *Graph) TopologicalOrder() ([]*Node, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	ret := make([]*Node, 0, len(g.nodes))
+	for _, component := range depgraph.Components(g.deps) {
+		if cycle := depgraph.FindCycle(component, g.deps); cycle != nil {
+			diags = append(diags, g.cycleDiagnostic(cycle))
+		}
+		for _, idx := range component {
+			ret = append(ret, g.nodes[idx])
+		}
+	}
+	return ret, diags
+}
+
+// Cycles returns one cycle for each group of nodes that depend on one
+// another, directly or indirectly. Each cycle starts and ends with the same
+// node, with each node depending on the node after it.
+//
+// The result is empty if the graph has no cycles.
+func (g *Graph) Cycles() [][]*Node {
+	var ret [][]*Node
+	for _, component := range depgraph.Components(g.deps) {
+		cycle := depgraph.FindCycle(component, g.deps)
+		if cycle == nil {
+			continue
+		}
+		nodes := make([]*Node, len(cycle))
+		for i, idx := range cycle {
+			nodes[i] = g.nodes[idx]
+		}
+		ret = append(ret, nodes)
+	}
+	return ret
+}
+
+func (g *Graph) cycleDiagnostic(cycle []int) *hcl.Diagnostic {
+	chain := depgraph.CycleChain(cycle, func(from, to int) string {
+		fromNode, toNode := g.nodes[from], g.nodes[to]
+		return fmt.Sprintf("%s refers to %s at %s", fromNode.Addr, toNode.Addr, fromNode.referenceRange(toNode))
+	})
+
+	first := g.nodes[cycle[0]]
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Dependency cycle between blocks",
+		Detail: fmt.Sprintf(
+			"The block %s depends on itself through the following chain of references:%s",
+			first.Addr, chain,
+		),
+		Subject: first.Block.DefRange.Ptr(),
+	}
+}
+
+// referenceRange returns the source range of the first reference from the
+// receiver to the given node.
+func (n *Node) referenceRange(to *Node) hcl.Range {
+	for _, ref := range n.References {
+		for _, target := range ref.Targets {
+			if target == to {
+				return ref.Traversal.SourceRange()
+			}
+		}
+	}
+	return n.Block.DefRange
+}
@@ -0,0 +1,117 @@
+package refgraph
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/hashicorp/hcl2/ext/dynblock"
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcldec"
+)
+
+// BlockType describes a type of top-level block whose blocks are to be
+// included as nodes in the graph.
+type BlockType struct {
+	// Name is the block type name, as used in the configuration.
+	Name string
+
+	// LabelNames gives the names of the labels expected for this block type.
+	// Each block's address consists of the reference root name followed by
+	// its labels.
+	LabelNames []string
+
+	// RefRoot is the root name that expressions use to refer to blocks of
+	// this type. If empty, the block type name is used.
+	RefRoot string
+
+	// Variables returns the variables referenced from within the body of a
+	// block of this type, including from within any nested blocks.
+	Variables VariablesFunc
+}
+
+func (bt *BlockType) refRoot() string {
+	if bt.RefRoot != "" {
+		return bt.RefRoot
+	}
+	return bt.Name
+}
+
+// VariablesFunc is the signature of a function that finds all of the
+// variables referenced in a given block body.
+type VariablesFunc func(body hcl.Body) []hcl.Traversal
+
+// SpecVariables returns a VariablesFunc that uses the given hcldec
+// specification to find the variables referenced in a body, treating any
+// "dynamic" blocks as the dynblock extension would.
+func SpecVariables(spec hcldec.Spec) VariablesFunc {
+	return func(body hcl.Body) []hcl.Traversal {
+		return dynblock.VariablesHCLDec(body, spec)
+	}
+}
+
+// GoStructVariables returns a VariablesFunc that uses the gohcl struct tags
+// on the type of the given value, which must be a struct value or a pointer
+// to one, to find the variables referenced in a body, treating any
+// "dynamic" blocks as the dynblock extension would.
+//
+// As with gohcl.ImpliedBodySchema, this function will panic if given an
+// inappropriate value.
+func GoStructVariables(val interface{}) VariablesFunc {
+	ty := structType(reflect.TypeOf(val))
+	return func(body hcl.Body) []hcl.Traversal {
+		return walkGoStructVariables(dynblock.WalkVariables(body), ty)
+	}
+}
+
+// BlockTypesForGoStruct returns a BlockType for each of the block-typed
+// fields in the type of the given value, which must be a struct value or a
+// pointer to one, using the gohcl struct tags to determine each block type's
+// name, labels, and nested structure.
+//
+// The RefRoot of each of the returned block types is left empty, so callers
+// may set it if desired before passing the result to Build.
+//
+// As with gohcl.ImpliedBodySchema, this function will panic if given an
+// inappropriate value.
+func BlockTypesForGoStruct(val interface{}) []BlockType {
+	ty := structType(reflect.TypeOf(val))
+	schema, _ := gohcl.ImpliedBodySchema(reflect.Zero(ty).Interface())
+	nested := gohcl.ImpliedBlockTypes(reflect.Zero(ty).Interface())
+
+	ret := make([]BlockType, 0, len(schema.Blocks))
+	for _, blockS := range schema.Blocks {
+		ret = append(ret, BlockType{
+			Name:       blockS.Type,
+			LabelNames: blockS.LabelNames,
+			Variables:  GoStructVariables(reflect.Zero(nested[blockS.Type]).Interface()),
+		})
+	}
+	return ret
+}
+
+func walkGoStructVariables(node dynblock.WalkVariablesNode, ty reflect.Type) []hcl.Traversal {
+	schema, _ := gohcl.ImpliedBodySchema(reflect.Zero(ty).Interface())
+	vars, children := node.Visit(schema)
+
+	if len(children) > 0 {
+		nested := gohcl.ImpliedBlockTypes(reflect.Zero(ty).Interface())
+		for _, child := range children {
+			if childTy, exists := nested[child.BlockTypeName]; exists {
+				vars = append(vars, walkGoStructVariables(child.Node, childTy)...)
+			}
+		}
+	}
+
+	return vars
+}
+
+func structType(ty reflect.Type) reflect.Type {
+	if ty.Kind() == reflect.Ptr {
+		ty = ty.Elem()
+	}
+	if ty.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("given value must be struct, not %s", ty))
+	}
+	return ty
+}
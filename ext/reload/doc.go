@@ -0,0 +1,20 @@
+// Package reload helps long-running programs reload their configuration when
+// the files it was loaded from change.
+//
+// A Reloader calls an application-provided function to load configuration
+// using a hclparse.Parser, and then watches all of the files that the parser
+// read. When any of them change, it loads the configuration again using a new
+// parser that shares a cache with the previous ones, so that only the changed
+// files are parsed again, and notifies its subscribers of the result.
+//
+// Files are watched using a Watcher, which by default polls the files for
+// changes to their size or modification time. Applications can provide their
+// own Watcher implementation to use operating system notifications instead.
+//
+// Because the set of watched files is taken from the parser, files that are
+// included using the FileResolver from package ext/include are watched too,
+// as long as the resolver uses the parser given to the load function. Such
+// files are parsed only when the content of the including body is requested,
+// so the Reloader updates the set of watched files after notifying its
+// subscribers, once they have had the opportunity to decode the new body.
+package reload
@@ -0,0 +1,304 @@
+package reload
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hclparse"
+)
+
+// LoadFunc is the signature of a function that loads configuration using the
+// given parser, returning the resulting body.
+//
+// All of the files that make up the configuration must be parsed using the
+// given parser, so that the Reloader can watch them. The function should not
+// retain the parser after returning, except within the returned body for
+// the purpose of resolving includes.
+type LoadFunc func(parser *hclparse.Parser) (hcl.Body, hcl.Diagnostics)
+
+// Update describes the result of loading configuration.
+type Update struct {
+	// Body and Diagnostics are the results of the LoadFunc.
+	Body        hcl.Body
+	Diagnostics hcl.Diagnostics
+
+	// Parser is the parser that was given to the LoadFunc, whose Files
+	// method can be used to print diagnostics with source code snippets.
+	Parser *hclparse.Parser
+
+	// Changed is the paths whose changes caused the configuration to be
+	// loaded again, which is nil for the initial load.
+	Changed []string
+}
+
+// Reloader loads configuration and then loads it again whenever the files
+// it was loaded from change, notifying its subscribers each time.
+type Reloader struct {
+	load    LoadFunc
+	watcher Watcher
+	cache   *hclparse.Cache
+
+	// loadMu is held while loading, so that loads happen one at a time.
+	loadMu sync.Mutex
+
+	mu      sync.Mutex
+	current Update
+	subs    map[int]func(Update)
+	nextSub int
+	started bool
+	done    chan struct{}
+
+	// watchErr is the first error from the watcher when updating the set
+	// of watched files after a reload, which Close returns.
+	watchErr error
+
+	// pending is the updates that subscribers have not yet been notified
+	// of, in the order they were loaded. While delivering is set, some
+	// goroutine is notifying the subscribers of them, so that subscribers
+	// see the updates in order and one at a time.
+	pending    []Update
+	delivering bool
+}
+
+// New creates a Reloader that loads configuration using the given function
+// and watches the files it parses using the given watcher, which the
+// Reloader then owns. If the watcher is nil then a polling watcher that
+// checks for changes every second is used.
+//
+// The configuration is not loaded until Load is called.
+func New(load LoadFunc, watcher Watcher) *Reloader {
+	if watcher == nil {
+		watcher = NewPollingWatcher(time.Second)
+	}
+	return &Reloader{
+		load:    load,
+		watcher: watcher,
+		cache:   hclparse.NewCache(hclparse.CacheByModTime),
+		subs:    map[int]func(Update){},
+		done:    make(chan struct{}),
+	}
+}
+
+// Load loads the configuration, notifies the subscribers and then returns
+// the result. It is usually called once before calling Start, but may also
+// be called at any time to force the configuration to be loaded again.
+//
+// If the subscribers are already being notified of an earlier load, such as
+// when a subscriber itself calls Load, then the goroutine notifying them
+// delivers this result once they have returned, and Load returns without
+// waiting for that.
+func (r *Reloader) Load() Update {
+	return r.reload(nil)
+}
+
+// Current returns the result of the most recent load.
+func (r *Reloader) Current() Update {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Subscribe registers a function to be called with the result each time the
+// configuration is loaded, returning a function that cancels the
+// subscription.
+//
+// Subscribers are called one at a time, in no particular order, and are
+// given the results in the order they were loaded. They are usually called
+// from the goroutine that loaded the configuration, and may themselves call
+// methods of the Reloader, including Load, but not Close. Once they have all returned, the
+// Reloader begins watching any files that were parsed by them, such as files
+// included by the body, and drops any other files from its cache.
+func (r *Reloader) Subscribe(fn func(Update)) (cancel func()) {
+	r.mu.Lock()
+	id := r.nextSub
+	r.nextSub++
+	r.subs[id] = fn
+	r.mu.Unlock()
+
+	return func() {
+		r.mu.Lock()
+		delete(r.subs, id)
+		r.mu.Unlock()
+	}
+}
+
+// Start begins watching the files parsed by the most recent load, and
+// loading the configuration again whenever they change, until Close is
+// called. The results are delivered only to the subscribers.
+//
+// If any of the files changed after they were parsed but before Start was
+// called then the configuration is loaded again before Start returns.
+//
+// Start returns an error if the watcher cannot watch the files, in which
+// case the Reloader is not started. Errors from watching the files of later
+// loads are returned by Close instead.
+func (r *Reloader) Start() error {
+	r.mu.Lock()
+	if r.started {
+		r.mu.Unlock()
+		return nil
+	}
+	r.started = true
+	parser := r.current.Parser
+	r.mu.Unlock()
+
+	var stale []string
+	if parser != nil {
+		var err error
+		stale, err = r.watch(parsedFiles(parser))
+		if err != nil {
+			r.mu.Lock()
+			r.started = false
+			r.mu.Unlock()
+			return err
+		}
+	}
+	go func() {
+		defer close(r.done)
+		for changed := range r.watcher.Changes() {
+			r.reload(changed)
+		}
+	}()
+	if len(stale) != 0 {
+		r.reload(stale)
+	}
+	return nil
+}
+
+// Close stops watching files and waits for any reload in progress to
+// complete. It returns the error from closing the watcher, if any, or else
+// the first error from watching the files of a load after Start.
+//
+// Close must not be called by a subscriber, because it would then wait for
+// the delivery of an update to the subscriber that is calling it. A
+// subscriber that needs to stop the Reloader can call Close from a new
+// goroutine instead.
+func (r *Reloader) Close() error {
+	err := r.watcher.Close()
+
+	r.mu.Lock()
+	started := r.started
+	r.mu.Unlock()
+	if started {
+		<-r.done
+	}
+
+	if err == nil {
+		r.mu.Lock()
+		err = r.watchErr
+		r.mu.Unlock()
+	}
+	return err
+}
+
+func (r *Reloader) reload(changed []string) Update {
+	r.loadMu.Lock()
+	for _, path := range changed {
+		r.cache.Forget(path)
+	}
+
+	parser := hclparse.NewParserWithCache(r.cache)
+	body, diags := r.load(parser)
+	update := Update{
+		Body:        body,
+		Diagnostics: diags,
+		Parser:      parser,
+		Changed:     changed,
+	}
+
+	r.mu.Lock()
+	r.current = update
+	r.pending = append(r.pending, update)
+	deliver := !r.delivering
+	r.delivering = true
+	r.mu.Unlock()
+	r.loadMu.Unlock()
+
+	if deliver {
+		r.deliver()
+	}
+	return update
+}
+
+// deliver notifies the subscribers of each of the pending updates in turn,
+// until there are none left. Subscribers are called without holding any
+// locks, so that they may call methods of the Reloader, including Load.
+func (r *Reloader) deliver() {
+	for {
+		r.mu.Lock()
+		if len(r.pending) == 0 {
+			r.delivering = false
+			r.mu.Unlock()
+			return
+		}
+		update := r.pending[0]
+		r.pending = r.pending[1:]
+		subs := make([]func(Update), 0, len(r.subs))
+		for _, fn := range r.subs {
+			subs = append(subs, fn)
+		}
+		started := r.started
+		r.mu.Unlock()
+
+		for _, fn := range subs {
+			fn(update)
+		}
+
+		// The subscribers may have parsed more files, such as included
+		// ones, so we can only now tell which files are no longer used.
+		filenames := parsedFiles(update.Parser)
+		r.cache.Retain(filenames)
+		if !started {
+			continue
+		}
+		stale, err := r.watch(filenames)
+		if err != nil {
+			r.mu.Lock()
+			if r.watchErr == nil {
+				r.watchErr = err
+			}
+			r.mu.Unlock()
+		}
+		if len(stale) != 0 {
+			// We are already delivering, so this only queues the update
+			// for the next iteration of this loop.
+			r.reload(stale)
+		}
+	}
+}
+
+// watch makes the watcher watch the given files and the directories
+// containing them, so that new files are noticed too.
+//
+// Watchers may not notice changes made before they begin watching, so watch
+// also returns those of the files that have changed since they were parsed.
+func (r *Reloader) watch(filenames []string) ([]string, error) {
+	seen := map[string]bool{}
+	var paths []string
+	for _, filename := range filenames {
+		for _, path := range []string{filename, filepath.Dir(filename)} {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+	if err := r.watcher.Watch(paths); err != nil {
+		return nil, err
+	}
+	return r.cache.Stale(filenames), nil
+}
+
+// parsedFiles returns the names of the files parsed by the given parser.
+func parsedFiles(parser *hclparse.Parser) []string {
+	files := parser.Files()
+	ret := make([]string, 0, len(files))
+	for filename := range files {
+		ret = append(ret, filename)
+	}
+	return ret
+}
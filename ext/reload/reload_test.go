@@ -0,0 +1,310 @@
+package reload
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl2/ext/include"
+	"github.com/hashicorp/hcl2/ext/transform"
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hclparse"
+)
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	mainFile := filepath.Join(dir, "main.hcl")
+	incFile := filepath.Join(dir, "inc.hcl")
+	files := map[string]string{
+		mainFile: `
+name = "main"
+
+include {
+  path = "inc.hcl"
+}
+`,
+		incFile: `port = 1`,
+	}
+	for filename, src := range files {
+		if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	load := func(parser *hclparse.Parser) (hcl.Body, hcl.Diagnostics) {
+		f, diags := parser.ParseHCLFile(mainFile)
+		if f == nil {
+			return hcl.EmptyBody(), diags
+		}
+		resolver := include.FileResolver("", parser)
+		return transform.Deep(f.Body, include.Transformer("include", nil, resolver)), diags
+	}
+
+	type Config struct {
+		Name string `hcl:"name"`
+		Port int    `hcl:"port"`
+	}
+	configs := make(chan Config, 10)
+	var mainFiles []*hcl.File
+	var mu sync.Mutex
+
+	watcher := newTestWatcher()
+	r := New(load, watcher)
+	defer r.Close()
+	r.Subscribe(func(u Update) {
+		if u.Diagnostics.HasErrors() {
+			t.Errorf("unexpected diagnostics: %s", u.Diagnostics.Error())
+			return
+		}
+		var config Config
+		if diags := gohcl.DecodeBody(u.Body, nil, &config); diags.HasErrors() {
+			t.Errorf("unexpected diagnostics: %s", diags.Error())
+			return
+		}
+		mu.Lock()
+		mainFiles = append(mainFiles, u.Parser.Files()[mainFile])
+		mu.Unlock()
+		configs <- config
+	})
+
+	r.Load()
+	if got, want := <-configs, (Config{"main", 1}); got != want {
+		t.Fatalf("wrong initial config %#v; want %#v", got, want)
+	}
+
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	wantWatched := []string{dir, incFile, mainFile}
+	if got := watcher.watched(); !reflect.DeepEqual(got, wantWatched) {
+		t.Fatalf("wrong watched paths\ngot:  %#v\nwant: %#v", got, wantWatched)
+	}
+
+	if err := ioutil.WriteFile(incFile, []byte(`port = 2`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	watcher.changes <- []string{incFile}
+
+	select {
+	case got := <-configs:
+		if want := (Config{"main", 2}); got != want {
+			t.Errorf("wrong reloaded config %#v; want %#v", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("configuration was not reloaded")
+	}
+
+	mu.Lock()
+	if mainFiles[0] != mainFiles[1] {
+		t.Errorf("unchanged main file was parsed again")
+	}
+	mu.Unlock()
+	if got := r.Current().Changed; !reflect.DeepEqual(got, []string{incFile}) {
+		t.Errorf("wrong changed paths %#v", got)
+	}
+}
+
+func TestReloaderSubscriberLoad(t *testing.T) {
+	loads := 0
+	load := func(parser *hclparse.Parser) (hcl.Body, hcl.Diagnostics) {
+		loads++
+		f, diags := parser.ParseHCL([]byte(fmt.Sprintf("n = %d\n", loads)), "main.hcl")
+		return f.Body, diags
+	}
+
+	r := New(load, newTestWatcher())
+	var got []int
+	r.Subscribe(func(u Update) {
+		var config struct {
+			N int `hcl:"n"`
+		}
+		gohcl.DecodeBody(u.Body, nil, &config)
+		got = append(got, config.N)
+		if config.N == 1 {
+			// This must not deadlock, and the subscribers must be notified
+			// of the second load only once this call has returned.
+			r.Load()
+			got = append(got, -1)
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		r.Load()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Load called by a subscriber did not return")
+	}
+
+	if want := []int{1, -1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong notifications %#v; want %#v", got, want)
+	}
+	if got, want := r.Current().Parser.Files()["main.hcl"].Bytes, []byte("n = 2\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong current source %q; want %q", got, want)
+	}
+}
+
+func TestReloaderChangedBeforeStart(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "main.hcl")
+	if err := ioutil.WriteFile(filename, []byte("n = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	load := func(parser *hclparse.Parser) (hcl.Body, hcl.Diagnostics) {
+		f, diags := parser.ParseHCLFile(filename)
+		if f == nil {
+			return hcl.EmptyBody(), diags
+		}
+		return f.Body, diags
+	}
+
+	r := New(load, newTestWatcher())
+	defer r.Close()
+	var got []int
+	r.Subscribe(func(u Update) {
+		var config struct {
+			N int `hcl:"n"`
+		}
+		gohcl.DecodeBody(u.Body, nil, &config)
+		got = append(got, config.N)
+	})
+	r.Load()
+
+	// The watcher can't notice a change made before it starts watching, so
+	// the Reloader must notice it instead.
+	if err := ioutil.WriteFile(filename, []byte("n = 22\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filename, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []int{1, 22}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong notifications %#v; want %#v", got, want)
+	}
+	if got, want := r.Current().Changed, []string{filename}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong changed paths %#v; want %#v", got, want)
+	}
+}
+
+func TestReloaderWatchError(t *testing.T) {
+	load := func(parser *hclparse.Parser) (hcl.Body, hcl.Diagnostics) {
+		f, diags := parser.ParseHCL([]byte("n = 1\n"), "main.hcl")
+		return f.Body, diags
+	}
+
+	watcher := newTestWatcher()
+	watcher.err = fmt.Errorf("too many files")
+	r := New(load, watcher)
+	r.Load()
+	if err := r.Start(); err != watcher.err {
+		t.Errorf("wrong error from Start %v; want %v", err, watcher.err)
+	}
+
+	watcher.err = nil
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	watcher.mu.Lock()
+	watcher.err = fmt.Errorf("watch failed")
+	watcher.mu.Unlock()
+	r.Load()
+	if err := r.Close(); err == nil || err.Error() != "watch failed" {
+		t.Errorf("wrong error from Close %v; want %q", err, "watch failed")
+	}
+}
+
+func TestPollingWatcher(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.hcl")
+	b := filepath.Join(dir, "b.hcl")
+	if err := ioutil.WriteFile(a, []byte(`a = 1`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewPollingWatcher(10 * time.Millisecond)
+	defer w.Close()
+	w.Watch([]string{a, b})
+
+	if err := ioutil.WriteFile(a, []byte(`a = 22`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(a, later, later)
+	if err := ioutil.WriteFile(b, []byte(`b = 1`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]bool{}
+	timeout := time.After(5 * time.Second)
+	for len(got) < 2 {
+		select {
+		case changed := <-w.Changes():
+			for _, path := range changed {
+				got[path] = true
+			}
+		case <-timeout:
+			t.Fatalf("changes not reported; got %#v", got)
+		}
+	}
+	if !got[a] || !got[b] {
+		t.Errorf("wrong changes %#v", got)
+	}
+
+	w.Close()
+	for range w.Changes() {
+		// drain until closed
+	}
+}
+
+type testWatcher struct {
+	mu      sync.Mutex
+	paths   []string
+	changes chan []string
+
+	// err, if set, is returned by Watch.
+	err error
+}
+
+func newTestWatcher() *testWatcher {
+	return &testWatcher{
+		changes: make(chan []string),
+	}
+}
+
+func (w *testWatcher) Watch(paths []string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.paths = paths
+	return nil
+}
+
+func (w *testWatcher) watched() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.paths
+}
+
+func (w *testWatcher) Changes() <-chan []string {
+	return w.changes
+}
+
+func (w *testWatcher) Close() error {
+	close(w.changes)
+	return nil
+}
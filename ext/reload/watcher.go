@@ -0,0 +1,141 @@
+package reload
+
+import (
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Watcher is the interface used by a Reloader to learn of changes to the
+// files it is watching.
+type Watcher interface {
+	// Watch replaces the set of watched paths with the given paths, which
+	// may be files or directories. Changes to a directory include files
+	// being added to or removed from it.
+	Watch(paths []string) error
+
+	// Changes returns a channel that receives the paths that have changed,
+	// possibly several at once. The channel is closed when the watcher is
+	// closed.
+	Changes() <-chan []string
+
+	// Close stops watching all paths.
+	Close() error
+}
+
+// NewPollingWatcher returns a Watcher that checks the size and modification
+// time of each watched path at the given interval, and reports those that
+// have changed, been created or been removed since the previous check.
+func NewPollingWatcher(interval time.Duration) Watcher {
+	w := &pollingWatcher{
+		states:  map[string]pathState{},
+		changes: make(chan []string),
+		done:    make(chan struct{}),
+	}
+	go w.run(interval)
+	return w
+}
+
+type pollingWatcher struct {
+	mu     sync.Mutex
+	states map[string]pathState
+
+	changes   chan []string
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// pathState is what a pollingWatcher knows about a path as of its most
+// recent check.
+type pathState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+func statPath(path string) pathState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return pathState{}
+	}
+	return pathState{
+		exists:  true,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+	}
+}
+
+func (s pathState) equal(other pathState) bool {
+	return s.exists == other.exists && s.size == other.size && s.modTime.Equal(other.modTime)
+}
+
+func (w *pollingWatcher) Watch(paths []string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	states := make(map[string]pathState, len(paths))
+	for _, path := range paths {
+		if state, exists := w.states[path]; exists {
+			states[path] = state
+		} else {
+			states[path] = statPath(path)
+		}
+	}
+	w.states = states
+	return nil
+}
+
+func (w *pollingWatcher) Changes() <-chan []string {
+	return w.changes
+}
+
+func (w *pollingWatcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+	})
+	return nil
+}
+
+func (w *pollingWatcher) run(interval time.Duration) {
+	defer close(w.changes)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		changed := w.poll()
+		if len(changed) == 0 {
+			continue
+		}
+		select {
+		case <-w.done:
+			return
+		case w.changes <- changed:
+		}
+	}
+}
+
+// poll checks all of the watched paths and returns those that have changed
+// since the previous check, in lexical order.
+func (w *pollingWatcher) poll() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var changed []string
+	for path, prev := range w.states {
+		state := statPath(path)
+		if !state.equal(prev) {
+			changed = append(changed, path)
+			w.states[path] = state
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
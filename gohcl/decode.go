@@ -7,6 +7,7 @@ import (
 	"github.com/zclconf/go-cty/cty"
 
 	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcldec"
 	"github.com/zclconf/go-cty/cty/convert"
 	"github.com/zclconf/go-cty/cty/gocty"
 )
@@ -33,22 +34,55 @@ func DecodeBody(body hcl.Body, ctx *hcl.EvalContext, val interface{}) hcl.Diagno
 		panic(fmt.Sprintf("target value must be a pointer, not %s", rv.Type().String()))
 	}
 
-	return decodeBodyToValue(body, ctx, rv.Elem())
+	return decodeBodyToValue(body, ctx, rv.Elem(), nil, nil)
 }
 
-func decodeBodyToValue(body hcl.Body, ctx *hcl.EvalContext, val reflect.Value) hcl.Diagnostics {
+// PlanDecodeBody is like DecodeBody except that it tolerates unknown values,
+// returning a description of each part of the configuration that is unknown.
+//
+// This is intended for applications that validate configuration before the
+// values of all of its variables are available, by placing cty.UnknownVal
+// values into the given EvalContext for the variables that are not yet
+// known. DecodeBody would return errors for any unknown values that it can't
+// represent in the target Go types, but PlanDecodeBody instead leaves the
+// corresponding fields at their zero values and returns an UnknownValue
+// describing each one. Fields of type cty.Value are populated with the
+// unknown value, but are reported as unknown too.
+//
+// The paths of the returned values use the attribute and block type names
+// from the struct tags, with index steps for the elements of slices and the
+// keys of maps, so they describe the same locations as they would in a
+// value decoded by hcldec using the spec returned by ImpliedSpec.
+func PlanDecodeBody(body hcl.Body, ctx *hcl.EvalContext, val interface{}) ([]hcldec.UnknownValue, hcl.Diagnostics) {
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("target value must be a pointer, not %s", rv.Type().String()))
+	}
+
+	plan := &planState{}
+	diags := decodeBodyToValue(body, ctx, rv.Elem(), nil, plan)
+	return plan.unknowns, diags
+}
+
+// planState accumulates the unknown values found by PlanDecodeBody. A nil
+// *planState represents a normal decode, where unknown values are errors.
+type planState struct {
+	unknowns []hcldec.UnknownValue
+}
+
+func decodeBodyToValue(body hcl.Body, ctx *hcl.EvalContext, val reflect.Value, path cty.Path, plan *planState) hcl.Diagnostics {
 	et := val.Type()
 	switch et.Kind() {
 	case reflect.Struct:
-		return decodeBodyToStruct(body, ctx, val)
+		return decodeBodyToStruct(body, ctx, val, path, plan)
 	case reflect.Map:
-		return decodeBodyToMap(body, ctx, val)
+		return decodeBodyToMap(body, ctx, val, path, plan)
 	default:
 		panic(fmt.Sprintf("target value must be pointer to struct or map, not %s", et.String()))
 	}
 }
 
-func decodeBodyToStruct(body hcl.Body, ctx *hcl.EvalContext, val reflect.Value) hcl.Diagnostics {
+func decodeBodyToStruct(body hcl.Body, ctx *hcl.EvalContext, val reflect.Value, path cty.Path, plan *planState) hcl.Diagnostics {
 	schema, partial := ImpliedBodySchema(val.Interface())
 
 	var content *hcl.BodyContent
@@ -66,9 +100,9 @@ func decodeBodyToStruct(body hcl.Body, ctx *hcl.EvalContext, val reflect.Value)
 	tags := getFieldTags(val.Type())
 
 	if tags.Remain != nil {
-		fieldIdx := *tags.Remain
-		field := val.Type().Field(fieldIdx)
-		fieldV := val.Field(fieldIdx)
+		fieldIdx := tags.Remain
+		field := val.Type().FieldByIndex(fieldIdx)
+		fieldV := val.FieldByIndex(fieldIdx)
 		switch {
 		case bodyType.AssignableTo(field.Type):
 			fieldV.Set(reflect.ValueOf(leftovers))
@@ -79,16 +113,37 @@ func decodeBodyToStruct(body hcl.Body, ctx *hcl.EvalContext, val reflect.Value)
 			}
 			fieldV.Set(reflect.ValueOf(attrs))
 		default:
-			diags = append(diags, decodeBodyToValue(leftovers, ctx, fieldV)...)
+			diags = append(diags, decodeBodyToValue(leftovers, ctx, fieldV, path, plan)...)
 		}
 	}
 
+	unknownAttrs := map[string]bool{}
 	for name, fieldIdx := range tags.Attributes {
 		attr := content.Attributes[name]
-		field := val.Type().Field(fieldIdx)
-		fieldV := val.Field(fieldIdx)
+		field := val.Type().FieldByIndex(fieldIdx)
+		fieldV := val.FieldByIndex(fieldIdx)
 
 		if attr == nil {
+			if dflt, hasDefault := tags.Defaults[name]; hasDefault && !attrType.AssignableTo(field.Type) {
+				// The default is given as a string in the struct tag, which
+				// we decode as if it were a string literal in the
+				// configuration, converting it to the field's type.
+				dfltExpr := hcl.StaticExpr(cty.StringVal(dflt), body.MissingItemRange())
+				if exprType.AssignableTo(field.Type) {
+					fieldV.Set(reflect.ValueOf(dfltExpr))
+				} else {
+					diags = append(diags, decodeExpression(
+						dfltExpr, ctx, fieldV.Addr().Interface(), path.GetAttr(name), plan,
+					)...)
+				}
+				continue
+			}
+			if attrType.AssignableTo(field.Type) || field.Type == attrValType {
+				// Clear any attribute left over from a previous decode, so
+				// that absence is always represented consistently.
+				fieldV.Set(reflect.Zero(field.Type))
+				continue
+			}
 			if !exprType.AssignableTo(field.Type) {
 				continue
 			}
@@ -105,12 +160,21 @@ func decodeBodyToStruct(body hcl.Body, ctx *hcl.EvalContext, val reflect.Value)
 		switch {
 		case attrType.AssignableTo(field.Type):
 			fieldV.Set(reflect.ValueOf(attr))
+		case field.Type == attrValType:
+			fieldV.Set(reflect.ValueOf(*attr))
 		case exprType.AssignableTo(field.Type):
 			fieldV.Set(reflect.ValueOf(attr.Expr))
 		default:
-			diags = append(diags, DecodeExpression(
-				attr.Expr, ctx, fieldV.Addr().Interface(),
+			var numUnknowns int
+			if plan != nil {
+				numUnknowns = len(plan.unknowns)
+			}
+			diags = append(diags, decodeExpression(
+				attr.Expr, ctx, fieldV.Addr().Interface(), path.GetAttr(name), plan,
 			)...)
+			if plan != nil && len(plan.unknowns) > numUnknowns {
+				unknownAttrs[name] = true
+			}
 		}
 	}
 
@@ -118,21 +182,25 @@ func decodeBodyToStruct(body hcl.Body, ctx *hcl.EvalContext, val reflect.Value)
 
 	for typeName, fieldIdx := range tags.Blocks {
 		blocks := blocksByType[typeName]
-		field := val.Type().Field(fieldIdx)
+		field := val.Type().FieldByIndex(fieldIdx)
+		fieldV := val.FieldByIndex(fieldIdx)
 
 		ty := field.Type
 		isSlice := false
 		isPtr := false
+		mapDepth := 0
 		if ty.Kind() == reflect.Slice {
 			isSlice = true
 			ty = ty.Elem()
+		} else {
+			mapDepth, ty = blockMapDepth(ty)
 		}
 		if ty.Kind() == reflect.Ptr {
 			isPtr = true
 			ty = ty.Elem()
 		}
 
-		if len(blocks) > 1 && !isSlice {
+		if len(blocks) > 1 && !isSlice && mapDepth == 0 {
 			diags = append(diags, &hcl.Diagnostic{
 				Severity: hcl.DiagError,
 				Summary:  fmt.Sprintf("Duplicate %s block", typeName),
@@ -146,8 +214,8 @@ func decodeBodyToStruct(body hcl.Body, ctx *hcl.EvalContext, val reflect.Value)
 		}
 
 		if len(blocks) == 0 {
-			if isSlice || isPtr {
-				val.Field(fieldIdx).Set(reflect.Zero(field.Type))
+			if isSlice || isPtr || mapDepth > 0 {
+				fieldV.Set(reflect.Zero(field.Type))
 			} else {
 				diags = append(diags, &hcl.Diagnostic{
 					Severity: hcl.DiagError,
@@ -169,35 +237,66 @@ func decodeBodyToStruct(body hcl.Body, ctx *hcl.EvalContext, val reflect.Value)
 			sli := reflect.MakeSlice(reflect.SliceOf(elemType), len(blocks), len(blocks))
 
 			for i, block := range blocks {
+				blockPath := path.GetAttr(typeName).Index(cty.NumberIntVal(int64(i)))
 				if isPtr {
 					v := reflect.New(ty)
-					diags = append(diags, decodeBlockToValue(block, ctx, v.Elem())...)
+					diags = append(diags, decodeBlockToValue(block, ctx, v.Elem(), blockPath, plan)...)
 					sli.Index(i).Set(v)
 				} else {
-					diags = append(diags, decodeBlockToValue(block, ctx, sli.Index(i))...)
+					diags = append(diags, decodeBlockToValue(block, ctx, sli.Index(i), blockPath, plan)...)
 				}
 			}
 
-			val.Field(fieldIdx).Set(sli)
+			fieldV.Set(sli)
+
+		case mapDepth > 0:
+			diags = append(diags, decodeBlocksToMap(blocks, ctx, fieldV, mapDepth, path.GetAttr(typeName), plan)...)
 
 		default:
 			block := blocks[0]
+			blockPath := path.GetAttr(typeName)
 			if isPtr {
 				v := reflect.New(ty)
-				diags = append(diags, decodeBlockToValue(block, ctx, v.Elem())...)
-				val.Field(fieldIdx).Set(v)
+				diags = append(diags, decodeBlockToValue(block, ctx, v.Elem(), blockPath, plan)...)
+				fieldV.Set(v)
 			} else {
-				diags = append(diags, decodeBlockToValue(block, ctx, val.Field(fieldIdx))...)
+				diags = append(diags, decodeBlockToValue(block, ctx, fieldV, blockPath, plan)...)
 			}
 
 		}
 
 	}
 
+	for name, fieldIdx := range tags.Ranges {
+		fieldV := val.FieldByIndex(fieldIdx)
+		switch {
+		case content.Attributes[name] != nil:
+			setRange(fieldV, content.Attributes[name].Range)
+		case len(blocksByType[name]) > 0:
+			setRange(fieldV, blocksByType[name][0].DefRange)
+		default:
+			fieldV.Set(reflect.Zero(fieldV.Type()))
+		}
+	}
+	if tags.BodyRange != nil {
+		setRange(val.FieldByIndex(tags.BodyRange), bodyRange(body))
+	}
+
+	if !diags.HasErrors() {
+		// We validate only once everything within the struct has decoded
+		// successfully, since validation rules are likely to produce
+		// confusing additional errors for values that are incomplete.
+		diags = append(diags, validateStruct(val, tags, &ValidateContext{
+			EvalContext:  ctx,
+			Content:      content,
+			unknownAttrs: unknownAttrs,
+		})...)
+	}
+
 	return diags
 }
 
-func decodeBodyToMap(body hcl.Body, ctx *hcl.EvalContext, v reflect.Value) hcl.Diagnostics {
+func decodeBodyToMap(body hcl.Body, ctx *hcl.EvalContext, v reflect.Value, path cty.Path, plan *planState) hcl.Diagnostics {
 	attrs, diags := body.JustAttributes()
 	if attrs == nil {
 		return diags
@@ -209,11 +308,13 @@ func decodeBodyToMap(body hcl.Body, ctx *hcl.EvalContext, v reflect.Value) hcl.D
 		switch {
 		case attrType.AssignableTo(v.Type().Elem()):
 			mv.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(attr))
+		case v.Type().Elem() == attrValType:
+			mv.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(*attr))
 		case exprType.AssignableTo(v.Type().Elem()):
 			mv.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(attr.Expr))
 		default:
 			ev := reflect.New(v.Type().Elem())
-			diags = append(diags, DecodeExpression(attr.Expr, ctx, ev.Interface())...)
+			diags = append(diags, decodeExpression(attr.Expr, ctx, ev.Interface(), path.Index(cty.StringVal(k)), plan)...)
 			mv.SetMapIndex(reflect.ValueOf(k), ev.Elem())
 		}
 	}
@@ -223,7 +324,90 @@ func decodeBodyToMap(body hcl.Body, ctx *hcl.EvalContext, v reflect.Value) hcl.D
 	return diags
 }
 
-func decodeBlockToValue(block *hcl.Block, ctx *hcl.EvalContext, v reflect.Value) hcl.Diagnostics {
+// setRange assigns the given range to the given value, which is of type
+// hcl.Range or *hcl.Range.
+func setRange(v reflect.Value, rng hcl.Range) {
+	if v.Kind() == reflect.Ptr {
+		v.Set(reflect.ValueOf(&rng))
+		return
+	}
+	v.Set(reflect.ValueOf(rng))
+}
+
+// bodyRange returns the source range of the given body, if its
+// implementation is able to report it, or its missing item range otherwise.
+func bodyRange(body hcl.Body) hcl.Range {
+	if rb, ok := body.(interface{ Range() hcl.Range }); ok {
+		return rb.Range()
+	}
+	return body.MissingItemRange()
+}
+
+// decodeBlocksToMap decodes the given blocks into a new map assigned to the
+// given value, which has the given number of levels of nested maps keyed by
+// the first labels of each block.
+func decodeBlocksToMap(blocks hcl.Blocks, ctx *hcl.EvalContext, v reflect.Value, depth int, path cty.Path, plan *planState) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	ty := v.Type()
+	elemTy := ty
+	for i := 0; i < depth; i++ {
+		elemTy = elemTy.Elem()
+	}
+	isPtr := elemTy.Kind() == reflect.Ptr
+	if isPtr {
+		elemTy = elemTy.Elem()
+	}
+
+	mv := reflect.MakeMap(ty)
+	seen := map[string]*hcl.Block{}
+	for _, block := range blocks {
+		keys := block.Labels[:depth]
+		seenKey := fmt.Sprintf("%q", keys)
+		if prev, exists := seen[seenKey]; exists {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  fmt.Sprintf("Duplicate %s block", block.Type),
+				Detail: fmt.Sprintf(
+					"A %s block with the same labels was already defined at %s. The %s labels must be unique.",
+					block.Type, prev.DefRange.String(), block.Type,
+				),
+				Subject: &block.DefRange,
+			})
+			continue
+		}
+		seen[seenKey] = block
+
+		// Find or create the innermost map for this block's keys.
+		target := mv
+		blockPath := path
+		for _, key := range keys[:depth-1] {
+			kv := reflect.ValueOf(key)
+			inner := target.MapIndex(kv)
+			if !inner.IsValid() {
+				inner = reflect.MakeMap(target.Type().Elem())
+				target.SetMapIndex(kv, inner)
+			}
+			target = inner
+			blockPath = blockPath.Index(cty.StringVal(key))
+		}
+		key := keys[depth-1]
+		blockPath = blockPath.Index(cty.StringVal(key))
+
+		ev := reflect.New(elemTy)
+		diags = append(diags, decodeBlockToValue(block, ctx, ev.Elem(), blockPath, plan)...)
+		if isPtr {
+			target.SetMapIndex(reflect.ValueOf(key), ev)
+		} else {
+			target.SetMapIndex(reflect.ValueOf(key), ev.Elem())
+		}
+	}
+
+	v.Set(mv)
+	return diags
+}
+
+func decodeBlockToValue(block *hcl.Block, ctx *hcl.EvalContext, v reflect.Value, path cty.Path, plan *planState) hcl.Diagnostics {
 	var diags hcl.Diagnostics
 
 	ty := v.Type()
@@ -240,14 +424,18 @@ func decodeBlockToValue(block *hcl.Block, ctx *hcl.EvalContext, v reflect.Value)
 		}
 		v.Elem().Set(reflect.ValueOf(attrs))
 	default:
-		diags = append(diags, decodeBodyToValue(block.Body, ctx, v)...)
+		diags = append(diags, decodeBodyToValue(block.Body, ctx, v, path, plan)...)
 
-		if len(block.Labels) > 0 {
-			blockTags := getFieldTags(ty)
-			for li, lv := range block.Labels {
-				lfieldIdx := blockTags.Labels[li].FieldIndex
-				v.Field(lfieldIdx).Set(reflect.ValueOf(lv))
-			}
+		if ty.Kind() != reflect.Struct {
+			break
+		}
+		blockTags := getFieldTags(ty)
+		for li, lv := range block.Labels {
+			lfieldIdx := blockTags.Labels[li].FieldIndex
+			v.FieldByIndex(lfieldIdx).Set(reflect.ValueOf(lv))
+		}
+		if blockTags.DefRange != nil {
+			setRange(v.FieldByIndex(blockTags.DefRange), block.DefRange)
 		}
 
 	}
@@ -257,7 +445,23 @@ func decodeBlockToValue(block *hcl.Block, ctx *hcl.EvalContext, v reflect.Value)
 
 // DecodeExpression extracts the value of the given expression into the given
 // value. This value must be something that gocty is able to decode into,
-// since the final decoding is delegated to that package.
+// since the final decoding is delegated to that package, unless it is one of
+// the following types that are handled specially:
+//
+//   - Types whose pointer type implements ExpressionDecoder, which are
+//     given the expression to decode themselves.
+//   - Types whose pointer type implements encoding.TextUnmarshaler, such as
+//     net.IP and regexp.Regexp, which are decoded from a string. The
+//     exceptions are big.Int and big.Float, which are decoded from numbers.
+//   - time.Duration, decoded from a string using time.ParseDuration.
+//   - url.URL, decoded from a string using url.Parse.
+//   - hcl.Expression, which is assigned the given expression itself.
+//   - []hcl.Expression and map[string]hcl.Expression, which are assigned the
+//     element expressions of a tuple or object constructor expression, as
+//     returned by hcl.ExprList and hcl.ExprMap, without evaluating them.
+//
+// Pointers to any of these types are also accepted, in which case a null
+// value produces a nil pointer.
 //
 // The given EvalContext is used to resolve any variables or functions in
 // expressions encountered while decoding. This may be nil to require only
@@ -270,14 +474,30 @@ func decodeBlockToValue(block *hcl.Block, ctx *hcl.EvalContext, v reflect.Value)
 // may still be accessed by a careful caller for static analysis and editor
 // integration use-cases.
 func DecodeExpression(expr hcl.Expression, ctx *hcl.EvalContext, val interface{}) hcl.Diagnostics {
+	return decodeExpression(expr, ctx, val, nil, nil)
+}
+
+func decodeExpression(expr hcl.Expression, ctx *hcl.EvalContext, val interface{}, path cty.Path, plan *planState) hcl.Diagnostics {
+	if diags, ok := decodeExpressionCustom(expr, ctx, reflect.ValueOf(val), path, plan); ok {
+		return diags
+	}
+
 	srcVal, diags := expr.Value(ctx)
 
-	convTy, err := gocty.ImpliedType(val)
+	convTy, err := impliedType(val)
 	if err != nil {
 		panic(fmt.Sprintf("unsuitable DecodeExpression target: %s", err))
 	}
 
 	srcVal, err = convert.Convert(srcVal, convTy)
+	if err == nil && plan != nil && !srcVal.IsWhollyKnown() {
+		plan.unknowns = append(plan.unknowns, unknownValues(srcVal, expr, ctx, path)...)
+		if convTy != cty.DynamicPseudoType {
+			// The target can't represent an unknown value, so we leave it
+			// at its zero value.
+			return diags
+		}
+	}
 	if err != nil {
 		diags = append(diags, &hcl.Diagnostic{
 			Severity: hcl.DiagError,
@@ -302,3 +522,31 @@ func DecodeExpression(expr hcl.Expression, ctx *hcl.EvalContext, val interface{}
 
 	return diags
 }
+
+// unknownValues returns an UnknownValue for each of the deepest unknown
+// values within the given value, which was produced by the given expression.
+func unknownValues(val cty.Value, expr hcl.Expression, ctx *hcl.EvalContext, path cty.Path) []hcldec.UnknownValue {
+	var traversals []hcl.Traversal
+	if ctx != nil {
+		for _, traversal := range expr.Variables() {
+			v, diags := traversal.TraverseAbs(ctx)
+			if !diags.HasErrors() && !v.IsWhollyKnown() {
+				traversals = append(traversals, traversal)
+			}
+		}
+	}
+
+	var ret []hcldec.UnknownValue
+	cty.Walk(val, func(rel cty.Path, v cty.Value) (bool, error) {
+		if v.IsKnown() {
+			return true, nil
+		}
+		ret = append(ret, hcldec.UnknownValue{
+			Path:       append(path.Copy(), rel...),
+			Range:      expr.Range(),
+			Traversals: traversals,
+		})
+		return false, nil
+	})
+	return ret
+}
@@ -3,11 +3,17 @@ package gohcl
 import (
 	"encoding/json"
 	"fmt"
+	"math/big"
+	"net"
+	"net/url"
 	"reflect"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
 	hclJSON "github.com/hashicorp/hcl2/hcl/json"
 	"github.com/zclconf/go-cty/cty"
 )
@@ -643,3 +649,500 @@ func (e *fixedExpression) StartRange() (r hcl.Range) {
 func (e *fixedExpression) Variables() []hcl.Traversal {
 	return nil
 }
+
+func TestPlanDecodeBody(t *testing.T) {
+	type Rule struct {
+		CIDR string `hcl:"cidr"`
+	}
+	type Config struct {
+		Name  string    `hcl:"name"`
+		Ports []int     `hcl:"ports"`
+		Extra cty.Value `hcl:"extra"`
+		Rules []Rule    `hcl:"rule,block"`
+	}
+
+	src := `
+name  = "web-${var.env}"
+ports = [80, var.port]
+extra = var.env
+
+rule {
+  cidr = "10.0.0.0/8"
+}
+rule {
+  cidr = var.cidr
+}
+`
+	f, diags := hclsyntax.ParseConfig([]byte(src), "test.hcl", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var": cty.ObjectVal(map[string]cty.Value{
+				"env":  cty.UnknownVal(cty.String),
+				"port": cty.UnknownVal(cty.Number),
+				"cidr": cty.UnknownVal(cty.String),
+			}),
+		},
+	}
+
+	var got Config
+	if diags := DecodeBody(f.Body, ctx, &got); !diags.HasErrors() {
+		t.Errorf("DecodeBody succeeded; want errors for unknown values")
+	}
+
+	got = Config{}
+	unknowns, diags := PlanDecodeBody(f.Body, ctx, &got)
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	want := Config{
+		Extra: cty.UnknownVal(cty.String),
+		Rules: []Rule{
+			{CIDR: "10.0.0.0/8"},
+			{},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong result\ngot:  %s\nwant: %s", spew.Sdump(got), spew.Sdump(want))
+	}
+
+	gotUnknowns := map[string]string{}
+	for _, u := range unknowns {
+		var traversals []string
+		for _, traversal := range u.Traversals {
+			traversals = append(traversals, traversal.SourceRange().String())
+		}
+		gotUnknowns[fmt.Sprintf("%#v", u.Path)] = fmt.Sprintf("%s from %s", u.Range, traversals)
+	}
+	wantUnknowns := map[string]string{
+		fmt.Sprintf("%#v", cty.Path{cty.GetAttrStep{Name: "name"}}): "test.hcl:2,9-25 from [test.hcl:2,16-23]",
+		fmt.Sprintf("%#v", cty.Path{
+			cty.GetAttrStep{Name: "ports"}, cty.IndexStep{Key: cty.NumberIntVal(1)},
+		}): "test.hcl:3,9-23 from [test.hcl:3,14-22]",
+		fmt.Sprintf("%#v", cty.Path{cty.GetAttrStep{Name: "extra"}}): "test.hcl:4,9-16 from [test.hcl:4,9-16]",
+		fmt.Sprintf("%#v", cty.Path{
+			cty.GetAttrStep{Name: "rule"}, cty.IndexStep{Key: cty.NumberIntVal(1)}, cty.GetAttrStep{Name: "cidr"},
+		}): "test.hcl:10,10-18 from [test.hcl:10,10-18]",
+	}
+	if !reflect.DeepEqual(gotUnknowns, wantUnknowns) {
+		t.Errorf("wrong unknowns\ngot:  %s\nwant: %s", spew.Sdump(gotUnknowns), spew.Sdump(wantUnknowns))
+	}
+}
+
+type testExprDecoder struct {
+	Src string
+}
+
+func (d *testExprDecoder) DecodeExpression(expr hcl.Expression, ctx *hcl.EvalContext) hcl.Diagnostics {
+	rng := expr.Range()
+	d.Src = fmt.Sprintf("%s:%d", rng.Filename, rng.Start.Line)
+	return nil
+}
+
+func TestDecodeExpressionCustom(t *testing.T) {
+	type Config struct {
+		Timeout  time.Duration    `hcl:"timeout"`
+		Interval *time.Duration   `hcl:"interval"`
+		Address  net.IP           `hcl:"address"`
+		Endpoint *url.URL         `hcl:"endpoint"`
+		Pattern  *regexp.Regexp   `hcl:"pattern"`
+		Custom   testExprDecoder  `hcl:"custom"`
+		CustomP  *testExprDecoder `hcl:"custom_p"`
+		Amount   *big.Float       `hcl:"amount"`
+		Count    big.Int          `hcl:"count"`
+	}
+
+	src := `
+amount   = 1.5
+count    = 12
+timeout  = "1m30s"
+interval = null
+address  = "10.0.0.1"
+endpoint = "https://example.com/foo"
+pattern  = "^a+$"
+custom   = anything.at.all
+custom_p = 1 + 2
+`
+	f, diags := hclsyntax.ParseConfig([]byte(src), "test.hcl", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	var got Config
+	diags = DecodeBody(f.Body, nil, &got)
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	if got, want := got.Timeout, 90*time.Second; got != want {
+		t.Errorf("wrong timeout %s; want %s", got, want)
+	}
+	if got.Interval != nil {
+		t.Errorf("wrong interval %s; want nil", *got.Interval)
+	}
+	if got, want := got.Address, net.ParseIP("10.0.0.1"); !got.Equal(want) {
+		t.Errorf("wrong address %s; want %s", got, want)
+	}
+	if got.Endpoint == nil || got.Endpoint.Host != "example.com" || got.Endpoint.Path != "/foo" {
+		t.Errorf("wrong endpoint %#v", got.Endpoint)
+	}
+	if got.Pattern == nil || !got.Pattern.MatchString("aaa") || got.Pattern.MatchString("b") {
+		t.Errorf("wrong pattern %#v", got.Pattern)
+	}
+	if got, want := got.Custom.Src, "test.hcl:9"; got != want {
+		t.Errorf("wrong custom %q; want %q", got, want)
+	}
+	if got.CustomP == nil || got.CustomP.Src != "test.hcl:10" {
+		t.Errorf("wrong custom_p %#v", got.CustomP)
+	}
+	// These implement encoding.TextUnmarshaler, but are decoded from numbers
+	// by gocty rather than from strings.
+	if got.Amount == nil || got.Amount.Cmp(big.NewFloat(1.5)) != 0 {
+		t.Errorf("wrong amount %#v", got.Amount)
+	}
+	if got, want := got.Count.Int64(), int64(12); got != want {
+		t.Errorf("wrong count %d; want %d", got, want)
+	}
+}
+
+func TestDecodeExpressionCustomErrors(t *testing.T) {
+	tests := map[string]struct {
+		Target interface{}
+		Src    string
+		Detail string
+	}{
+		"duration": {
+			new(time.Duration),
+			`"soon"`,
+			`Unsuitable value: time: invalid duration "soon".`,
+		},
+		"ip": {
+			new(net.IP),
+			`"not-an-ip"`,
+			`Unsuitable value: invalid IP address: not-an-ip.`,
+		},
+		"regexp": {
+			new(*regexp.Regexp),
+			`"a("`,
+			"Unsuitable value: error parsing regexp: missing closing ): `a(`.",
+		},
+		"not string": {
+			new(time.Duration),
+			`["1s"]`,
+			`Unsuitable value: string required`,
+		},
+		"null": {
+			new(time.Duration),
+			`null`,
+			`Unsuitable value: value must not be null`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			expr, diags := hclsyntax.ParseExpression([]byte(test.Src), "test.hcl", hcl.Pos{Line: 1, Column: 1})
+			if diags.HasErrors() {
+				t.Fatal(diags.Error())
+			}
+			diags = DecodeExpression(expr, nil, test.Target)
+			if len(diags) != 1 {
+				t.Fatalf("wrong number of diagnostics %d; want 1\n%s", len(diags), diags.Error())
+			}
+			if got := diags[0].Detail; got != test.Detail {
+				t.Errorf("wrong detail\ngot:  %s\nwant: %s", got, test.Detail)
+			}
+			if got, want := *diags[0].Subject, expr.Range(); got != want {
+				t.Errorf("wrong subject %s; want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeBodySquashMapsDefaults(t *testing.T) {
+	type Common struct {
+		Name    string `hcl:"name"`
+		Enabled bool   `hcl:"enabled,optional,default=true"`
+	}
+	type Service struct {
+		Name    string        `hcl:"name,label"`
+		Port    int           `hcl:"port,default=8080"`
+		Timeout time.Duration `hcl:"timeout,optional,default=30s"`
+	}
+	type Resource struct {
+		Type string `hcl:"type,label"`
+		Name string `hcl:"name,label"`
+		Size int    `hcl:"size"`
+	}
+	type Config struct {
+		Common    `hcl:",squash"`
+		Services  map[string]Service              `hcl:"service,block"`
+		Resources map[string]map[string]*Resource `hcl:"resource,block"`
+	}
+
+	src := `
+name = "example"
+
+service "web" {
+  port = 80
+}
+service "db" {
+  timeout = "1m"
+}
+
+resource "disk" "a" {
+  size = 1
+}
+resource "disk" "b" {
+  size = 2
+}
+resource "nic" "a" {
+  size = 3
+}
+`
+	f, diags := hclsyntax.ParseConfig([]byte(src), "test.hcl", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	var got Config
+	diags = DecodeBody(f.Body, nil, &got)
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	want := Config{
+		Common: Common{
+			Name:    "example",
+			Enabled: true,
+		},
+		Services: map[string]Service{
+			"web": {Name: "web", Port: 80, Timeout: 30 * time.Second},
+			"db":  {Name: "db", Port: 8080, Timeout: time.Minute},
+		},
+		Resources: map[string]map[string]*Resource{
+			"disk": {
+				"a": {Type: "disk", Name: "a", Size: 1},
+				"b": {Type: "disk", Name: "b", Size: 2},
+			},
+			"nic": {
+				"a": {Type: "nic", Name: "a", Size: 3},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong result\ngot:  %s\nwant: %s", spew.Sdump(got), spew.Sdump(want))
+	}
+
+	f, diags = hclsyntax.ParseConfig([]byte(`
+name = "example"
+service "web" {}
+service "web" {}
+`), "dup.hcl", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+	diags = DecodeBody(f.Body, nil, &Config{})
+	if len(diags) != 1 || diags[0].Summary != "Duplicate service block" {
+		t.Errorf("wrong diagnostics for duplicate block: %s", diags.Error())
+	}
+}
+
+type testValidated struct {
+	Name     string `hcl:"name,label"`
+	Port     int    `hcl:"port,optional" hclvalidate:"min=1,max=65535"`
+	Protocol string `hcl:"protocol,optional" hclvalidate:"enum=tcp|udp"`
+	Host     string `hcl:"host,optional" hclvalidate:"required-one-of=target,regex=^[a-z.]+$"`
+	Address  string `hcl:"address,optional" hclvalidate:"required-one-of=target"`
+}
+
+func (v *testValidated) Validate(ctx *ValidateContext) hcl.Diagnostics {
+	if v.Protocol == "udp" && v.Port == 80 {
+		return hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid port",
+			Detail:   "Port 80 is for TCP.",
+			Subject:  ctx.Range("port").Ptr(),
+		}}
+	}
+	return nil
+}
+
+func TestDecodeBodyValidate(t *testing.T) {
+	type Config struct {
+		Services []testValidated `hcl:"service,block"`
+	}
+
+	tests := map[string]struct {
+		Src     string
+		Summary string
+		Detail  string
+		Subject string
+	}{
+		"valid": {
+			Src: `service "a" {
+  port     = 80
+  protocol = "tcp"
+  host     = "example.com"
+}`,
+		},
+		"min": {
+			Src: `service "a" {
+  port = 0
+  host = "example.com"
+}`,
+			Summary: "Invalid value",
+			Detail:  `Invalid value for "port": must be at least 1.`,
+			Subject: "test.hcl:2,10-11",
+		},
+		"max": {
+			Src: `service "a" {
+  port    = 100000
+  address = "10.0.0.1"
+}`,
+			Summary: "Invalid value",
+			Detail:  `Invalid value for "port": must be at most 65535.`,
+			Subject: "test.hcl:2,13-19",
+		},
+		"enum": {
+			Src: `service "a" {
+  protocol = "icmp"
+  host     = "example.com"
+}`,
+			Summary: "Invalid value",
+			Detail:  `Invalid value for "protocol": must be one of "tcp" or "udp".`,
+			Subject: "test.hcl:2,14-20",
+		},
+		"regex": {
+			Src: `service "a" {
+  host = "Example.com"
+}`,
+			Summary: "Invalid value",
+			Detail:  `Invalid value for "host": must match the regular expression "^[a-z.]+$".`,
+			Subject: "test.hcl:2,10-23",
+		},
+		"required one of": {
+			Src: `service "a" {
+  port = 80
+}`,
+			Summary: "Missing required argument",
+			Detail:  `At least one of "address" or "host" must be set.`,
+			Subject: "test.hcl:1,13-13",
+		},
+		"validator": {
+			Src: `service "a" {
+  port     = 80
+  protocol = "udp"
+  address  = "10.0.0.1"
+}`,
+			Summary: "Invalid port",
+			Detail:  "Port 80 is for TCP.",
+			Subject: "test.hcl:2,14-16",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, diags := hclsyntax.ParseConfig([]byte(test.Src), "test.hcl", hcl.Pos{Line: 1, Column: 1})
+			if diags.HasErrors() {
+				t.Fatal(diags.Error())
+			}
+			var got Config
+			diags = DecodeBody(f.Body, nil, &got)
+			if test.Summary == "" {
+				if len(diags) != 0 {
+					t.Fatalf("unexpected diagnostics: %s", diags.Error())
+				}
+				return
+			}
+			if len(diags) != 1 {
+				t.Fatalf("wrong number of diagnostics %d; want 1\n%s", len(diags), diags.Error())
+			}
+			if got := diags[0].Summary; got != test.Summary {
+				t.Errorf("wrong summary %q; want %q", got, test.Summary)
+			}
+			if got := diags[0].Detail; got != test.Detail {
+				t.Errorf("wrong detail\ngot:  %s\nwant: %s", got, test.Detail)
+			}
+			if got := diags[0].Subject.String(); got != test.Subject {
+				t.Errorf("wrong subject %s; want %s", got, test.Subject)
+			}
+		})
+	}
+}
+
+func TestDecodeBodyRanges(t *testing.T) {
+	type Rule struct {
+		Name      string                    `hcl:"name,label"`
+		Port      int                       `hcl:"port"`
+		PortRange hcl.Range                 `hcl:"port,range"`
+		DefRange  hcl.Range                 `hcl:",def_range"`
+		BodyRange *hcl.Range                `hcl:",body_range"`
+		Tags      map[string]hcl.Expression `hcl:"tags,optional"`
+		Ports     []hcl.Expression          `hcl:"ports,optional"`
+		Attr      hcl.Attribute             `hcl:"attr,optional"`
+		AttrP     *hcl.Attribute            `hcl:"attr_p,optional"`
+	}
+	type Config struct {
+		Rules     []Rule    `hcl:"rule,block"`
+		RuleRange hcl.Range `hcl:"rule,range"`
+		NameRange hcl.Range `hcl:"name,range"`
+		Name      string    `hcl:"name,optional"`
+	}
+
+	src := `rule "a" {
+  port  = 80
+  tags  = { env = "prod", "owner" = var.owner }
+  ports = [80, 443]
+  attr  = 1
+}
+`
+	f, diags := hclsyntax.ParseConfig([]byte(src), "test.hcl", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	got := Config{
+		NameRange: hcl.Range{Filename: "stale"},
+		Rules: []Rule{{
+			AttrP: &hcl.Attribute{Name: "stale"},
+		}},
+	}
+	diags = DecodeBody(f.Body, nil, &got)
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	if got, want := got.RuleRange.String(), "test.hcl:1,1-9"; got != want {
+		t.Errorf("wrong rule range %s; want %s", got, want)
+	}
+	if got.NameRange != (hcl.Range{}) {
+		t.Errorf("wrong name range %s; want zero range", got.NameRange)
+	}
+	rule := got.Rules[0]
+	if got, want := rule.PortRange.String(), "test.hcl:2,3-13"; got != want {
+		t.Errorf("wrong port range %s; want %s", got, want)
+	}
+	if got, want := rule.DefRange.String(), "test.hcl:1,1-9"; got != want {
+		t.Errorf("wrong def range %s; want %s", got, want)
+	}
+	if got, want := rule.BodyRange.String(), "test.hcl:1,10-6,2"; got != want {
+		t.Errorf("wrong body range %s; want %s", got, want)
+	}
+	if got, want := len(rule.Tags), 2; got != want {
+		t.Errorf("wrong number of tags %d; want %d", got, want)
+	} else if got, want := rule.Tags["owner"].Range().String(), "test.hcl:3,37-46"; got != want {
+		t.Errorf("wrong range for owner tag %s; want %s", got, want)
+	}
+	if got, want := len(rule.Ports), 2; got != want {
+		t.Errorf("wrong number of ports %d; want %d", got, want)
+	}
+	if got, want := rule.Attr.Name, "attr"; got != want {
+		t.Errorf("wrong attr name %q; want %q", got, want)
+	}
+	if rule.AttrP != nil {
+		t.Errorf("attr_p is %#v; want nil", rule.AttrP)
+	}
+}
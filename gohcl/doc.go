@@ -18,16 +18,47 @@
 //    block indicates that the value is to populated from a block
 //    label indicates that the value is to populated from a block label
 //    remain indicates that the value is to be populated from the remaining body after populating other fields
+//    optional is the same as attr, but the attribute may be omitted from configuration
+//    squash indicates that the fields of a struct are to be treated as if they were fields of the parent struct
+//    range indicates that the value is to be populated with the source range of the named attribute, or of the header of the first block of the named type
+//    def_range indicates that the value is to be populated with the source range of the header of the block being decoded
+//    body_range indicates that the value is to be populated with the source range of the body being decoded
 //
-// "attr" fields may either be of type *hcl.Expression, in which case the raw
-// expression is assigned, or of any type accepted by gocty, in which case
-// gocty will be used to assign the value to a native Go type.
+// A struct field, usually an embedded one, is squashed only if it has a tag
+// like `hcl:",squash"`, and is otherwise ignored like any other untagged
+// field. As with Go's own embedding rules, a field of the outer struct takes
+// precedence over one of the same name in a squashed struct.
+//
+// An "attr" or "optional" tag may be followed by a default value, as in
+// `hcl:"port,optional,default=8080"`, which is used when the attribute is
+// absent and implies that the attribute is optional. The default is written
+// without quotes and is converted to the field's type as if it were a string
+// given in configuration. Since it is the last item in the tag, it may
+// itself contain commas.
+//
+// "attr" fields may either be of type hcl.Expression, in which case the raw
+// expression is assigned, of type *hcl.Attribute or hcl.Attribute, in which
+// case the whole attribute is assigned, of type []hcl.Expression or
+// map[string]hcl.Expression, in which case the unevaluated elements of a
+// tuple or object constructor are assigned, or of any type accepted by gocty,
+// in which case gocty will be used to assign the value to a native Go type.
+// An absent attribute is represented by an expression that returns null for
+// hcl.Expression fields and by the zero value for attribute fields. Types that
+// implement ExpressionDecoder or encoding.TextUnmarshaler, along with
+// time.Duration and url.URL, are also accepted; see DecodeExpression for
+// details.
 //
 // "block" fields may be of type *hcl.Block or hcl.Body, in which case the
 // corresponding raw value is assigned, or may be a struct that recursively
 // uses the same tags. Block fields may also be slices of any of these types,
 // in which case multiple blocks of the corresponding type are decoded into
-// the slice.
+// the slice, or maps with string keys of structs or pointers to structs, in
+// which case the blocks are decoded into map elements keyed by their first
+// label. Maps of maps are keyed by the first two labels, and so on, so that
+// for example a map[string]map[string]T field collects blocks with two labels
+// into nested maps. The struct type must have at least as many "label" fields
+// as there are levels of maps, and these fields are populated with the keys
+// too.
 //
 // "label" fields are considered only in a struct used as the type of a field
 // marked as "block", and are used sequentially to capture the labels of
@@ -40,9 +71,40 @@
 // present then any attributes or blocks not matched by another valid tag
 // will cause an error diagnostic.
 //
+// Attribute fields may also have an "hclvalidate" tag giving rules that the
+// decoded value must follow, which are checked only if the attribute is set
+// in configuration:
+//
+//    min=N and max=N give bounds for the values of number fields
+//    regex=PATTERN requires string values to match a regular expression, and must be the last rule in the tag
+//    enum=a|b|c requires the value to be one of the given alternatives
+//    required-one-of=GROUP requires that at least one attribute of all of those in the named group is set
+//
+// For example:
+//
+//    Port int `hcl:"port,optional" hclvalidate:"min=1,max=65535"`
+//
+// Structs may also implement the Validator interface to check rules that
+// can't be expressed with tags, with access to the source ranges of their
+// attributes and blocks for use in diagnostics.
+//
+// "range", "def_range" and "body_range" fields must be of type hcl.Range or
+// *hcl.Range, and allow an application to report problems it detects after
+// decoding at the right location in configuration. A "range" field is set to
+// the zero value if the named item is absent, while a "def_range" field is
+// left unchanged when decoding a body that is not part of a block. Only some
+// body implementations can report their full range, so "body_range" fields
+// are set to the body's missing item range for others.
+//
 // Only a subset of this tagging/typing vocabulary is supported for the
 // "Encode" family of functions. See the EncodeIntoBody docs for full details
-// on the constraints there.
+// on the constraints there. Two further tag options affect only encoding:
+// "omitempty" causes an attribute or block to be left out when the field has
+// its zero value or is an empty slice, map or string, and "comment" gives
+// text to write as a comment before a newly-added attribute or block, as in
+// `hcl:"port,optional,omitempty,comment=\"The port to listen on\""`. The
+// comment may be given either as a quoted string or, if it contains no
+// commas, without quotes.
 //
 // Broadly-speaking this package deals with two types of error. The first is
 // errors in the configuration itself, which are returned as diagnostics
@@ -4,15 +4,64 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strings"
 
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
 	"github.com/hashicorp/hcl2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
 	"github.com/zclconf/go-cty/cty/gocty"
 )
 
-// EncodeIntoBody replaces the contents of the given hclwrite Body with
-// attributes and blocks derived from the given value, which must be a
-// struct value or a pointer to a struct value with the struct tags defined
-// in this package.
+// EncodeOrder selects the order in which attributes and blocks that are not
+// already present in the destination body are written by
+// EncodeIntoBodyWithOptions.
+type EncodeOrder int
+
+const (
+	// EncodeFieldOrder writes attributes and blocks in the order of the
+	// corresponding struct fields.
+	EncodeFieldOrder EncodeOrder = iota
+
+	// EncodeAttributesFirst writes all of the attributes before all of the
+	// blocks, each in the order of the corresponding struct fields.
+	EncodeAttributesFirst
+
+	// EncodeSortedOrder writes all of the attributes, sorted by name, before
+	// all of the blocks, sorted by block type name.
+	EncodeSortedOrder
+)
+
+// EncodeOptions customizes the behavior of EncodeIntoBodyWithOptions.
+type EncodeOptions struct {
+	Order EncodeOrder
+}
+
+// EncodeIntoBody updates the contents of the given hclwrite Body with
+// attributes and blocks derived from the given value, which must be either
+// a struct value or a pointer to a struct value with the struct tags defined
+// in this package, or a map with string keys whose elements are each written
+// as an attribute.
+//
+// The body is updated in place, so that it may be one that was parsed from
+// an existing configuration file:
+//
+//   - An attribute that is already present has its expression replaced, unless
+//     it is a literal of the same value, and keeps its comments. An attribute
+//     whose field is a nil pointer or is empty and tagged "omitempty" is
+//     removed.
+//   - Blocks that are already present are updated in place, recursively, when
+//     they have the same labels as the corresponding value. Blocks decoded
+//     into a slice are matched by position, and those decoded into a map are
+//     matched by their labels. Existing blocks with no corresponding value
+//     are removed.
+//   - New attributes and blocks are appended to the body. If the field has a
+//     "comment" tag option then its text is written as a comment before the
+//     new attribute, or before the first new block if there were no blocks
+//     of that type already.
+//   - Attributes and blocks that do not correspond to any struct field are
+//     left untouched.
 //
 // This function can work only with fully-decoded data. It will ignore any
 // fields tagged as "remain", any fields that decode attributes into either
@@ -20,6 +69,12 @@ import (
 // into hcl.Attributes values. This function does not have enough information
 // to complete the decoding of these types.
 //
+// Fields whose types are decoded from strings, such as time.Duration and
+// types implementing encoding.TextUnmarshaler, and fields whose types
+// implement ExpressionDecoder, are written as strings using their
+// MarshalText or String methods. If such a type has neither method then the
+// corresponding attribute is left untouched.
+//
 // Any fields tagged as "label" are ignored by this function. Use EncodeAsBlock
 // to produce a whole hclwrite.Block including block labels.
 //
@@ -28,24 +83,39 @@ import (
 // any errors in the calling program, such as passing an inappropriate type
 // or a nil body.
 //
-// The layout of the resulting HCL source is derived from the ordering of
-// the struct fields, with blank lines around nested blocks of different types.
-// Fields representing attributes should usually precede those representing
-// blocks so that the attributes can group togather in the result. For more
-// control, use the hclwrite API directly.
+// The layout of new items in the resulting HCL source is derived from the
+// ordering of the struct fields, with blank lines around nested blocks of
+// different types. Fields representing attributes should usually precede
+// those representing blocks so that the attributes can group togather in the
+// result. Use EncodeIntoBodyWithOptions to choose a different order, or use
+// the hclwrite API directly for more control.
 func EncodeIntoBody(val interface{}, dst *hclwrite.Body) {
+	EncodeIntoBodyWithOptions(val, dst, EncodeOptions{})
+}
+
+// EncodeIntoBodyWithOptions is like EncodeIntoBody except that it accepts
+// options to customize its behavior.
+func EncodeIntoBodyWithOptions(val interface{}, dst *hclwrite.Body, opts EncodeOptions) {
 	rv := reflect.ValueOf(val)
-	ty := rv.Type()
-	if ty.Kind() == reflect.Ptr {
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			panic(fmt.Sprintf("cannot encode nil %s", rv.Type()))
+		}
 		rv = rv.Elem()
-		ty = rv.Type()
-	}
-	if ty.Kind() != reflect.Struct {
-		panic(fmt.Sprintf("value is %s, not struct", ty.Kind()))
 	}
 
-	tags := getFieldTags(ty)
-	populateBody(rv, ty, tags, dst)
+	switch rv.Kind() {
+	case reflect.Struct:
+		ty := rv.Type()
+		populateBody(rv, ty, getFieldTags(ty), dst, opts)
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			panic(fmt.Sprintf("cannot encode %s: map keys must be strings", rv.Type()))
+		}
+		populateBodyFromMap(rv, dst)
+	default:
+		panic(fmt.Sprintf("cannot encode %s: value must be a struct or a map", rv.Type()))
+	}
 }
 
 // EncodeAsBlock creates a new hclwrite.Block populated with the data from
@@ -58,32 +128,49 @@ func EncodeIntoBody(val interface{}, dst *hclwrite.Body) {
 // This function has the same constraints as EncodeIntoBody and will panic
 // if they are violated.
 func EncodeAsBlock(val interface{}, blockType string) *hclwrite.Block {
+	return encodeAsBlock(structValue(val), blockType, nil, EncodeOptions{})
+}
+
+// structValue returns the struct value that the given value is or points to,
+// or panics if it is neither.
+func structValue(val interface{}) reflect.Value {
 	rv := reflect.ValueOf(val)
-	ty := rv.Type()
-	if ty.Kind() == reflect.Ptr {
+	if rv.Kind() == reflect.Ptr {
 		rv = rv.Elem()
-		ty = rv.Type()
 	}
-	if ty.Kind() != reflect.Struct {
-		panic(fmt.Sprintf("value is %s, not struct", ty.Kind()))
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("value is %s, not struct", rv.Kind()))
 	}
+	return rv
+}
 
+// encodeAsBlock creates a new block from the given struct value, using the
+// given keys as the first labels of the block instead of the values of the
+// corresponding label fields.
+func encodeAsBlock(rv reflect.Value, blockType string, keys []string, opts EncodeOptions) *hclwrite.Block {
+	ty := rv.Type()
 	tags := getFieldTags(ty)
+	block := hclwrite.NewBlock(blockType, blockLabels(rv, tags, keys))
+	populateBody(rv, ty, tags, block.Body(), opts)
+	return block
+}
+
+// blockLabels returns the labels for a block representing the given struct
+// value, taking the first labels from the given keys if any.
+func blockLabels(rv reflect.Value, tags *fieldTags, keys []string) []string {
 	labels := make([]string, len(tags.Labels))
 	for i, lf := range tags.Labels {
-		lv := rv.Field(lf.FieldIndex)
+		lv := rv.FieldByIndex(lf.FieldIndex)
 		// We just stringify whatever we find. It should always be a string
 		// but if not then we'll still do something reasonable.
 		labels[i] = fmt.Sprintf("%s", lv.Interface())
 	}
-
-	block := hclwrite.NewBlock(blockType, labels)
-	populateBody(rv, ty, tags, block.Body())
-	return block
+	copy(labels, keys)
+	return labels
 }
 
-func populateBody(rv reflect.Value, ty reflect.Type, tags *fieldTags, dst *hclwrite.Body) {
-	nameIdxs := make(map[string]int, len(tags.Attributes)+len(tags.Blocks))
+func populateBody(rv reflect.Value, ty reflect.Type, tags *fieldTags, dst *hclwrite.Body, opts EncodeOptions) {
+	nameIdxs := make(map[string][]int, len(tags.Attributes)+len(tags.Blocks))
 	namesOrder := make([]string, 0, len(tags.Attributes)+len(tags.Blocks))
 	for n, i := range tags.Attributes {
 		nameIdxs[n] = i
@@ -95,17 +182,26 @@ func populateBody(rv reflect.Value, ty reflect.Type, tags *fieldTags, dst *hclwr
 	}
 	sort.SliceStable(namesOrder, func(i, j int) bool {
 		ni, nj := namesOrder[i], namesOrder[j]
-		return nameIdxs[ni] < nameIdxs[nj]
+		if opts.Order != EncodeFieldOrder {
+			_, iAttr := tags.Attributes[ni]
+			_, jAttr := tags.Attributes[nj]
+			if iAttr != jAttr {
+				return iAttr
+			}
+			if opts.Order == EncodeSortedOrder {
+				return ni < nj
+			}
+		}
+		return indexLess(nameIdxs[ni], nameIdxs[nj])
 	})
 
-	dst.Clear()
-
 	prevWasBlock := false
 	for _, name := range namesOrder {
 		fieldIdx := nameIdxs[name]
-		field := ty.Field(fieldIdx)
+		field := ty.FieldByIndex(fieldIdx)
 		fieldTy := field.Type
-		fieldVal := rv.Field(fieldIdx)
+		fieldVal := rv.FieldByIndex(fieldIdx)
+		omit := tags.OmitEmpty[name] && isEmptyValue(fieldVal)
 
 		if fieldTy.Kind() == reflect.Ptr {
 			fieldTy = fieldTy.Elem()
@@ -114,40 +210,35 @@ func populateBody(rv reflect.Value, ty reflect.Type, tags *fieldTags, dst *hclwr
 
 		if _, isAttr := tags.Attributes[name]; isAttr {
 
-			if exprType.AssignableTo(fieldTy) || attrType.AssignableTo(fieldTy) {
+			if exprType.AssignableTo(fieldTy) || attrType.AssignableTo(fieldTy) || fieldTy == attrValType ||
+				fieldTy == exprListType || fieldTy == exprMapType {
 				continue // ignore undecoded fields
 			}
-			if !fieldVal.IsValid() {
-				continue // ignore (field value is nil pointer)
+			if omit || !fieldVal.IsValid() {
+				// An omitted value or nil pointer means the attribute is
+				// not set, so we remove any existing definition of it.
+				dst.RemoveAttribute(name)
+				continue
 			}
 			if fieldTy.Kind() == reflect.Ptr && fieldVal.IsNil() {
 				continue // ignore
 			}
-			if prevWasBlock {
+			if prevWasBlock && dst.GetAttribute(name) == nil {
 				dst.AppendNewline()
 				prevWasBlock = false
 			}
 
-			valTy, err := gocty.ImpliedType(fieldVal.Interface())
-			if err != nil {
-				panic(fmt.Sprintf("cannot encode %T as HCL expression: %s", fieldVal.Interface(), err))
-			}
-
-			val, err := gocty.ToCtyValue(fieldVal.Interface(), valTy)
-			if err != nil {
-				// This should never happen, since we should always be able
-				// to decode into the implied type.
-				panic(fmt.Sprintf("failed to encode %T as %#v: %s", fieldVal.Interface(), valTy, err))
-			}
-
-			dst.SetAttributeValue(name, val)
+			setAttribute(dst, name, fieldVal, tags.Comments[name])
 
 		} else { // must be a block, then
 			elemTy := fieldTy
 			isSeq := false
+			mapDepth := 0
 			if elemTy.Kind() == reflect.Slice || elemTy.Kind() == reflect.Array {
 				isSeq = true
 				elemTy = elemTy.Elem()
+			} else {
+				mapDepth, elemTy = blockMapDepth(elemTy)
 			}
 
 			if bodyType.AssignableTo(elemTy) || attrsType.AssignableTo(elemTy) {
@@ -155,37 +246,248 @@ func populateBody(rv reflect.Value, ty reflect.Type, tags *fieldTags, dst *hclwr
 			}
 			prevWasBlock = false
 
-			if isSeq {
+			var vals []blockValue
+			switch {
+			case omit:
+				// no blocks at all, then
+			case mapDepth > 0:
+				vals = blockMapValues(fieldVal, mapDepth, nil)
+			case isSeq:
 				l := fieldVal.Len()
 				for i := 0; i < l; i++ {
 					elemVal := fieldVal.Index(i)
+					if elemVal.Kind() == reflect.Ptr {
+						elemVal = elemVal.Elem()
+					}
 					if !elemVal.IsValid() {
 						continue // ignore (elem value is nil pointer)
 					}
-					if elemTy.Kind() == reflect.Ptr && elemVal.IsNil() {
-						continue // ignore
-					}
-					block := EncodeAsBlock(elemVal.Interface(), name)
-					if !prevWasBlock {
-						dst.AppendNewline()
-						prevWasBlock = true
-					}
-					dst.AppendBlock(block)
-				}
-			} else {
-				if !fieldVal.IsValid() {
-					continue // ignore (field value is nil pointer)
+					vals = append(vals, blockValue{val: elemVal})
 				}
-				if elemTy.Kind() == reflect.Ptr && fieldVal.IsNil() {
-					continue // ignore
+			default:
+				if fieldVal.IsValid() {
+					vals = append(vals, blockValue{val: fieldVal})
 				}
-				block := EncodeAsBlock(fieldVal.Interface(), name)
-				if !prevWasBlock {
-					dst.AppendNewline()
-					prevWasBlock = true
-				}
-				dst.AppendBlock(block)
 			}
+
+			updateBlocks(dst, name, vals, mapDepth, tags.Comments[name], &prevWasBlock, opts)
+		}
+	}
+}
+
+// populateBodyFromMap sets an attribute in the given body for each element
+// of the given map, in lexical order of their keys.
+func populateBodyFromMap(mv reflect.Value, dst *hclwrite.Body) {
+	keys := mv.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	for _, k := range keys {
+		name := k.String()
+		elemVal := mv.MapIndex(k)
+		for elemVal.Kind() == reflect.Ptr || elemVal.Kind() == reflect.Interface {
+			if elemVal.IsNil() {
+				break
+			}
+			elemVal = elemVal.Elem()
+		}
+		if (elemVal.Kind() == reflect.Ptr || elemVal.Kind() == reflect.Interface) && elemVal.IsNil() {
+			dst.RemoveAttribute(name)
+			continue
+		}
+		setAttribute(dst, name, elemVal, "")
+	}
+}
+
+// setAttribute sets the attribute of the given name to the given value,
+// keeping its existing expression if that is a literal of the same value.
+// The given comment is written before the attribute only if it is new.
+//
+// Values of the types that decode from strings, or that decode themselves
+// from expressions, are written as strings using their MarshalText or
+// String methods. If such a value has neither method then any existing
+// attribute is left untouched, since we cannot know how to write it.
+func setAttribute(dst *hclwrite.Body, name string, fieldVal reflect.Value, comment string) {
+	var val cty.Value
+	if ty := fieldVal.Type(); isExprDecoderType(ty) || isTextType(ty) {
+		text, ok, err := encodeText(fieldVal)
+		if err != nil {
+			panic(fmt.Sprintf("failed to encode %T as text: %s", fieldVal.Interface(), err))
+		}
+		if !ok {
+			return
+		}
+		val = cty.StringVal(text)
+	} else {
+		valTy, err := gocty.ImpliedType(fieldVal.Interface())
+		if err != nil {
+			panic(fmt.Sprintf("cannot encode %T as HCL expression: %s", fieldVal.Interface(), err))
+		}
+
+		val, err = gocty.ToCtyValue(fieldVal.Interface(), valTy)
+		if err != nil {
+			// This should never happen, since we should always be able
+			// to decode into the implied type.
+			panic(fmt.Sprintf("failed to encode %T as %#v: %s", fieldVal.Interface(), valTy, err))
+		}
+	}
+
+	if attr := dst.GetAttribute(name); attr != nil {
+		if !exprHasValue(attr.Expr(), val) {
+			dst.SetAttributeValue(name, val)
+		}
+		return
+	}
+
+	attr := dst.SetAttributeValue(name, val)
+	if comment != "" {
+		attr.SetLeadComment(comment)
+	}
+}
+
+// exprHasValue returns true if the given expression is a literal, needing no
+// variables or functions, whose value is equal to the given value once
+// converted to its type.
+func exprHasValue(expr *hclwrite.Expression, want cty.Value) bool {
+	src := expr.BuildTokens(nil).Bytes()
+	hclExpr, diags := hclsyntax.ParseExpression(src, "", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() || len(hclExpr.Variables()) > 0 {
+		return false
+	}
+	got, diags := hclExpr.Value(nil)
+	if diags.HasErrors() || !got.IsWhollyKnown() {
+		return false
+	}
+	got, err := convert.Convert(got, want.Type())
+	if err != nil {
+		return false
+	}
+	return got.Equals(want).True()
+}
+
+// blockValue is a struct value to be written as a block, along with any keys
+// from the block map it belongs to.
+type blockValue struct {
+	val  reflect.Value
+	keys []string
+}
+
+// blockMapValues returns the values of the given map, which has the given
+// number of levels of nested maps, in lexical order of their keys.
+func blockMapValues(mv reflect.Value, depth int, keys []string) []blockValue {
+	if !mv.IsValid() || mv.IsNil() {
+		return nil
+	}
+
+	mapKeys := mv.MapKeys()
+	sort.Slice(mapKeys, func(i, j int) bool {
+		return mapKeys[i].String() < mapKeys[j].String()
+	})
+
+	var ret []blockValue
+	for _, k := range mapKeys {
+		elemKeys := append(append([]string(nil), keys...), k.String())
+		elemVal := mv.MapIndex(k)
+		if depth > 1 {
+			ret = append(ret, blockMapValues(elemVal, depth-1, elemKeys)...)
+			continue
+		}
+		if elemVal.Kind() == reflect.Ptr {
+			if elemVal.IsNil() {
+				continue // ignore
+			}
+			elemVal = elemVal.Elem()
+		}
+
+		ret = append(ret, blockValue{val: elemVal, keys: elemKeys})
+	}
+	return ret
+}
+
+// updateBlocks makes the blocks of the given type in the given body
+// correspond to the given values, updating existing blocks in place where
+// possible.
+//
+// If mapDepth is zero then existing blocks are matched with values by
+// position. Otherwise, they are matched by their first mapDepth labels.
+func updateBlocks(dst *hclwrite.Body, blockType string, vals []blockValue, mapDepth int, comment string, prevWasBlock *bool, opts EncodeOptions) {
+	var existing []*hclwrite.Block
+	byKey := map[string]*hclwrite.Block{}
+	for _, block := range dst.Blocks() {
+		if block.Type() != blockType {
+			continue
+		}
+		existing = append(existing, block)
+		if labels := block.Labels(); mapDepth > 0 && len(labels) >= mapDepth {
+			key := strings.Join(labels[:mapDepth], "\x00")
+			if _, exists := byKey[key]; !exists {
+				byKey[key] = block
+			}
+		}
+	}
+
+	used := map[*hclwrite.Block]bool{}
+	commented := len(existing) > 0
+	for i, bv := range vals {
+		ty := bv.val.Type()
+		tags := getFieldTags(ty)
+		labels := blockLabels(bv.val, tags, bv.keys)
+
+		var match *hclwrite.Block
+		if mapDepth > 0 {
+			match = byKey[strings.Join(bv.keys, "\x00")]
+		} else if i < len(existing) {
+			match = existing[i]
+		}
+		if match != nil && stringsEqual(match.Labels(), labels) {
+			used[match] = true
+			populateBody(bv.val, ty, tags, match.Body(), opts)
+			continue
+		}
+
+		block := hclwrite.NewBlock(blockType, labels)
+		populateBody(bv.val, ty, tags, block.Body(), opts)
+		if !commented && comment != "" {
+			block.SetLeadComment(comment)
+			commented = true
+		}
+		if !*prevWasBlock {
+			dst.AppendNewline()
+			*prevWasBlock = true
+		}
+		dst.AppendBlock(block)
+	}
+
+	for _, block := range existing {
+		if !used[block] {
+			dst.RemoveBlock(block)
+		}
+	}
+}
+
+// isEmptyValue returns true if the given field value is empty for the
+// purposes of the "omitempty" tag option, using the same rules as the
+// encoding/json package.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	default:
+		return v.IsZero()
+	}
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
 		}
 	}
+	return true
 }
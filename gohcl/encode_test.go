@@ -2,8 +2,15 @@ package gohcl_test
 
 import (
 	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
 
 	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
 	"github.com/hashicorp/hcl2/hclwrite"
 )
 
@@ -62,3 +69,248 @@ func ExampleEncodeIntoBody() {
 	//   executable = ["./worker"]
 	// }
 }
+
+func ExampleEncodeIntoBody_maps() {
+	type Common struct {
+		Name string `hcl:"name"`
+	}
+	type Resource struct {
+		Type string `hcl:"type,label"`
+		Name string `hcl:"name,label"`
+		Size int    `hcl:"size"`
+	}
+	type Config struct {
+		Common    `hcl:",squash"`
+		Resources map[string]map[string]Resource `hcl:"resource,block"`
+	}
+
+	config := Config{
+		Common: Common{Name: "example"},
+		Resources: map[string]map[string]Resource{
+			"nic": {
+				"a": {Size: 3},
+			},
+			"disk": {
+				"b": {Size: 2},
+				"a": {Size: 1},
+			},
+		},
+	}
+
+	f := hclwrite.NewEmptyFile()
+	gohcl.EncodeIntoBody(&config, f.Body())
+	fmt.Printf("%s", f.Bytes())
+
+	// Output:
+	// name = "example"
+	//
+	// resource "disk" "a" {
+	//   size = 1
+	// }
+	// resource "disk" "b" {
+	//   size = 2
+	// }
+	// resource "nic" "a" {
+	//   size = 3
+	// }
+}
+
+func ExampleEncodeIntoBody_update() {
+	type Listener struct {
+		Name string `hcl:"name,label"`
+		Port int    `hcl:"port"`
+		TLS  bool   `hcl:"tls,optional,omitempty"`
+		Cert string `hcl:"cert,optional,omitempty,comment=\"Path to the certificate, in PEM format\""`
+	}
+	type Server struct {
+		Name      string              `hcl:"name"`
+		Debug     bool                `hcl:"debug,optional,omitempty"`
+		Tags      map[string]string   `hcl:"tags,optional,omitempty"`
+		Listeners map[string]Listener `hcl:"listener,block,comment=Network listeners"`
+	}
+
+	src := `# The server's name
+name  = "old"   # renamed below
+debug = true
+
+listener "http" {
+  # plain HTTP
+  port = 80
+}
+
+listener "admin" {
+  port = 8081
+}
+`
+	f, diags := hclwrite.ParseConfig([]byte(src), "server.hcl", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		fmt.Println(diags.Error())
+		return
+	}
+
+	server := Server{
+		Name: "new",
+		Listeners: map[string]Listener{
+			"http":  {Port: 80},
+			"https": {Port: 443, TLS: true, Cert: "server.pem"},
+		},
+	}
+	gohcl.EncodeIntoBody(&server, f.Body())
+	fmt.Printf("%s", f.Bytes())
+
+	// Output:
+	// # The server's name
+	// name = "new" # renamed below
+	//
+	// listener "http" {
+	//   # plain HTTP
+	//   port = 80
+	// }
+	//
+	// listener "https" {
+	//   port = 443
+	//   tls  = true
+	//   # Path to the certificate, in PEM format
+	//   cert = "server.pem"
+	// }
+}
+
+func ExampleEncodeIntoBodyWithOptions() {
+	type Logging struct {
+		Level string `hcl:"level"`
+	}
+	type Config struct {
+		Logging *Logging `hcl:"logging,block,comment=Logging settings"`
+		Version int      `hcl:"version"`
+		Name    string   `hcl:"name,comment=\"A name, for display\""`
+		Region  string   `hcl:"region,optional,omitempty"`
+	}
+
+	config := Config{
+		Logging: &Logging{Level: "info"},
+		Version: 2,
+		Name:    "example",
+	}
+
+	f := hclwrite.NewEmptyFile()
+	gohcl.EncodeIntoBodyWithOptions(&config, f.Body(), gohcl.EncodeOptions{
+		Order: gohcl.EncodeSortedOrder,
+	})
+	fmt.Printf("%s", f.Bytes())
+
+	// Output:
+	// # A name, for display
+	// name    = "example"
+	// version = 2
+	//
+	// # Logging settings
+	// logging {
+	//   level = "info"
+	// }
+}
+
+func ExampleEncodeIntoBody_map() {
+	vars := map[string]interface{}{
+		"region":   "eu-west-1",
+		"replicas": 3,
+		"zones":    []string{"a", "b"},
+	}
+
+	f := hclwrite.NewEmptyFile()
+	gohcl.EncodeIntoBody(vars, f.Body())
+	fmt.Printf("%s", f.Bytes())
+
+	// Output:
+	// region   = "eu-west-1"
+	// replicas = 3
+	// zones    = ["a", "b"]
+}
+
+// testTextExprDecoder decodes itself from the source text of an expression,
+// and encodes as a string containing that text.
+type testTextExprDecoder struct {
+	Src string
+}
+
+func (d *testTextExprDecoder) DecodeExpression(expr hcl.Expression, ctx *hcl.EvalContext) hcl.Diagnostics {
+	val, diags := expr.Value(ctx)
+	if !diags.HasErrors() {
+		d.Src = val.AsString()
+	}
+	return diags
+}
+
+func (d testTextExprDecoder) MarshalText() ([]byte, error) {
+	return []byte(d.Src), nil
+}
+
+func TestEncodeIntoBodyText(t *testing.T) {
+	type Config struct {
+		Timeout  time.Duration       `hcl:"timeout"`
+		Interval *time.Duration      `hcl:"interval"`
+		Address  net.IP              `hcl:"address"`
+		Endpoint *url.URL            `hcl:"endpoint"`
+		Base     url.URL             `hcl:"base"`
+		Pattern  *regexp.Regexp      `hcl:"pattern"`
+		Custom   testTextExprDecoder `hcl:"custom"`
+	}
+
+	interval := 5 * time.Second
+	endpoint, _ := url.Parse("https://example.com/foo?a=b")
+	base, _ := url.Parse("http://localhost:8080/")
+	config := Config{
+		Timeout:  90 * time.Second,
+		Interval: &interval,
+		Address:  net.ParseIP("10.0.0.1"),
+		Endpoint: endpoint,
+		Base:     *base,
+		Pattern:  regexp.MustCompile(`^a+$`),
+		Custom:   testTextExprDecoder{Src: "custom"},
+	}
+
+	f := hclwrite.NewEmptyFile()
+	gohcl.EncodeIntoBody(&config, f.Body())
+	want := `timeout  = "1m30s"
+interval = "5s"
+address  = "10.0.0.1"
+endpoint = "https://example.com/foo?a=b"
+base     = "http://localhost:8080/"
+pattern  = "^a+$"
+custom   = "custom"
+`
+	if got := string(f.Bytes()); got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	parsed, diags := hclsyntax.ParseConfig(f.Bytes(), "test.hcl", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+	var got Config
+	diags = gohcl.DecodeBody(parsed.Body, nil, &got)
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	if got.Timeout != config.Timeout {
+		t.Errorf("wrong timeout %s; want %s", got.Timeout, config.Timeout)
+	}
+	if got.Interval == nil || *got.Interval != interval {
+		t.Errorf("wrong interval %v; want %s", got.Interval, interval)
+	}
+	if !got.Address.Equal(config.Address) {
+		t.Errorf("wrong address %s; want %s", got.Address, config.Address)
+	}
+	if got.Endpoint == nil || got.Endpoint.String() != endpoint.String() {
+		t.Errorf("wrong endpoint %v; want %s", got.Endpoint, endpoint)
+	}
+	if got.Base.String() != base.String() {
+		t.Errorf("wrong base %s; want %s", &got.Base, base)
+	}
+	if got.Pattern == nil || got.Pattern.String() != config.Pattern.String() {
+		t.Errorf("wrong pattern %v; want %s", got.Pattern, config.Pattern)
+	}
+	if got.Custom != config.Custom {
+		t.Errorf("wrong custom %#v; want %#v", got.Custom, config.Custom)
+	}
+}
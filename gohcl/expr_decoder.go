@@ -0,0 +1,261 @@
+package gohcl
+
+import (
+	"encoding"
+	"fmt"
+	"math/big"
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+// ExpressionDecoder is implemented by types that decode themselves directly
+// from an HCL expression, rather than from a value that has been evaluated
+// and then converted to the target type using gocty.
+//
+// When decoding an attribute into a value whose pointer type implements this
+// interface, the DecodeExpression method is called with the attribute's
+// expression and the EvalContext given to the decode function. The method
+// is responsible for evaluating the expression, if needed, and for returning
+// diagnostics for any problems with it. If the target field is itself a
+// pointer then a new value is allocated before calling the method.
+type ExpressionDecoder interface {
+	DecodeExpression(expr hcl.Expression, ctx *hcl.EvalContext) hcl.Diagnostics
+}
+
+var exprDecoderType = reflect.TypeOf((*ExpressionDecoder)(nil)).Elem()
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+var stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+var durationType = reflect.TypeOf(time.Duration(0))
+var urlType = reflect.TypeOf(url.URL{})
+var bigIntType = reflect.TypeOf(big.Int{})
+var bigFloatType = reflect.TypeOf(big.Float{})
+
+// isExprDecoderType returns true if values of the given type decode
+// themselves from expressions, via ExpressionDecoder.
+func isExprDecoderType(ty reflect.Type) bool {
+	return reflect.PtrTo(ty).Implements(exprDecoderType)
+}
+
+// isBigNumberType returns true if the given type is one of the
+// arbitrary-precision number types that gocty decodes from numbers.
+func isBigNumberType(ty reflect.Type) bool {
+	return ty == bigIntType || ty == bigFloatType
+}
+
+// impliedType returns the type that values must be converted to before gocty
+// decodes them into the given value. This is the result of
+// gocty.ImpliedType, except that it is cty.Number for the arbitrary-precision
+// number types, which gocty can decode into but cannot infer a type for.
+func impliedType(val interface{}) (cty.Type, error) {
+	ty := reflect.TypeOf(val)
+	for ty.Kind() == reflect.Ptr {
+		ty = ty.Elem()
+	}
+	if isBigNumberType(ty) {
+		return cty.Number, nil
+	}
+	return gocty.ImpliedType(val)
+}
+
+// isTextType returns true if values of the given type are decoded from
+// strings, either because they implement encoding.TextUnmarshaler or because
+// they are one of the standard library types that we handle specially.
+//
+// Types that gocty can decode themselves are left to gocty even if they
+// implement encoding.TextUnmarshaler, so that they are decoded from values
+// of their natural type rather than from strings.
+func isTextType(ty reflect.Type) bool {
+	switch {
+	case ty == durationType, ty == urlType:
+		return true
+	case isBigNumberType(ty):
+		return false
+	}
+	return reflect.PtrTo(ty).Implements(textUnmarshalerType)
+}
+
+// decodeExpressionCustom decodes the given expression into the value that
+// the given pointer points to, if that value's type is one that we must
+// handle ourselves rather than delegating to gocty. The boolean result is
+// false if the target is not such a type, in which case nothing is done.
+func decodeExpressionCustom(expr hcl.Expression, ctx *hcl.EvalContext, target reflect.Value, path cty.Path, plan *planState) (hcl.Diagnostics, bool) {
+	if target.Kind() != reflect.Ptr {
+		return nil, false
+	}
+	ty := target.Type().Elem()
+	isPtr := false
+	if ty.Kind() == reflect.Ptr {
+		isPtr = true
+		ty = ty.Elem()
+	}
+
+	switch {
+	case ty == exprType && !isPtr:
+		target.Elem().Set(reflect.ValueOf(&expr).Elem())
+		return nil, true
+
+	case ty == exprListType && !isPtr:
+		exprs, diags := hcl.ExprList(expr)
+		if !diags.HasErrors() {
+			target.Elem().Set(reflect.ValueOf(exprs))
+		}
+		return diags, true
+
+	case ty == exprMapType && !isPtr:
+		pairs, diags := hcl.ExprMap(expr)
+		if diags.HasErrors() {
+			return diags, true
+		}
+		exprs := make(map[string]hcl.Expression, len(pairs))
+		for _, pair := range pairs {
+			key, keyDiags := pair.Key.Value(ctx)
+			diags = append(diags, keyDiags...)
+			if keyDiags.HasErrors() {
+				continue
+			}
+			key, err := convert.Convert(key, cty.String)
+			if err != nil || key.IsNull() || !key.IsKnown() {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid map key",
+					Detail:   "A known string value is required for each key.",
+					Subject:  pair.Key.Range().Ptr(),
+				})
+				continue
+			}
+			exprs[key.AsString()] = pair.Value
+		}
+		target.Elem().Set(reflect.ValueOf(exprs))
+		return diags, true
+
+	case isExprDecoderType(ty):
+		if isPtr {
+			v := reflect.New(ty)
+			target.Elem().Set(v)
+			target = v
+		}
+		return target.Interface().(ExpressionDecoder).DecodeExpression(expr, ctx), true
+
+	case isTextType(ty):
+		val, diags := expr.Value(ctx)
+		if diags.HasErrors() {
+			return diags, true
+		}
+
+		val, err := convert.Convert(val, cty.String)
+		if err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unsuitable value type",
+				Detail:   fmt.Sprintf("Unsuitable value: %s", err.Error()),
+				Subject:  expr.Range().Ptr(),
+			})
+			return diags, true
+		}
+		if !val.IsKnown() {
+			if plan != nil {
+				plan.unknowns = append(plan.unknowns, unknownValues(val, expr, ctx, path)...)
+				return diags, true
+			}
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unsuitable value type",
+				Detail:   "Unsuitable value: value must be known",
+				Subject:  expr.Range().Ptr(),
+			})
+			return diags, true
+		}
+		if val.IsNull() {
+			if isPtr {
+				target.Elem().Set(reflect.Zero(target.Type().Elem()))
+				return diags, true
+			}
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unsuitable value type",
+				Detail:   "Unsuitable value: value must not be null",
+				Subject:  expr.Range().Ptr(),
+			})
+			return diags, true
+		}
+
+		if isPtr {
+			v := reflect.New(ty)
+			target.Elem().Set(v)
+			target = v
+		}
+		if err := decodeText(val.AsString(), target); err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid value",
+				Detail:   fmt.Sprintf("Unsuitable value: %s.", err.Error()),
+				Subject:  expr.Range().Ptr(),
+			})
+		}
+		return diags, true
+
+	default:
+		return nil, false
+	}
+}
+
+// decodeText assigns the value represented by the given string to the value
+// that the given pointer points to, whose type must be one for which
+// isTextType returns true.
+func decodeText(s string, target reflect.Value) error {
+	switch target.Type().Elem() {
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		target.Elem().Set(reflect.ValueOf(d))
+		return nil
+	case urlType:
+		u, err := url.Parse(s)
+		if err != nil {
+			return err
+		}
+		target.Elem().Set(reflect.ValueOf(*u))
+		return nil
+	default:
+		return target.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+}
+
+// encodeText returns the string that decodeText would decode into a value
+// equal to the given one, for a value whose type is one for which isTextType
+// or isExprDecoderType returns true.
+//
+// The boolean result is false if the value has no text form, because its
+// type implements neither encoding.TextMarshaler nor fmt.Stringer.
+func encodeText(val reflect.Value) (string, bool, error) {
+	switch val.Type() {
+	case durationType:
+		return val.Interface().(time.Duration).String(), true, nil
+	case urlType:
+		u := val.Interface().(url.URL)
+		return u.String(), true, nil
+	}
+
+	// Marshaling methods often have pointer receivers, so we need an
+	// addressable value to find them all.
+	ptr := reflect.New(val.Type())
+	ptr.Elem().Set(val)
+	switch {
+	case ptr.Type().Implements(textMarshalerType):
+		text, err := ptr.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err == nil, err
+	case ptr.Type().Implements(stringerType):
+		return ptr.Interface().(fmt.Stringer).String(), true, nil
+	default:
+		return "", false, nil
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/hashicorp/hcl2/hcl"
 )
@@ -43,7 +45,7 @@ func ImpliedBodySchema(val interface{}) (schema *hcl.BodySchema, partial bool) {
 	for _, n := range attrNames {
 		idx := tags.Attributes[n]
 		optional := tags.Optional[n]
-		field := ty.Field(idx)
+		field := ty.FieldByIndex(idx)
 
 		var required bool
 
@@ -72,20 +74,14 @@ func ImpliedBodySchema(val interface{}) (schema *hcl.BodySchema, partial bool) {
 	sort.Strings(blockNames)
 	for _, n := range blockNames {
 		idx := tags.Blocks[n]
-		field := ty.Field(idx)
-		fty := field.Type
-		if fty.Kind() == reflect.Slice {
-			fty = fty.Elem()
-		}
-		if fty.Kind() == reflect.Ptr {
-			fty = fty.Elem()
-		}
-		if fty.Kind() != reflect.Struct {
+		field := ty.FieldByIndex(idx)
+		depth, fty := blockElemType(field)
+		ftags := getFieldTags(fty)
+		if len(ftags.Labels) < depth {
 			panic(fmt.Sprintf(
-				"hcl 'block' tag kind cannot be applied to %s field %s: struct required", field.Type.String(), field.Name,
+				"hcl 'block' tag kind cannot be applied to %s field %s: element type must have at least %d label fields", field.Type.String(), field.Name, depth,
 			))
 		}
-		ftags := getFieldTags(fty)
 		var labelNames []string
 		if len(ftags.Labels) > 0 {
 			labelNames = make([]string, len(ftags.Labels))
@@ -108,67 +104,334 @@ func ImpliedBodySchema(val interface{}) (schema *hcl.BodySchema, partial bool) {
 	return schema, partial
 }
 
+// ImpliedBlockTypes returns the struct type used to decode each of the nested
+// block types declared by the type of the given value, which must be a struct
+// value or a pointer to one, keyed by block type name. This includes the
+// block types declared by any embedded structs that are squashed into it.
+//
+// As with ImpliedBodySchema, this function will panic if given an
+// inappropriate value.
+func ImpliedBlockTypes(val interface{}) map[string]reflect.Type {
+	ty := reflect.TypeOf(val)
+
+	if ty.Kind() == reflect.Ptr {
+		ty = ty.Elem()
+	}
+
+	if ty.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("given value must be struct, not %T", val))
+	}
+
+	tags := getFieldTags(ty)
+	ret := make(map[string]reflect.Type, len(tags.Blocks))
+	for n, idx := range tags.Blocks {
+		_, ret[n] = blockElemType(ty.FieldByIndex(idx))
+	}
+	return ret
+}
+
 type fieldTags struct {
-	Attributes map[string]int
-	Blocks     map[string]int
+	Attributes map[string][]int
+	Blocks     map[string][]int
 	Labels     []labelField
-	Remain     *int
+	Remain     []int
 	Optional   map[string]bool
+	Defaults   map[string]string
+
+	// OmitEmpty and Comments are the "omitempty" and "comment" options of
+	// attribute and block fields, keyed by name, which affect only encoding.
+	OmitEmpty map[string]bool
+	Comments  map[string]string
+
+	// Ranges are the fields that capture the source ranges of attributes or
+	// blocks, keyed by attribute name or block type name. DefRange and
+	// BodyRange capture the ranges of the block and body being decoded.
+	Ranges    map[string][]int
+	DefRange  []int
+	BodyRange []int
+
+	// Validations are the rules from the "hclvalidate" tags of attribute
+	// fields, keyed by attribute name.
+	Validations map[string]*fieldValidation
 }
 
 type labelField struct {
-	FieldIndex int
+	FieldIndex []int
 	Name       string
 }
 
+// fieldTagsCache maps struct types to the result of getFieldTags for each
+// one, so that the tags of a type, including the regular expressions in
+// any "hclvalidate" tags, are parsed only once however often it is decoded.
+var fieldTagsCache sync.Map
+
+// getFieldTags returns the tags of the fields of the given struct type,
+// including those of any embedded structs whose fields are squashed into
+// it. The field indices are suitable for use with FieldByIndex.
+//
+// The result is shared by all callers for the same type, so it must not be
+// modified.
 func getFieldTags(ty reflect.Type) *fieldTags {
+	if cached, ok := fieldTagsCache.Load(ty); ok {
+		return cached.(*fieldTags)
+	}
+
 	ret := &fieldTags{
-		Attributes: map[string]int{},
-		Blocks:     map[string]int{},
+		Attributes: map[string][]int{},
+		Blocks:     map[string][]int{},
 		Optional:   map[string]bool{},
+		Defaults:   map[string]string{},
+		OmitEmpty:  map[string]bool{},
+		Comments:   map[string]string{},
+		Ranges:     map[string][]int{},
+
+		Validations: map[string]*fieldValidation{},
+	}
+	ret.add(ty, nil)
+
+	cached, _ := fieldTagsCache.LoadOrStore(ty, ret)
+	return cached.(*fieldTags)
+}
+
+func (ret *fieldTags) add(ty reflect.Type, parent []int) {
+	// Fields squashed in from embedded structs are shadowed by fields of
+	// the same name at shallower depths, as with Go's own embedding rules,
+	// so we must know all of the names at this level before adding those.
+	names := map[string]bool{}
+	if parent != nil {
+		for n := range ret.Attributes {
+			names[n] = true
+		}
+		for n := range ret.Blocks {
+			names[n] = true
+		}
+	}
+	shadowed := func(name string) bool {
+		return parent != nil && names[name]
 	}
 
 	ct := ty.NumField()
 	for i := 0; i < ct; i++ {
 		field := ty.Field(i)
+		idx := make([]int, len(parent)+1)
+		copy(idx, parent)
+		idx[len(parent)] = i
+
 		tag := field.Tag.Get("hcl")
 		if tag == "" {
 			continue
 		}
 
-		comma := strings.Index(tag, ",")
-		var name, kind string
-		if comma != -1 {
-			name = tag[:comma]
-			kind = tag[comma+1:]
-		} else {
-			name = tag
-			kind = "attr"
+		name, kind, opts := parseFieldTag(tag, field)
+		switch kind {
+		case "attr", "optional", "block":
+			if shadowed(name) {
+				continue
+			}
+		case "range":
+			if _, exists := ret.Ranges[name]; exists && parent != nil {
+				continue
+			}
+		case "def_range":
+			if ret.DefRange != nil && parent != nil {
+				continue
+			}
+		case "body_range":
+			if ret.BodyRange != nil && parent != nil {
+				continue
+			}
+		}
+		switch kind {
+		case "range", "def_range", "body_range":
+			if field.Type != rangeType && field.Type != reflect.PtrTo(rangeType) {
+				panic(fmt.Sprintf("hcl '%s' tag kind cannot be applied to %s field %s: hcl.Range required", kind, field.Type.String(), field.Name))
+			}
 		}
 
 		switch kind {
 		case "attr":
-			ret.Attributes[name] = i
+			ret.Attributes[name] = idx
 		case "block":
-			ret.Blocks[name] = i
+			ret.Blocks[name] = idx
 		case "label":
 			ret.Labels = append(ret.Labels, labelField{
-				FieldIndex: i,
+				FieldIndex: idx,
 				Name:       name,
 			})
 		case "remain":
 			if ret.Remain != nil {
 				panic("only one 'remain' tag is permitted")
 			}
-			idx := i // copy, because this loop will continue assigning to i
-			ret.Remain = &idx
+			ret.Remain = idx
 		case "optional":
-			ret.Attributes[name] = i
+			ret.Attributes[name] = idx
 			ret.Optional[name] = true
+		case "range":
+			ret.Ranges[name] = idx
+		case "def_range":
+			ret.DefRange = idx
+		case "body_range":
+			ret.BodyRange = idx
+		case "squash":
+			if field.Type.Kind() != reflect.Struct {
+				panic(fmt.Sprintf("hcl 'squash' tag kind cannot be applied to %s field %s: struct required", field.Type.String(), field.Name))
+			}
+			ret.add(field.Type, idx)
 		default:
 			panic(fmt.Sprintf("invalid hcl field tag kind %q on %s %q", kind, field.Type.String(), field.Name))
 		}
+
+		if opts.hasDefault {
+			if kind != "attr" && kind != "optional" {
+				panic(fmt.Sprintf("hcl 'default' tag option cannot be used with kind %q on %s %q", kind, field.Type.String(), field.Name))
+			}
+			ret.Defaults[name] = opts.dflt
+			ret.Optional[name] = true
+		}
+		if opts.omitEmpty || opts.hasComment {
+			if kind != "attr" && kind != "optional" && kind != "block" {
+				panic(fmt.Sprintf("hcl 'omitempty' and 'comment' tag options cannot be used with kind %q on %s %q", kind, field.Type.String(), field.Name))
+			}
+			if opts.omitEmpty {
+				ret.OmitEmpty[name] = true
+			}
+			if opts.hasComment {
+				ret.Comments[name] = opts.comment
+			}
+		}
+
+		if vtag := field.Tag.Get("hclvalidate"); vtag != "" {
+			if kind != "attr" && kind != "optional" {
+				panic(fmt.Sprintf("hclvalidate tag cannot be used with kind %q on %s %q", kind, field.Type.String(), field.Name))
+			}
+			ret.Validations[name] = parseValidationTag(vtag, field)
+		}
 	}
+}
 
-	return ret
+// fieldTagOptions are the options that may follow the name and kind in an
+// "hcl" tag.
+type fieldTagOptions struct {
+	omitEmpty  bool
+	hasComment bool
+	comment    string
+	hasDefault bool
+	dflt       string
+}
+
+// parseFieldTag splits an "hcl" tag into its name, its kind and any options.
+// The kind may be omitted, in which case it defaults to "attr".
+//
+// The "default" option takes the remainder of the tag as its value, so that
+// the value may itself contain commas, and so it must be the last option.
+// The "comment" option's value may be given as a Go-style quoted string to
+// allow it to contain commas.
+func parseFieldTag(tag string, field reflect.StructField) (name, kind string, opts fieldTagOptions) {
+	invalid := func(format string, args ...interface{}) {
+		panic(fmt.Sprintf("invalid hcl field tag on %s %q: %s", field.Type.String(), field.Name, fmt.Sprintf(format, args...)))
+	}
+
+	name, kind = tag, "attr"
+	comma := strings.Index(tag, ",")
+	if comma == -1 {
+		return name, kind, opts
+	}
+	name, rest := tag[:comma], tag[comma+1:]
+
+	first := rest
+	if comma := strings.Index(rest, ","); comma != -1 {
+		first = rest[:comma]
+	}
+	if first != "omitempty" && !strings.Contains(first, "=") {
+		kind = first
+		rest = strings.TrimPrefix(rest[len(first):], ",")
+	}
+
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "default="):
+			opts.hasDefault = true
+			opts.dflt, rest = strings.TrimPrefix(rest, "default="), ""
+			continue
+		case strings.HasPrefix(rest, "comment=\""):
+			quoted, err := strconv.QuotedPrefix(strings.TrimPrefix(rest, "comment="))
+			if err != nil {
+				invalid("comment option has an unterminated quoted string")
+			}
+			opts.hasComment = true
+			opts.comment, _ = strconv.Unquote(quoted)
+			rest = rest[len("comment=")+len(quoted):]
+			if rest != "" && rest[0] != ',' {
+				invalid("comment option must be followed by a comma")
+			}
+		case strings.HasPrefix(rest, "comment="):
+			opts.hasComment = true
+			opts.comment = strings.TrimPrefix(rest, "comment=")
+			if comma := strings.Index(opts.comment, ","); comma != -1 {
+				opts.comment = opts.comment[:comma]
+			}
+			rest = rest[len("comment=")+len(opts.comment):]
+		case rest == "omitempty" || strings.HasPrefix(rest, "omitempty,"):
+			opts.omitEmpty = true
+			rest = rest[len("omitempty"):]
+		default:
+			opt := rest
+			if comma := strings.Index(opt, ","); comma != -1 {
+				opt = opt[:comma]
+			}
+			panic(fmt.Sprintf("invalid hcl field tag option %q on %s %q", opt, field.Type.String(), field.Name))
+		}
+		rest = strings.TrimPrefix(rest, ",")
+	}
+
+	return name, kind, opts
+}
+
+// blockElemType returns the struct type of the blocks decoded into the given
+// block field, along with the number of levels of map nesting keyed by the
+// first labels of each block, as returned by blockMapDepth.
+func blockElemType(field reflect.StructField) (int, reflect.Type) {
+	ty := field.Type
+	depth := 0
+	if ty.Kind() == reflect.Slice {
+		ty = ty.Elem()
+	} else {
+		depth, ty = blockMapDepth(ty)
+	}
+	if ty.Kind() == reflect.Ptr {
+		ty = ty.Elem()
+	}
+	if ty.Kind() != reflect.Struct {
+		panic(fmt.Sprintf(
+			"hcl 'block' tag kind cannot be applied to %s field %s: struct required", field.Type.String(), field.Name,
+		))
+	}
+	return depth, ty
+}
+
+// blockMapDepth returns the number of levels of map nesting in the given
+// block field type, which are keyed by the first labels of each block, along
+// with the type of the map elements. The depth is zero if the field is not
+// a map.
+func blockMapDepth(ty reflect.Type) (int, reflect.Type) {
+	depth := 0
+	for ty.Kind() == reflect.Map {
+		if ty.Key().Kind() != reflect.String {
+			panic(fmt.Sprintf("hcl 'block' tag kind cannot be applied to map with %s keys: string required", ty.Key().String()))
+		}
+		depth++
+		ty = ty.Elem()
+	}
+	return depth, ty
+}
+
+// indexLess returns true if the field with index a precedes the field with
+// index b in declaration order.
+func indexLess(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
 }
@@ -228,3 +228,58 @@ func TestImpliedBodySchema(t *testing.T) {
 		})
 	}
 }
+
+func TestImpliedBlockTypes(t *testing.T) {
+	type Item struct {
+		Name string `hcl:"name,label"`
+	}
+	type Common struct {
+		Shared []Item `hcl:"shared,block"`
+	}
+	val := struct {
+		Common  `hcl:",squash"`
+		Single  *Item            `hcl:"single,block"`
+		Many    []Item           `hcl:"many,block"`
+		ByName  map[string]*Item `hcl:"by_name,block"`
+		Ignored string           `hcl:"ignored"`
+	}{}
+
+	got := ImpliedBlockTypes(val)
+	itemType := reflect.TypeOf(Item{})
+	want := map[string]reflect.Type{
+		"shared":  itemType,
+		"single":  itemType,
+		"many":    itemType,
+		"by_name": itemType,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestImpliedBodySchemaUntaggedEmbedded(t *testing.T) {
+	type Common struct {
+		Name string `hcl:"name"`
+	}
+	val := struct {
+		Common
+		Port int `hcl:"port"`
+	}{}
+
+	schema, _ := ImpliedBodySchema(val)
+	want := &hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{
+			{Name: "port", Required: true},
+		},
+	}
+	if !reflect.DeepEqual(schema, want) {
+		t.Errorf("wrong schema\ngot:  %s\nwant: %s", spew.Sdump(schema), spew.Sdump(want))
+	}
+}
+
+func TestGetFieldTagsCached(t *testing.T) {
+	ty := reflect.TypeOf(testValidated{})
+	if first, second := getFieldTags(ty), getFieldTags(ty); first != second {
+		t.Errorf("tags were parsed again for the same type")
+	}
+}
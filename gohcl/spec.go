@@ -0,0 +1,143 @@
+package gohcl
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// ImpliedSpec produces a hcldec.Spec derived from the type of the given value,
+// which must be a struct value or a pointer to one. The result describes the
+// same configuration structure that DecodeBody would accept for the given
+// value, and so it can be used to share a schema defined using struct tags
+// with other applications, such as via the "hcldec" command line tool.
+//
+// The result is always an hcldec.ObjectSpec whose keys are the names given in
+// the struct tags. Fields of type hcl.Expression or cty.Value become
+// attributes of any type, and the "remain" field, if any, is ignored since
+// its content cannot be described by a spec.
+//
+// As with ImpliedBodySchema, this function will panic if an inappropriate
+// value is passed or if any of the field types cannot be mapped to a spec.
+func ImpliedSpec(val interface{}) hcldec.Spec {
+	ty := reflect.TypeOf(val)
+
+	if ty.Kind() == reflect.Ptr {
+		ty = ty.Elem()
+	}
+
+	if ty.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("given value must be struct, not %T", val))
+	}
+
+	return impliedStructSpec(ty, 0)
+}
+
+// impliedStructSpec returns the spec for a body decoded into the given
+// struct type, whose first skipLabels labels are consumed as map keys by
+// a BlockMapSpec and so are not included.
+func impliedStructSpec(ty reflect.Type, skipLabels int) hcldec.ObjectSpec {
+	spec := hcldec.ObjectSpec{}
+	schema, _ := ImpliedBodySchema(reflect.Zero(ty).Interface())
+	tags := getFieldTags(ty)
+
+	for _, attrS := range schema.Attributes {
+		field := ty.FieldByIndex(tags.Attributes[attrS.Name])
+		attrTy := impliedFieldType(field)
+		var attrSpec hcldec.Spec = &hcldec.AttrSpec{
+			Name:     attrS.Name,
+			Type:     attrTy,
+			Required: attrS.Required,
+		}
+		if dflt, hasDefault := tags.Defaults[attrS.Name]; hasDefault {
+			dfltVal, err := convert.Convert(cty.StringVal(dflt), attrTy)
+			if err != nil {
+				panic(fmt.Sprintf("invalid default value for field %s: %s", field.Name, err))
+			}
+			attrSpec = &hcldec.DefaultSpec{
+				Primary: attrSpec,
+				Default: &hcldec.LiteralSpec{Value: dfltVal},
+			}
+		}
+		spec[attrS.Name] = attrSpec
+	}
+
+	for _, blockS := range schema.Blocks {
+		field := ty.FieldByIndex(tags.Blocks[blockS.Type])
+		fty := field.Type
+		isSlice := false
+		isPtr := false
+		mapDepth := 0
+		if fty.Kind() == reflect.Slice {
+			isSlice = true
+			fty = fty.Elem()
+		} else {
+			mapDepth, fty = blockMapDepth(fty)
+		}
+		if fty.Kind() == reflect.Ptr {
+			isPtr = true
+			fty = fty.Elem()
+		}
+
+		nested := impliedStructSpec(fty, mapDepth)
+		switch {
+		case mapDepth > 0:
+			spec[blockS.Type] = &hcldec.BlockMapSpec{
+				TypeName:   blockS.Type,
+				LabelNames: blockS.LabelNames[:mapDepth],
+				Nested:     nested,
+			}
+		case isSlice:
+			spec[blockS.Type] = &hcldec.BlockListSpec{
+				TypeName: blockS.Type,
+				Nested:   nested,
+			}
+		default:
+			spec[blockS.Type] = &hcldec.BlockSpec{
+				TypeName: blockS.Type,
+				Nested:   nested,
+				Required: !isPtr,
+			}
+		}
+	}
+
+	for i, label := range tags.Labels {
+		if i < skipLabels {
+			continue
+		}
+		spec[label.Name] = &hcldec.BlockLabelSpec{
+			Index: i - skipLabels,
+			Name:  label.Name,
+		}
+	}
+
+	return spec
+}
+
+func impliedFieldType(field reflect.StructField) cty.Type {
+	switch {
+	case exprType.AssignableTo(field.Type), field.Type.AssignableTo(attrType), field.Type == attrValType,
+		field.Type == exprListType, field.Type == exprMapType:
+		return cty.DynamicPseudoType
+	}
+
+	fty := field.Type
+	if fty.Kind() == reflect.Ptr {
+		fty = fty.Elem()
+	}
+	switch {
+	case isExprDecoderType(fty):
+		return cty.DynamicPseudoType
+	case isTextType(fty):
+		return cty.String
+	}
+
+	ty, err := impliedType(reflect.Zero(field.Type).Interface())
+	if err != nil {
+		panic(fmt.Sprintf("cannot determine type for field %s: %s", field.Name, err))
+	}
+	return ty
+}
@@ -0,0 +1,146 @@
+package gohcl
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+func TestImpliedSpec(t *testing.T) {
+	type Rule struct {
+		Name string `hcl:"name,label"`
+		Port int    `hcl:"port"`
+	}
+	type Network struct {
+		CIDR string `hcl:"cidr"`
+	}
+	type Config struct {
+		Name    string            `hcl:"name"`
+		Size    *int              `hcl:"size"`
+		Tags    map[string]string `hcl:"tags,optional"`
+		Value   hcl.Expression    `hcl:"value"`
+		Dynamic cty.Value         `hcl:"dynamic,optional"`
+		Timeout *time.Duration    `hcl:"timeout"`
+		Network *Network          `hcl:"network,block"`
+		Rules   []Rule            `hcl:"rule,block"`
+		Remain  hcl.Body          `hcl:",remain"`
+	}
+
+	got := ImpliedSpec(&Config{})
+	want := hcldec.ObjectSpec{
+		"name": &hcldec.AttrSpec{
+			Name:     "name",
+			Type:     cty.String,
+			Required: true,
+		},
+		"size": &hcldec.AttrSpec{
+			Name: "size",
+			Type: cty.Number,
+		},
+		"tags": &hcldec.AttrSpec{
+			Name: "tags",
+			Type: cty.Map(cty.String),
+		},
+		"value": &hcldec.AttrSpec{
+			Name: "value",
+			Type: cty.DynamicPseudoType,
+		},
+		"dynamic": &hcldec.AttrSpec{
+			Name: "dynamic",
+			Type: cty.DynamicPseudoType,
+		},
+		"timeout": &hcldec.AttrSpec{
+			Name: "timeout",
+			Type: cty.String,
+		},
+		"network": &hcldec.BlockSpec{
+			TypeName: "network",
+			Nested: hcldec.ObjectSpec{
+				"cidr": &hcldec.AttrSpec{
+					Name:     "cidr",
+					Type:     cty.String,
+					Required: true,
+				},
+			},
+		},
+		"rule": &hcldec.BlockListSpec{
+			TypeName: "rule",
+			Nested: hcldec.ObjectSpec{
+				"name": &hcldec.BlockLabelSpec{
+					Index: 0,
+					Name:  "name",
+				},
+				"port": &hcldec.AttrSpec{
+					Name:     "port",
+					Type:     cty.Number,
+					Required: true,
+				},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong result\ngot:  %s\nwant: %s", spew.Sdump(got), spew.Sdump(want))
+	}
+}
+
+func TestImpliedSpecSquashMapsDefaults(t *testing.T) {
+	type Common struct {
+		Port int `hcl:"port,default=8080"`
+	}
+	type Resource struct {
+		Type string `hcl:"type,label"`
+		Name string `hcl:"name,label"`
+		Size int    `hcl:"size"`
+	}
+	type Config struct {
+		Common    `hcl:",squash"`
+		Resources map[string]Resource `hcl:"resource,block"`
+	}
+
+	got := ImpliedSpec(&Config{})
+	want := hcldec.ObjectSpec{
+		"port": &hcldec.DefaultSpec{
+			Primary: &hcldec.AttrSpec{
+				Name: "port",
+				Type: cty.Number,
+			},
+			Default: &hcldec.LiteralSpec{
+				Value: mustConvert(cty.StringVal("8080"), cty.Number),
+			},
+		},
+		"resource": &hcldec.BlockMapSpec{
+			TypeName:   "resource",
+			LabelNames: []string{"type"},
+			Nested: hcldec.ObjectSpec{
+				"name": &hcldec.BlockLabelSpec{
+					Index: 0,
+					Name:  "name",
+				},
+				"size": &hcldec.AttrSpec{
+					Name:     "size",
+					Type:     cty.Number,
+					Required: true,
+				},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong result\ngot:  %s\nwant: %s", spew.Sdump(got), spew.Sdump(want))
+	}
+}
+
+func mustConvert(val cty.Value, ty cty.Type) cty.Value {
+	ret, err := convert.Convert(val, ty)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
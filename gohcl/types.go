@@ -14,3 +14,7 @@ var bodyType = reflect.TypeOf(&victimBody).Elem()
 var blockType = reflect.TypeOf((*hcl.Block)(nil))
 var attrType = reflect.TypeOf((*hcl.Attribute)(nil))
 var attrsType = reflect.TypeOf(hcl.Attributes(nil))
+var rangeType = reflect.TypeOf(hcl.Range{})
+var attrValType = reflect.TypeOf(hcl.Attribute{})
+var exprListType = reflect.TypeOf([]hcl.Expression(nil))
+var exprMapType = reflect.TypeOf(map[string]hcl.Expression(nil))
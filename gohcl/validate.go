@@ -0,0 +1,301 @@
+package gohcl
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/internal/prose"
+)
+
+// Validator is implemented by struct types that check their own values once
+// they have been decoded.
+//
+// DecodeBody calls Validate on each struct it decodes, including those
+// decoded from nested blocks, after all of the struct's fields have been
+// populated without errors and after the rules given in any "hclvalidate"
+// tags have passed. The method may be implemented with either a value or a
+// pointer receiver.
+//
+// The given context provides the source ranges of the struct's attributes
+// and blocks, so that the returned diagnostics can refer to the exact part
+// of the configuration that is invalid.
+type Validator interface {
+	Validate(ctx *ValidateContext) hcl.Diagnostics
+}
+
+// ValidateContext describes the configuration that a struct was decoded
+// from, for use by a Validator.
+type ValidateContext struct {
+	// EvalContext is the context that was used to evaluate expressions
+	// while decoding, which may be nil.
+	EvalContext *hcl.EvalContext
+
+	// Content is the content of the body that the struct was decoded from,
+	// according to the schema implied by its struct tags.
+	Content *hcl.BodyContent
+
+	unknownAttrs map[string]bool
+}
+
+// Attribute returns the attribute of the given name, or nil if it was not
+// set in configuration.
+func (c *ValidateContext) Attribute(name string) *hcl.Attribute {
+	return c.Content.Attributes[name]
+}
+
+// Blocks returns the blocks of the given type, in the order they appeared in
+// configuration.
+func (c *ValidateContext) Blocks(typeName string) hcl.Blocks {
+	var ret hcl.Blocks
+	for _, block := range c.Content.Blocks {
+		if block.Type == typeName {
+			ret = append(ret, block)
+		}
+	}
+	return ret
+}
+
+// Range returns the most appropriate source range to use as the subject of
+// a diagnostic about the attribute or block type of the given name: the
+// range of the attribute's expression, the header of the first block of the
+// type, or the range where a missing item would be added if there is
+// neither.
+func (c *ValidateContext) Range(name string) hcl.Range {
+	if attr := c.Attribute(name); attr != nil {
+		return attr.Expr.Range()
+	}
+	if blocks := c.Blocks(name); len(blocks) > 0 {
+		return blocks[0].DefRange
+	}
+	return c.Content.MissingItemRange
+}
+
+// Unknown returns true if the value of the attribute of the given name was
+// unknown while decoding with PlanDecodeBody, in which case the field was
+// left at its zero value and should not be validated.
+func (c *ValidateContext) Unknown(name string) bool {
+	return c.unknownAttrs[name]
+}
+
+// fieldValidation describes the rules given in the "hclvalidate" tag of an
+// attribute field.
+type fieldValidation struct {
+	Min, Max      *float64
+	MinS, MaxS    string
+	Pattern       *regexp.Regexp
+	Enum          []string
+	RequiredOneOf string
+}
+
+// parseValidationTag parses a "hclvalidate" tag, which is a comma-separated
+// list of rules. Since a regular expression may itself contain commas, a
+// "regex" rule must be the last one in the tag.
+func parseValidationTag(tag string, field reflect.StructField) *fieldValidation {
+	ret := &fieldValidation{}
+	invalid := func(format string, args ...interface{}) {
+		panic(fmt.Sprintf("invalid hclvalidate tag on %s %q: %s", field.Type.String(), field.Name, fmt.Sprintf(format, args...)))
+	}
+
+	fty := field.Type
+	if fty.Kind() == reflect.Ptr {
+		fty = fty.Elem()
+	}
+
+	for tag != "" {
+		var rule string
+		if strings.HasPrefix(tag, "regex=") {
+			rule, tag = tag, ""
+		} else if comma := strings.Index(tag, ","); comma != -1 {
+			rule, tag = tag[:comma], tag[comma+1:]
+		} else {
+			rule, tag = tag, ""
+		}
+
+		eq := strings.Index(rule, "=")
+		if eq == -1 {
+			invalid("rule %q has no value", rule)
+		}
+		key, arg := rule[:eq], rule[eq+1:]
+
+		switch key {
+		case "min", "max":
+			if !isNumberKind(fty.Kind()) {
+				invalid("%s requires a number field", key)
+			}
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				invalid("%s value must be a number", key)
+			}
+			if key == "min" {
+				ret.Min, ret.MinS = &n, arg
+			} else {
+				ret.Max, ret.MaxS = &n, arg
+			}
+		case "regex":
+			if fty.Kind() != reflect.String {
+				invalid("regex requires a string field")
+			}
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				invalid("%s", err)
+			}
+			ret.Pattern = re
+		case "enum":
+			ret.Enum = strings.Split(arg, "|")
+		case "required-one-of":
+			ret.RequiredOneOf = arg
+		default:
+			invalid("unsupported rule %q", key)
+		}
+	}
+
+	return ret
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// validateStruct checks the rules given in the "hclvalidate" tags of the
+// given struct value and then calls its Validate method, if any.
+func validateStruct(val reflect.Value, tags *fieldTags, ctx *ValidateContext) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	names := make([]string, 0, len(tags.Validations))
+	for name := range tags.Validations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	groups := map[string][]string{}
+	groupSet := map[string]bool{}
+	var groupNames []string
+	for _, name := range names {
+		v := tags.Validations[name]
+		attr := ctx.Attribute(name)
+
+		if g := v.RequiredOneOf; g != "" {
+			if _, exists := groups[g]; !exists {
+				groupNames = append(groupNames, g)
+			}
+			groups[g] = append(groups[g], name)
+			if attr != nil {
+				groupSet[g] = true
+			}
+		}
+
+		// Only values given in configuration are checked, since defaults
+		// are chosen by the application rather than the author.
+		if attr == nil || ctx.Unknown(name) {
+			continue
+		}
+		fieldV := val.FieldByIndex(tags.Attributes[name])
+		if fieldV.Kind() == reflect.Ptr {
+			if fieldV.IsNil() {
+				continue
+			}
+			fieldV = fieldV.Elem()
+		}
+		if detail := v.check(fieldV); detail != "" {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid value",
+				Detail:   fmt.Sprintf("Invalid value for %q: %s", name, detail),
+				Subject:  attr.Expr.Range().Ptr(),
+				Context:  attr.Range.Ptr(),
+			})
+		}
+	}
+
+	for _, g := range groupNames {
+		if groupSet[g] {
+			continue
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Missing required argument",
+			Detail:   fmt.Sprintf("At least one of %s must be set.", quotedAlternatives(groups[g])),
+			Subject:  ctx.Content.MissingItemRange.Ptr(),
+		})
+	}
+
+	if diags.HasErrors() {
+		return diags
+	}
+
+	var validator Validator
+	if val.CanAddr() {
+		validator, _ = val.Addr().Interface().(Validator)
+	} else {
+		validator, _ = val.Interface().(Validator)
+	}
+	if validator != nil {
+		diags = append(diags, validator.Validate(ctx)...)
+	}
+
+	return diags
+}
+
+// check returns a sentence describing why the given field value breaks the
+// rules, or an empty string if it doesn't.
+func (v *fieldValidation) check(val reflect.Value) string {
+	if v.Min != nil || v.Max != nil {
+		var n float64
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = float64(val.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n = float64(val.Uint())
+		default:
+			n = val.Float()
+		}
+		if v.Min != nil && n < *v.Min {
+			return fmt.Sprintf("must be at least %s.", v.MinS)
+		}
+		if v.Max != nil && n > *v.Max {
+			return fmt.Sprintf("must be at most %s.", v.MaxS)
+		}
+	}
+
+	if v.Pattern != nil && !v.Pattern.MatchString(val.String()) {
+		return fmt.Sprintf("must match the regular expression %q.", v.Pattern.String())
+	}
+
+	if len(v.Enum) > 0 {
+		s := fmt.Sprint(val.Interface())
+		found := false
+		for _, allowed := range v.Enum {
+			if s == allowed {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Sprintf("must be one of %s.", quotedAlternatives(v.Enum))
+		}
+	}
+
+	return ""
+}
+
+// quotedAlternatives returns a human-readable list of the given names,
+// quoted and joined with "or".
+func quotedAlternatives(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = strconv.Quote(name)
+	}
+	return prose.JoinAlternatives(quoted)
+}
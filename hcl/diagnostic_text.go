@@ -162,13 +162,15 @@ func (w *diagnosticTextWriter) WriteDiagnostic(diag *Diagnostic) error {
 					continue // don't show duplicates when the same variable is referenced multiple times
 				}
 				switch {
+				case ctx.IsSensitive(traversal):
+					stmts = append(stmts, fmt.Sprintf("%s as %s", traversalStr, sensitiveValueStr))
 				case !val.IsKnown():
 					// Can't say anything about this yet, then.
 					continue
 				case val.IsNull():
 					stmts = append(stmts, fmt.Sprintf("%s set to null", traversalStr))
 				default:
-					stmts = append(stmts, fmt.Sprintf("%s as %s", traversalStr, w.valueStr(val)))
+					stmts = append(stmts, fmt.Sprintf("%s as %s", traversalStr, w.valueStr(val, ctx, traversal)))
 				}
 				seen[traversalStr] = struct{}{}
 			}
@@ -231,7 +233,7 @@ func (w *diagnosticTextWriter) traversalStr(traversal Traversal) string {
 		case TraverseIndex:
 			buf.WriteByte('[')
 			if keyTy := tStep.Key.Type(); keyTy.IsPrimitiveType() {
-				buf.WriteString(w.valueStr(tStep.Key))
+				buf.WriteString(w.valueStr(tStep.Key, nil, nil))
 			} else {
 				// We'll just use a placeholder for more complex values,
 				// since otherwise our result could grow ridiculously long.
@@ -243,11 +245,23 @@ func (w *diagnosticTextWriter) traversalStr(traversal Traversal) string {
 	return buf.String()
 }
 
-func (w *diagnosticTextWriter) valueStr(val cty.Value) string {
+// sensitiveValueStr is the placeholder shown in diagnostics in place of
+// sensitive values.
+const sensitiveValueStr = "(sensitive)"
+
+// valueStr renders the given value, which is the result of the given
+// absolute traversal in the given context, or which is a literal if the
+// context is nil. A value that is sensitive or that contains any sensitive
+// values is replaced with a placeholder.
+func (w *diagnosticTextWriter) valueStr(val cty.Value, ctx *EvalContext, traversal Traversal) string {
 	// This is a specialized subset of value rendering tailored to producing
 	// helpful but concise messages in diagnostics. It is not comprehensive
 	// nor intended to be used for other purposes.
 
+	if ctx.containsSensitive(traversal, val) {
+		return sensitiveValueStr
+	}
+
 	ty := val.Type()
 	switch {
 	case val.IsNull():
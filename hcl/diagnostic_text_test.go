@@ -183,6 +183,139 @@ with bar.baz as empty list of string,
 This diagnostic includes an expression
 and an evalcontext.
 
+`,
+		},
+		{
+			&Diagnostic{
+				Severity: DiagError,
+				Summary:  "Test of redacting sensitive values",
+				Detail:   `This diagnostic refers to sensitive values.`,
+				Subject: &Range{
+					Start: Pos{
+						Byte:   42,
+						Column: 3,
+						Line:   5,
+					},
+					End: Pos{
+						Byte:   47,
+						Column: 8,
+						Line:   5,
+					},
+				},
+				Expression: &diagnosticTestExpr{
+					vars: []Traversal{
+						{
+							TraverseRoot{
+								Name: "token",
+							},
+						},
+						{
+							TraverseRoot{
+								Name: "creds",
+							},
+							TraverseAttr{
+								Name: "password",
+							},
+						},
+						{
+							TraverseRoot{
+								Name: "user",
+							},
+						},
+						{
+							TraverseRoot{
+								Name: "empty",
+							},
+						},
+					},
+				},
+				EvalContext: &EvalContext{
+					Variables: map[string]cty.Value{
+						"token": cty.StringVal("hunter2"),
+						"creds": cty.ObjectVal(map[string]cty.Value{
+							"password": cty.StringVal("hunter3"),
+						}),
+						"user":  cty.StringVal("admin"),
+						"empty": cty.NullVal(cty.String),
+					},
+					Sensitive: SensitiveNames("token", "creds", "empty"),
+				},
+			},
+			`Error: Test of redacting sensitive values
+
+  on  line 5, in hardcoded-context:
+   5:   pizza = "cheese"
+
+with creds.password as (sensitive),
+     empty as (sensitive),
+     token as (sensitive),
+     user as "admin".
+
+This diagnostic refers to sensitive
+values.
+
+`,
+		},
+		{
+			&Diagnostic{
+				Severity: DiagError,
+				Summary:  "Test of redacting objects with sensitive attributes",
+				Detail:   `This diagnostic refers to an object containing a sensitive value.`,
+				Subject: &Range{
+					Start: Pos{
+						Byte:   42,
+						Column: 3,
+						Line:   5,
+					},
+					End: Pos{
+						Byte:   47,
+						Column: 8,
+						Line:   5,
+					},
+				},
+				Expression: &diagnosticTestExpr{
+					vars: []Traversal{
+						{
+							TraverseRoot{
+								Name: "var",
+							},
+						},
+						{
+							TraverseRoot{
+								Name: "other",
+							},
+						},
+					},
+				},
+				EvalContext: &EvalContext{
+					Variables: map[string]cty.Value{
+						"var": cty.ObjectVal(map[string]cty.Value{
+							"token": cty.StringVal("hunter2"),
+						}),
+						"other": cty.ObjectVal(map[string]cty.Value{
+							"name": cty.StringVal("app"),
+						}),
+					},
+					Sensitive: func(traversal Traversal) bool {
+						if len(traversal) != 2 || traversal.RootName() != "var" {
+							return false
+						}
+						attr, ok := traversal[1].(TraverseAttr)
+						return ok && attr.Name == "token"
+					},
+				},
+			},
+			`Error: Test of redacting objects with sensitive attributes
+
+  on  line 5, in hardcoded-context:
+   5:   pizza = "cheese"
+
+with other as object with 1 attribute "name",
+     var as (sensitive).
+
+This diagnostic refers to an object
+containing a sensitive value.
+
 `,
 		},
 	}
@@ -1,6 +1,8 @@
 package hcl
 
 import (
+	"sync"
+
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/function"
 )
@@ -10,7 +12,31 @@ import (
 type EvalContext struct {
 	Variables map[string]cty.Value
 	Functions map[string]function.Function
-	parent    *EvalContext
+
+	// VariableResolver, if non-nil, is consulted for any variable name that
+	// is not present in Variables, allowing the values of variables to be
+	// computed only when they are actually referenced.
+	//
+	// Results from the resolver are cached in the EvalContext, so the
+	// resolver is called at most once per name unless it returns errors.
+	// For this reason, an EvalContext with a VariableResolver must not be
+	// copied or have its VariableResolver changed once it has been used
+	// for evaluation. Use NewChild to derive a context with different
+	// variables instead.
+	VariableResolver VariableResolver
+
+	// Sensitive, if non-nil, is called to decide whether the value resulting
+	// from a traversal through one of the variables defined in this context
+	// is sensitive. Sensitive values, and values derived from them, are
+	// redacted when included in diagnostic messages.
+	Sensitive SensitiveFunc
+
+	parent *EvalContext
+
+	// cache holds the results from VariableResolver. It is created when
+	// first needed, since contexts are usually constructed as literals.
+	cacheOnce sync.Once
+	cache     *resolverCache
 }
 
 // NewChild returns a new EvalContext that is a child of the receiver.
@@ -23,3 +49,118 @@ func (ctx *EvalContext) NewChild() *EvalContext {
 func (ctx *EvalContext) Parent() *EvalContext {
 	return ctx.parent
 }
+
+// VariableResolver is the interface implemented by objects that can provide
+// variable values on demand, for use in EvalContext.VariableResolver.
+type VariableResolver interface {
+	// ResolveVariable returns the value of the variable whose name is the
+	// root name of the given absolute traversal.
+	//
+	// The full traversal is provided so that the resolver can use its source
+	// range in any diagnostics it returns, but the result must be the value
+	// of the whole variable since it will be cached and used for any other
+	// traversals with the same root name.
+	//
+	// If no variable of the given name exists, ResolveVariable should return
+	// false as its second return value, in which case the search continues
+	// in any parent contexts.
+	ResolveVariable(traversal Traversal) (cty.Value, bool, Diagnostics)
+}
+
+// VariableResolverFunc is an adapter that allows an ordinary function to be
+// used as a VariableResolver.
+type VariableResolverFunc func(traversal Traversal) (cty.Value, bool, Diagnostics)
+
+// ResolveVariable implements VariableResolver by calling the receiver.
+func (f VariableResolverFunc) ResolveVariable(traversal Traversal) (cty.Value, bool, Diagnostics) {
+	return f(traversal)
+}
+
+type resolvedVariable struct {
+	Value  cty.Value
+	Exists bool
+}
+
+// resolverCache is the cache of results from the VariableResolver of an
+// EvalContext, which may be used concurrently by multiple evaluations.
+type resolverCache struct {
+	mu       sync.Mutex
+	resolved map[string]resolvedVariable
+}
+
+// resolverCache returns the receiver's resolver cache, creating it if
+// necessary.
+func (ctx *EvalContext) resolverCache() *resolverCache {
+	ctx.cacheOnce.Do(func() {
+		ctx.cache = &resolverCache{
+			resolved: make(map[string]resolvedVariable),
+		}
+	})
+	return ctx.cache
+}
+
+// hasVariables returns true if the receiver is able to provide any variables
+// at all, either via its Variables map or via its VariableResolver.
+func (ctx *EvalContext) hasVariables() bool {
+	return ctx.Variables != nil || ctx.VariableResolver != nil
+}
+
+// variableNames returns the names of the variables known to the receiver
+// only, without considering its ancestors, for use in suggestions. A
+// VariableResolver cannot list the names it would accept, so only the
+// names it has already resolved are included.
+func (ctx *EvalContext) variableNames() []string {
+	names := make([]string, 0, len(ctx.Variables))
+	for name := range ctx.Variables {
+		names = append(names, name)
+	}
+	if ctx.VariableResolver == nil {
+		return names
+	}
+
+	cache := ctx.resolverCache()
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	for name, resolved := range cache.resolved {
+		if _, defined := ctx.Variables[name]; resolved.Exists && !defined {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// lookupVariable finds the value of the root variable of the given absolute
+// traversal in the receiver only, without considering its ancestors.
+func (ctx *EvalContext) lookupVariable(traversal Traversal) (cty.Value, bool, Diagnostics) {
+	name := traversal.RootName()
+	if val, exists := ctx.Variables[name]; exists {
+		return val, true, nil
+	}
+	if ctx.VariableResolver == nil {
+		return cty.NilVal, false, nil
+	}
+
+	cache := ctx.resolverCache()
+	cache.mu.Lock()
+	cached, isCached := cache.resolved[name]
+	cache.mu.Unlock()
+	if isCached {
+		return cached.Value, cached.Exists, nil
+	}
+
+	val, exists, diags := ctx.VariableResolver.ResolveVariable(traversal)
+	if diags.HasErrors() {
+		// We don't cache errors, so that each reference to a broken
+		// variable will produce its own diagnostics.
+		return cty.DynamicVal, true, diags
+	}
+
+	cache.mu.Lock()
+	cache.resolved[name] = resolvedVariable{
+		Value:  val,
+		Exists: exists,
+	}
+	cache.mu.Unlock()
+
+	return val, exists, diags
+}
@@ -0,0 +1,149 @@
+package hcl
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestEvalContextVariableResolver(t *testing.T) {
+	calls := map[string]int{}
+	resolver := VariableResolverFunc(func(traversal Traversal) (cty.Value, bool, Diagnostics) {
+		name := traversal.RootName()
+		calls[name]++
+		switch name {
+		case "lazy":
+			return cty.ObjectVal(map[string]cty.Value{
+				"id": cty.StringVal("lazy-id"),
+			}), true, nil
+		case "broken":
+			return cty.DynamicVal, true, Diagnostics{
+				{
+					Severity: DiagError,
+					Summary:  "Broken variable",
+					Subject:  traversal.SourceRange().Ptr(),
+				},
+			}
+		default:
+			return cty.NilVal, false, nil
+		}
+	})
+
+	ctx := &EvalContext{
+		Variables: map[string]cty.Value{
+			"eager":    cty.StringVal("eager"),
+			"shadowed": cty.StringVal("from map"),
+		},
+		VariableResolver: resolver,
+	}
+	child := ctx.NewChild()
+	child.Variables = map[string]cty.Value{
+		"inner": cty.StringVal("inner"),
+	}
+
+	tests := []struct {
+		traversal Traversal
+		want      cty.Value
+		wantErr   bool
+	}{
+		{
+			Traversal{TraverseRoot{Name: "eager"}},
+			cty.StringVal("eager"),
+			false,
+		},
+		{
+			Traversal{TraverseRoot{Name: "inner"}},
+			cty.StringVal("inner"),
+			false,
+		},
+		{
+			Traversal{TraverseRoot{Name: "shadowed"}},
+			cty.StringVal("from map"),
+			false,
+		},
+		{
+			Traversal{TraverseRoot{Name: "lazy"}, TraverseAttr{Name: "id"}},
+			cty.StringVal("lazy-id"),
+			false,
+		},
+		{
+			Traversal{TraverseRoot{Name: "lazy"}, TraverseAttr{Name: "id"}},
+			cty.StringVal("lazy-id"),
+			false,
+		},
+		{
+			Traversal{TraverseRoot{Name: "broken"}},
+			cty.DynamicVal,
+			true,
+		},
+		{
+			Traversal{TraverseRoot{Name: "missing"}},
+			cty.DynamicVal,
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.traversal.RootName(), func(t *testing.T) {
+			got, diags := test.traversal.TraverseAbs(child)
+			if test.wantErr != diags.HasErrors() {
+				t.Fatalf("wrong error result %t; want %t\n%s", diags.HasErrors(), test.wantErr, diags.Error())
+			}
+			if !got.RawEquals(test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+
+	if got, want := calls["lazy"], 1; got != want {
+		t.Errorf("resolver called %d times for \"lazy\"; want %d", got, want)
+	}
+	if got := calls["eager"]; got != 0 {
+		t.Errorf("resolver called %d times for \"eager\"; want 0", got)
+	}
+	if got, want := calls["broken"], 1; got != want {
+		t.Errorf("resolver called %d times for \"broken\"; want %d", got, want)
+	}
+}
+
+func TestEvalContextVariableResolverOnly(t *testing.T) {
+	// A context with only a resolver and no Variables map must still permit
+	// variables to be used.
+	ctx := &EvalContext{
+		VariableResolver: VariableResolverFunc(func(traversal Traversal) (cty.Value, bool, Diagnostics) {
+			return cty.True, true, nil
+		}),
+	}
+
+	got, diags := Traversal{TraverseRoot{Name: "anything"}}.TraverseAbs(ctx.NewChild())
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+	if !got.RawEquals(cty.True) {
+		t.Errorf("wrong result %#v; want %#v", got, cty.True)
+	}
+}
+
+func TestEvalContextVariableResolverSuggestions(t *testing.T) {
+	// Names already produced by a resolver are offered as suggestions for
+	// misspelled variables, just as names in Variables are.
+	ctx := &EvalContext{
+		VariableResolver: VariableResolverFunc(func(traversal Traversal) (cty.Value, bool, Diagnostics) {
+			if traversal.RootName() == "resolved" {
+				return cty.True, true, nil
+			}
+			return cty.NilVal, false, nil
+		}),
+	}
+
+	if _, diags := (Traversal{TraverseRoot{Name: "resolved"}}).TraverseAbs(ctx); diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+	_, diags := Traversal{TraverseRoot{Name: "resolvd"}}.TraverseAbs(ctx.NewChild())
+	if len(diags) != 1 {
+		t.Fatalf("got %d diagnostics; want 1", len(diags))
+	}
+	if got, want := diags[0].Detail, `There is no variable named "resolvd". Did you mean "resolved"?`; got != want {
+		t.Errorf("wrong detail\ngot:  %s\nwant: %s", got, want)
+	}
+}
@@ -411,13 +411,19 @@ func (e *FunctionCallExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnosti
 			})
 
 		default:
+			detail := fmt.Sprintf("Call to function %q failed: %s.", e.Name, err)
+			for _, argExpr := range e.Args {
+				if ctx.IsSensitiveExpr(argExpr) {
+					// The error message may include some of the argument
+					// values, so we can't safely show it.
+					detail = fmt.Sprintf("Call to function %q failed. The error message is not shown because the arguments include sensitive values.", e.Name)
+					break
+				}
+			}
 			diags = append(diags, &hcl.Diagnostic{
 				Severity: hcl.DiagError,
 				Summary:  "Error in function call",
-				Detail: fmt.Sprintf(
-					"Call to function %q failed: %s.",
-					e.Name, err,
-				),
+				Detail:   detail,
 				Subject:     e.StartRange().Ptr(),
 				Context:     e.Range().Ptr(),
 				Expression:  e,
@@ -909,6 +915,10 @@ func (e *ForExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 		return cty.DynamicVal, diags
 	}
 
+	// The iterator variables are derived from the collection, so if the
+	// collection is sensitive then so are they.
+	collSensitive := ctx.IsSensitiveExpr(e.CollExpr)
+
 	// Before we start we'll do an early check to see if any CondExpr we've
 	// been given is of the wrong type. This isn't 100% reliable (it may
 	// be DynamicVal until real values are given) but it should catch some
@@ -974,6 +984,9 @@ func (e *ForExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 				childCtx.Variables[e.KeyVar] = k
 			}
 			childCtx.Variables[e.ValVar] = v
+			if collSensitive {
+				childCtx.Sensitive = hcl.SensitiveNames(e.KeyVar, e.ValVar)
+			}
 
 			if e.CondExpr != nil {
 				includeRaw, condDiags := e.CondExpr.Value(childCtx)
@@ -1068,12 +1081,16 @@ func (e *ForExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 			} else {
 				k := key.AsString()
 				if _, exists := vals[k]; exists {
+					keyStr := fmt.Sprintf("the key %q", k)
+					if childCtx.IsSensitiveExpr(e.KeyExpr) {
+						keyStr = "the same sensitive key"
+					}
 					diags = append(diags, &hcl.Diagnostic{
 						Severity: hcl.DiagError,
 						Summary:  "Duplicate object key",
 						Detail: fmt.Sprintf(
-							"Two different items produced the key %q in this 'for' expression. If duplicates are expected, use the ellipsis (...) after the value expression to enable grouping by key.",
-							k,
+							"Two different items produced %s in this 'for' expression. If duplicates are expected, use the ellipsis (...) after the value expression to enable grouping by key.",
+							keyStr,
 						),
 						Subject:     e.KeyExpr.Range().Ptr(),
 						Context:     &e.SrcRange,
@@ -1114,6 +1131,9 @@ func (e *ForExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 				childCtx.Variables[e.KeyVar] = k
 			}
 			childCtx.Variables[e.ValVar] = v
+			if collSensitive {
+				childCtx.Sensitive = hcl.SensitiveNames(e.KeyVar, e.ValVar)
+			}
 
 			if e.CondExpr != nil {
 				includeRaw, condDiags := e.CondExpr.Value(childCtx)
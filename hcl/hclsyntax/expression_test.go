@@ -1,6 +1,7 @@
 package hclsyntax
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/hashicorp/hcl2/hcl"
@@ -1576,3 +1577,76 @@ func TestStaticExpressionList(t *testing.T) {
 		t.Fatalf("wrong first value %#v; want cty.Zero", first.Val)
 	}
 }
+
+func TestExpressionSensitiveDiagnostics(t *testing.T) {
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"secrets": cty.TupleVal([]cty.Value{
+				cty.StringVal("hunter2"),
+				cty.StringVal("hunter2"),
+			}),
+			"public": cty.TupleVal([]cty.Value{
+				cty.StringVal("hello"),
+				cty.StringVal("hello"),
+			}),
+		},
+		Functions: map[string]function.Function{
+			"fail": function.New(&function.Spec{
+				Params: []function.Parameter{
+					{
+						Name: "arg",
+						Type: cty.String,
+					},
+				},
+				Type: function.StaticReturnType(cty.String),
+				Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+					return cty.DynamicVal, fmt.Errorf("can't use %q", args[0].AsString())
+				},
+			}),
+		},
+		Sensitive: hcl.SensitiveNames("secrets"),
+	}
+
+	tests := []struct {
+		input      string
+		wantDetail string
+	}{
+		{
+			`{for v in public: v => v}`,
+			`Two different items produced the key "hello" in this 'for' expression. If duplicates are expected, use the ellipsis (...) after the value expression to enable grouping by key.`,
+		},
+		{
+			`{for v in secrets: v => v}`,
+			`Two different items produced the same sensitive key in this 'for' expression. If duplicates are expected, use the ellipsis (...) after the value expression to enable grouping by key.`,
+		},
+		{
+			`fail(public[0])`,
+			`Call to function "fail" failed: can't use "hello".`,
+		},
+		{
+			`fail(secrets[0])`,
+			`Call to function "fail" failed. The error message is not shown because the arguments include sensitive values.`,
+		},
+		{
+			`[for v in secrets: fail(v)]`,
+			`Call to function "fail" failed. The error message is not shown because the arguments include sensitive values.`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			expr, parseDiags := ParseExpression([]byte(test.input), "", hcl.Pos{Line: 1, Column: 1, Byte: 0})
+			if parseDiags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", parseDiags.Error())
+			}
+
+			_, diags := expr.Value(ctx)
+			if len(diags) == 0 {
+				t.Fatalf("no diagnostics; want at least one")
+			}
+			if got := diags[0].Detail; got != test.wantDetail {
+				t.Errorf("wrong detail\ngot:  %s\nwant: %s", got, test.wantDetail)
+			}
+		})
+	}
+}
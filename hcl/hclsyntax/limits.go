@@ -0,0 +1,341 @@
+package hclsyntax
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// Limits describes optional resource limits to apply while parsing, for use
+// when the input comes from an untrusted source.
+//
+// The zero value of each field means that no limit is applied for that
+// aspect of the input, so the zero value of Limits as a whole is equivalent
+// to calling the non-limited parsing functions.
+type Limits struct {
+	// MaxFileSize is the maximum number of bytes permitted in the source
+	// buffer.
+	MaxFileSize int
+
+	// MaxTokens is the maximum number of tokens the source buffer may
+	// produce when lexed, including the synthetic end-of-file token.
+	MaxTokens int
+
+	// MaxNestingDepth is the maximum depth of nested blocks and nested
+	// expression terms, such as parentheses, tuple and object constructors,
+	// function calls, index and splat operators, template interpolations
+	// and template directives.
+	MaxNestingDepth int
+
+	// MaxHeredocLength is the maximum number of bytes permitted between
+	// the opening and closing markers of a single heredoc template.
+	MaxHeredocLength int
+}
+
+// ParseConfigWithLimits is a variant of ParseConfig that enforces the given
+// limits, returning error diagnostics if the given buffer exceeds any of
+// them.
+//
+// If the file size, token count or heredoc length limits are exceeded then
+// the buffer is not parsed at all and the returned file has an empty body.
+// If the nesting depth limit is exceeded then parsing stops at the point
+// where the limit was reached, and so the returned body is incomplete.
+func ParseConfigWithLimits(src []byte, filename string, start hcl.Pos, limits Limits) (*hcl.File, hcl.Diagnostics) {
+	rng := hcl.Range{
+		Filename: filename,
+		Start:    start,
+		End:      start,
+	}
+	if diags := checkSizeLimit(src, rng, "Configuration file", limits); diags.HasErrors() {
+		return emptyFile(src, rng), diags
+	}
+
+	tokens, diags := LexConfig(src, filename, start)
+	if limitDiags := checkTokenLimits(tokens, limits); limitDiags.HasErrors() {
+		return emptyFile(src, rng), append(diags, limitDiags...)
+	}
+
+	peeker := newPeeker(tokens, false)
+	parser := &parser{
+		peeker:   peeker,
+		maxDepth: limits.MaxNestingDepth,
+	}
+	body, parseDiags := parser.ParseBody(TokenEOF)
+	diags = append(diags, parseDiags...)
+
+	// Panic if the parser uses incorrect stack discipline with the peeker's
+	// newlines stack, since otherwise it will produce confusing downstream
+	// errors.
+	peeker.AssertEmptyIncludeNewlinesStack()
+
+	return &hcl.File{
+		Body:  body,
+		Bytes: src,
+
+		Nav: navigation{
+			root: body,
+		},
+	}, diags
+}
+
+// ParseExpressionWithLimits is a variant of ParseExpression that enforces the
+// given limits, returning error diagnostics if the given buffer exceeds any
+// of them.
+//
+// If the size, token count or heredoc length limits are exceeded then the
+// buffer is not parsed at all and the result is a placeholder expression
+// whose value is unknown.
+func ParseExpressionWithLimits(src []byte, filename string, start hcl.Pos, limits Limits) (Expression, hcl.Diagnostics) {
+	rng := hcl.Range{
+		Filename: filename,
+		Start:    start,
+		End:      start,
+	}
+	if diags := checkSizeLimit(src, rng, "Expression", limits); diags.HasErrors() {
+		return errPlaceholderExpr(rng), diags
+	}
+
+	tokens, diags := LexExpression(src, filename, start)
+	if limitDiags := checkTokenLimits(tokens, limits); limitDiags.HasErrors() {
+		return errPlaceholderExpr(rng), append(diags, limitDiags...)
+	}
+
+	peeker := newPeeker(tokens, false)
+	parser := &parser{
+		peeker:   peeker,
+		maxDepth: limits.MaxNestingDepth,
+	}
+
+	// Bare expressions are always parsed in  "ignore newlines" mode, as if
+	// they were wrapped in parentheses.
+	parser.PushIncludeNewlines(false)
+
+	expr, parseDiags := parser.ParseExpression()
+	diags = append(diags, parseDiags...)
+
+	next := parser.Peek()
+	if next.Type != TokenEOF && !parser.recovery {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Extra characters after expression",
+			Detail:   "An expression was successfully parsed, but extra characters were found after it.",
+			Subject:  &next.Range,
+		})
+	}
+
+	parser.PopIncludeNewlines()
+
+	// Panic if the parser uses incorrect stack discipline with the peeker's
+	// newlines stack, since otherwise it will produce confusing downstream
+	// errors.
+	peeker.AssertEmptyIncludeNewlinesStack()
+
+	return expr, diags
+}
+
+// ParseTemplateWithLimits is a variant of ParseTemplate that enforces the
+// given limits, returning error diagnostics if the given buffer exceeds any
+// of them. Nested template directives count toward the nesting depth limit
+// along with the expressions within them.
+//
+// If the size, token count or heredoc length limits are exceeded then the
+// buffer is not parsed at all and the result is a placeholder expression
+// whose value is unknown.
+func ParseTemplateWithLimits(src []byte, filename string, start hcl.Pos, limits Limits) (Expression, hcl.Diagnostics) {
+	rng := hcl.Range{
+		Filename: filename,
+		Start:    start,
+		End:      start,
+	}
+	if diags := checkSizeLimit(src, rng, "Template", limits); diags.HasErrors() {
+		return errPlaceholderExpr(rng), diags
+	}
+
+	tokens, diags := LexTemplate(src, filename, start)
+	if limitDiags := checkTokenLimits(tokens, limits); limitDiags.HasErrors() {
+		return errPlaceholderExpr(rng), append(diags, limitDiags...)
+	}
+
+	peeker := newPeeker(tokens, false)
+	parser := &parser{
+		peeker:   peeker,
+		maxDepth: limits.MaxNestingDepth,
+	}
+	expr, parseDiags := parser.ParseTemplate()
+	diags = append(diags, parseDiags...)
+
+	// Panic if the parser uses incorrect stack discipline with the peeker's
+	// newlines stack, since otherwise it will produce confusing downstream
+	// errors.
+	peeker.AssertEmptyIncludeNewlinesStack()
+
+	return expr, diags
+}
+
+// ParseTraversalAbsWithLimits is a variant of ParseTraversalAbs that enforces
+// the given limits, returning error diagnostics if the given buffer exceeds
+// any of them.
+//
+// If the size or token count limits are exceeded then the buffer is not
+// parsed at all and the returned traversal is nil.
+func ParseTraversalAbsWithLimits(src []byte, filename string, start hcl.Pos, limits Limits) (hcl.Traversal, hcl.Diagnostics) {
+	rng := hcl.Range{
+		Filename: filename,
+		Start:    start,
+		End:      start,
+	}
+	if diags := checkSizeLimit(src, rng, "Traversal", limits); diags.HasErrors() {
+		return nil, diags
+	}
+
+	tokens, diags := LexExpression(src, filename, start)
+	if limitDiags := checkTokenLimits(tokens, limits); limitDiags.HasErrors() {
+		return nil, append(diags, limitDiags...)
+	}
+
+	peeker := newPeeker(tokens, false)
+	parser := &parser{
+		peeker:   peeker,
+		maxDepth: limits.MaxNestingDepth,
+	}
+
+	// Bare traverals are always parsed in  "ignore newlines" mode, as if
+	// they were wrapped in parentheses.
+	parser.PushIncludeNewlines(false)
+
+	expr, parseDiags := parser.ParseTraversalAbs()
+	diags = append(diags, parseDiags...)
+
+	parser.PopIncludeNewlines()
+
+	// Panic if the parser uses incorrect stack discipline with the peeker's
+	// newlines stack, since otherwise it will produce confusing downstream
+	// errors.
+	peeker.AssertEmptyIncludeNewlinesStack()
+
+	return expr, diags
+}
+
+// checkSizeLimit returns an error diagnostic if the given buffer is larger
+// than the maximum file size in the given limits. The what argument is a
+// capitalized noun describing the buffer, for use in the diagnostic.
+func checkSizeLimit(src []byte, rng hcl.Range, what string, limits Limits) hcl.Diagnostics {
+	if limits.MaxFileSize <= 0 || len(src) <= limits.MaxFileSize {
+		return nil
+	}
+	return hcl.Diagnostics{
+		{
+			Severity: hcl.DiagError,
+			Summary:  fmt.Sprintf("%s too large", what),
+			Detail: fmt.Sprintf(
+				"This %s is %d bytes long, but the maximum permitted size is %d bytes.",
+				strings.ToLower(what), len(src), limits.MaxFileSize,
+			),
+			Subject: &rng,
+		},
+	}
+}
+
+// checkTokenLimits checks the token-related limits from the given limits
+// object against the given token sequence, returning error diagnostics for
+// any that are exceeded.
+func checkTokenLimits(tokens Tokens, limits Limits) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	if limits.MaxTokens > 0 && len(tokens) > limits.MaxTokens {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Too many tokens",
+			Detail: fmt.Sprintf(
+				"This file contains %d tokens, but the maximum permitted is %d.",
+				len(tokens), limits.MaxTokens,
+			),
+			Subject: &tokens[limits.MaxTokens].Range,
+		})
+	}
+
+	if limits.MaxHeredocLength > 0 {
+		var open Token
+		for _, tok := range tokens {
+			switch tok.Type {
+			case TokenOHeredoc:
+				open = tok
+			case TokenCHeredoc:
+				if open.Type != TokenOHeredoc {
+					continue
+				}
+				length := tok.Range.Start.Byte - open.Range.End.Byte
+				if length > limits.MaxHeredocLength {
+					diags = append(diags, &hcl.Diagnostic{
+						Severity: hcl.DiagError,
+						Summary:  "Heredoc template too long",
+						Detail: fmt.Sprintf(
+							"This heredoc template is %d bytes long, but the maximum permitted length is %d bytes.",
+							length, limits.MaxHeredocLength,
+						),
+						Subject: hcl.RangeBetween(open.Range, tok.Range).Ptr(),
+					})
+				}
+				open = Token{}
+			}
+		}
+	}
+
+	return diags
+}
+
+// enterNesting increments the parser's nesting depth, returning an error
+// diagnostic if doing so exceeds the parser's maximum depth.
+//
+// When the maximum depth is exceeded the peeker is moved to the end of the
+// token stream, so that all of the callers in the current call stack will
+// unwind without recursing any further. Callers must call exitNesting
+// before returning, regardless of whether an error was returned.
+func (p *parser) enterNesting(rng hcl.Range) hcl.Diagnostics {
+	p.depth++
+	if p.maxDepth <= 0 || p.depth <= p.maxDepth {
+		return nil
+	}
+
+	p.setRecovery()
+	p.NextIndex = len(p.Tokens) - 1 // the final token is always TokenEOF
+	if p.depthExceeded {
+		// We only report the problem once, since all of the enclosing
+		// constructs are affected by the same problem.
+		return nil
+	}
+	p.depthExceeded = true
+	return hcl.Diagnostics{
+		{
+			Severity: hcl.DiagError,
+			Summary:  "Nesting too deep",
+			Detail: fmt.Sprintf(
+				"The configuration is nested more than %d levels deep at this point. Parsing cannot continue.",
+				p.maxDepth,
+			),
+			Subject: &rng,
+		},
+	}
+}
+
+func (p *parser) exitNesting() {
+	p.depth--
+}
+
+func emptyFile(src []byte, rng hcl.Range) *hcl.File {
+	body := &Body{
+		Attributes: Attributes{},
+		Blocks:     Blocks{},
+		SrcRange:   rng,
+		EndRange:   rng,
+	}
+	return &hcl.File{
+		Body:  body,
+		Bytes: src,
+
+		Nav: navigation{
+			root: body,
+		},
+	}
+}
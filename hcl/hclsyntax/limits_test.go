@@ -0,0 +1,303 @@
+package hclsyntax
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+func TestParseConfigWithLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		src         string
+		limits      Limits
+		wantSummary string // empty if no diagnostics expected
+	}{
+		{
+			"no limits",
+			"a = [[[[[[1]]]]]]\n",
+			Limits{},
+			"",
+		},
+		{
+			"within all limits",
+			"a = [[1]]\nb = <<EOT\nhello\nEOT\n",
+			Limits{
+				MaxFileSize:      100,
+				MaxTokens:        100,
+				MaxNestingDepth:  3,
+				MaxHeredocLength: 10,
+			},
+			"",
+		},
+		{
+			"file too large",
+			"a = 1\n",
+			Limits{MaxFileSize: 5},
+			"Configuration file too large",
+		},
+		{
+			"too many tokens",
+			"a = 1\nb = 2\n",
+			Limits{MaxTokens: 4},
+			"Too many tokens",
+		},
+		{
+			"heredoc too long",
+			"a = <<EOT\nhello world\nEOT\n",
+			Limits{MaxHeredocLength: 5},
+			"Heredoc template too long",
+		},
+		{
+			"expression nested too deeply",
+			"a = [[[[[[1]]]]]]\n",
+			Limits{MaxNestingDepth: 3},
+			"Nesting too deep",
+		},
+		{
+			"parentheses nested too deeply",
+			"a = " + strings.Repeat("(", 10000) + "1" + strings.Repeat(")", 10000) + "\n",
+			Limits{MaxNestingDepth: 100},
+			"Nesting too deep",
+		},
+		{
+			"unary operators nested too deeply",
+			"a = " + strings.Repeat("!", 10000) + "true\n",
+			Limits{MaxNestingDepth: 100},
+			"Nesting too deep",
+		},
+		{
+			"conditional false results nested too deeply",
+			"a = " + strings.Repeat("x ? 1 : ", 5000) + "1\n",
+			Limits{MaxNestingDepth: 10},
+			"Nesting too deep",
+		},
+		{
+			"conditional true results nested too deeply",
+			"a = " + strings.Repeat("x ? ", 5000) + "1" + strings.Repeat(" : 2", 5000) + "\n",
+			Limits{MaxNestingDepth: 10},
+			"Nesting too deep",
+		},
+		{
+			"indexes nested too deeply",
+			"a = " + strings.Repeat("a[", 20000) + "1" + strings.Repeat("]", 20000) + "\n",
+			Limits{MaxNestingDepth: 50},
+			"Nesting too deep",
+		},
+		{
+			"indexes nested far too deeply",
+			"a = " + strings.Repeat("a[", 200000) + "1" + strings.Repeat("]", 200000) + "\n",
+			Limits{MaxNestingDepth: 50},
+			"Nesting too deep",
+		},
+		{
+			"splats nested too deeply",
+			"a = x" + strings.Repeat("[*]", 200000) + "\n",
+			Limits{MaxNestingDepth: 50},
+			"Nesting too deep",
+		},
+		{
+			"blocks nested too deeply",
+			"a {\nb {\nc {\n}\n}\n}\n",
+			Limits{MaxNestingDepth: 2},
+			"Nesting too deep",
+		},
+		{
+			"template directives nested too deeply",
+			`a = "` + strings.Repeat("%{ if true }", 10000) + "x" + strings.Repeat("%{ endif }", 10000) + `"` + "\n",
+			Limits{MaxNestingDepth: 50},
+			"Nesting too deep",
+		},
+		{
+			"interpolations nested too deeply",
+			`a = "${"${"${"${1}"}"}"}"` + "\n",
+			Limits{MaxNestingDepth: 3},
+			"Nesting too deep",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file, diags := ParseConfigWithLimits([]byte(test.src), "test.hcl", hcl.Pos{Line: 1, Column: 1}, test.limits)
+			if file == nil || file.Body == nil {
+				t.Fatalf("got nil file or body; want placeholder")
+			}
+
+			checkLimitDiags(t, diags, test.wantSummary)
+		})
+	}
+}
+
+func TestParseExpressionWithLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		src         string
+		limits      Limits
+		wantSummary string // empty if no diagnostics expected
+	}{
+		{
+			"within all limits",
+			"a[b[0]]",
+			Limits{
+				MaxFileSize:     100,
+				MaxTokens:       100,
+				MaxNestingDepth: 3,
+			},
+			"",
+		},
+		{
+			"expression too large",
+			"a[b[0]]",
+			Limits{MaxFileSize: 5},
+			"Expression too large",
+		},
+		{
+			"too many tokens",
+			"a + b + c",
+			Limits{MaxTokens: 4},
+			"Too many tokens",
+		},
+		{
+			"indexes nested too deeply",
+			strings.Repeat("a[", 20000) + "1" + strings.Repeat("]", 20000),
+			Limits{MaxNestingDepth: 50},
+			"Nesting too deep",
+		},
+		{
+			"splats nested too deeply",
+			"x" + strings.Repeat("[*]", 200000),
+			Limits{MaxNestingDepth: 50},
+			"Nesting too deep",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, diags := ParseExpressionWithLimits([]byte(test.src), "test.hcl", hcl.Pos{Line: 1, Column: 1}, test.limits)
+			if expr == nil {
+				t.Fatalf("got nil expression; want placeholder")
+			}
+			checkLimitDiags(t, diags, test.wantSummary)
+		})
+	}
+}
+
+func TestParseTemplateWithLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		src         string
+		limits      Limits
+		wantSummary string // empty if no diagnostics expected
+	}{
+		{
+			"within all limits",
+			"%{ for x in xs }%{ if x }${x}%{ endif }%{ endfor }",
+			Limits{
+				MaxFileSize:     100,
+				MaxTokens:       100,
+				MaxNestingDepth: 3,
+			},
+			"",
+		},
+		{
+			"template too large",
+			"hello ${name}",
+			Limits{MaxFileSize: 5},
+			"Template too large",
+		},
+		{
+			"if directives nested too deeply",
+			strings.Repeat("%{ if true }", 10000) + "x" + strings.Repeat("%{ endif }", 10000),
+			Limits{MaxNestingDepth: 50},
+			"Nesting too deep",
+		},
+		{
+			"for directives nested too deeply",
+			strings.Repeat("%{ for x in xs }", 10000) + "x" + strings.Repeat("%{ endfor }", 10000),
+			Limits{MaxNestingDepth: 50},
+			"Nesting too deep",
+		},
+		{
+			"unclosed directives nested too deeply",
+			strings.Repeat("%{ if true }", 10000),
+			Limits{MaxNestingDepth: 50},
+			"Nesting too deep",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, diags := ParseTemplateWithLimits([]byte(test.src), "test.tmpl", hcl.Pos{Line: 1, Column: 1}, test.limits)
+			if expr == nil {
+				t.Fatalf("got nil expression; want placeholder")
+			}
+			checkLimitDiags(t, diags, test.wantSummary)
+		})
+	}
+}
+
+func TestParseTraversalAbsWithLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		src         string
+		limits      Limits
+		wantSummary string // empty if no diagnostics expected
+	}{
+		{
+			"within all limits",
+			"a.b[0]",
+			Limits{
+				MaxFileSize: 100,
+				MaxTokens:   100,
+			},
+			"",
+		},
+		{
+			"traversal too large",
+			"a.b.c.d",
+			Limits{MaxFileSize: 5},
+			"Traversal too large",
+		},
+		{
+			"too many tokens",
+			"a.b.c.d",
+			Limits{MaxTokens: 4},
+			"Too many tokens",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, diags := ParseTraversalAbsWithLimits([]byte(test.src), "test.hcl", hcl.Pos{Line: 1, Column: 1}, test.limits)
+			checkLimitDiags(t, diags, test.wantSummary)
+		})
+	}
+}
+
+// checkLimitDiags checks that the given diagnostics are either empty, if
+// wantSummary is empty, or consist of a single diagnostic with the given
+// summary and a subject range.
+func checkLimitDiags(t *testing.T, diags hcl.Diagnostics, wantSummary string) {
+	t.Helper()
+	if wantSummary == "" {
+		for _, diag := range diags {
+			t.Errorf("unexpected diagnostic: %s", diag.Error())
+		}
+		return
+	}
+
+	if len(diags) != 1 {
+		t.Errorf("wrong number of diagnostics %d; want 1", len(diags))
+		for _, diag := range diags {
+			t.Logf("- %s", diag.Error())
+		}
+		return
+	}
+	if got, want := diags[0].Summary, wantSummary; got != want {
+		t.Errorf("wrong summary %q; want %q", got, want)
+	}
+	if diags[0].Subject == nil {
+		t.Errorf("diagnostic has no subject")
+	}
+}
@@ -19,6 +19,14 @@ type parser struct {
 	// in recovery mode, assuming that the recovery heuristics have failed
 	// in this case and left the peeker in a wrong place.
 	recovery bool
+
+	// depth is the current nesting depth of blocks and expression terms,
+	// which is compared to maxDepth (if greater than zero) to detect input
+	// that is nested too deeply. depthExceeded is set once that has
+	// happened, so that the problem is reported only once.
+	depth         int
+	maxDepth      int
+	depthExceeded bool
 }
 
 func (p *parser) ParseBody(end TokenType) (*Body, hcl.Diagnostics) {
@@ -355,6 +363,8 @@ Token:
 	// brace, so we can begin our nested body parsing.
 	var body *Body
 	var bodyDiags hcl.Diagnostics
+	diags = append(diags, p.enterNesting(oBrace.Range)...)
+	defer p.exitNesting()
 	switch p.Peek().Type {
 	case TokenNewline, TokenEOF, TokenCBrace:
 		body, bodyDiags = p.ParseBody(TokenCBrace)
@@ -459,6 +469,16 @@ func (p *parser) parseTernaryConditional() (Expression, hcl.Diagnostics) {
 
 	p.Read() // eat question mark
 
+	// The result expressions are parsed recursively, so we must count them
+	// towards the nesting depth just as for the other recursive constructs
+	// that parseExpressionTerm deals with.
+	if nestDiags := p.enterNesting(questionMark.Range); p.depthExceeded {
+		p.exitNesting()
+		diags = append(diags, nestDiags...)
+		return errPlaceholderExpr(hcl.RangeBetween(startRange, questionMark.Range)), diags
+	}
+	defer p.exitNesting()
+
 	trueExpr, trueDiags := p.ParseExpression()
 	diags = append(diags, trueDiags...)
 	if p.recovery && trueDiags.HasErrors() {
@@ -776,6 +796,16 @@ Traversal:
 			// the key value is something constant.
 
 			open := p.Read()
+
+			// Each index or splat can contain further nested expressions, or
+			// in the case of a full splat recurses into this function for
+			// the remaining steps, so each one counts as a nesting level.
+			if nestDiags := p.enterNesting(open.Range); p.depthExceeded {
+				p.exitNesting()
+				diags = append(diags, nestDiags...)
+				break Traversal
+			}
+
 			switch p.Peek().Type {
 			case TokenStar:
 				// This is a full splat expression, like foo[*], which consumes
@@ -853,6 +883,7 @@ Traversal:
 					}
 				}
 			}
+			p.exitNesting()
 
 		default:
 			break Traversal
@@ -888,6 +919,12 @@ func makeRelativeTraversal(expr Expression, next hcl.Traverser, rng hcl.Range) E
 func (p *parser) parseExpressionTerm() (Expression, hcl.Diagnostics) {
 	start := p.Peek()
 
+	if diags := p.enterNesting(start.Range); p.depthExceeded {
+		p.exitNesting()
+		return errPlaceholderExpr(start.Range), diags
+	}
+	defer p.exitNesting()
+
 	switch start.Type {
 	case TokenOParen:
 		p.Read() // eat open paren
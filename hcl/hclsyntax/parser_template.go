@@ -41,6 +41,7 @@ func (p *parser) parseTemplateInner(end TokenType, flushHeredoc bool) ([]Express
 	tp := templateParser{
 		Tokens:   parts.Tokens,
 		SrcRange: parts.SrcRange,
+		parser:   p,
 	}
 	exprs, exprsDiags := tp.parseRoot()
 	diags = append(diags, exprsDiags...)
@@ -59,6 +60,10 @@ type templateParser struct {
 	Tokens   []templateToken
 	SrcRange hcl.Range
 
+	// parser is the expression parser that produced the template tokens,
+	// whose nesting depth the template directives also count toward.
+	parser *parser
+
 	pos int
 }
 
@@ -96,9 +101,19 @@ func (p *templateParser) parseExpr() (Expression, hcl.Diagnostics) {
 		return tok.Expr, nil
 
 	case *templateIfToken:
+		if diags := p.enterNesting(tok.SrcRange); p.parser.depthExceeded {
+			p.parser.exitNesting()
+			return errPlaceholderExpr(tok.SrcRange), diags
+		}
+		defer p.parser.exitNesting()
 		return p.parseIf()
 
 	case *templateForToken:
+		if diags := p.enterNesting(tok.SrcRange); p.parser.depthExceeded {
+			p.parser.exitNesting()
+			return errPlaceholderExpr(tok.SrcRange), diags
+		}
+		defer p.parser.exitNesting()
 		return p.parseFor()
 
 	case *templateEndToken:
@@ -149,6 +164,11 @@ Token:
 	for {
 		next := p.Peek()
 		if end, isEnd := next.(*templateEndToken); isEnd {
+			if p.parser.depthExceeded {
+				// We skipped to the end after reporting that the
+				// directives are nested too deeply.
+				return errPlaceholderExpr(end.SrcRange), diags
+			}
 			diags = append(diags, &hcl.Diagnostic{
 				Severity: hcl.DiagError,
 				Summary:  "Unexpected end of template",
@@ -260,6 +280,11 @@ Token:
 	for {
 		next := p.Peek()
 		if end, isEnd := next.(*templateEndToken); isEnd {
+			if p.parser.depthExceeded {
+				// We skipped to the end after reporting that the
+				// directives are nested too deeply.
+				return errPlaceholderExpr(end.SrcRange), diags
+			}
 			diags = append(diags, &hcl.Diagnostic{
 				Severity: hcl.DiagError,
 				Summary:  "Unexpected end of template",
@@ -341,6 +366,19 @@ Token:
 	}, diags
 }
 
+// enterNesting increments the nesting depth of the underlying parser. If
+// that exceeds the maximum depth then the template parser also skips to its
+// end token, so that the enclosing directives will unwind without parsing
+// any further. Callers must call exitNesting on the underlying parser
+// before returning, regardless of whether an error was returned.
+func (p *templateParser) enterNesting(rng hcl.Range) hcl.Diagnostics {
+	diags := p.parser.enterNesting(rng)
+	if p.parser.depthExceeded {
+		p.pos = len(p.Tokens) - 1 // the final token is always the end token
+	}
+	return diags
+}
+
 func (p *templateParser) Peek() templateToken {
 	return p.Tokens[p.pos]
 }
@@ -15,76 +15,19 @@ import (
 // should be served using the hcl.Body interface to ensure compatibility with
 // other configurationg syntaxes, such as JSON.
 func ParseConfig(src []byte, filename string, start hcl.Pos) (*hcl.File, hcl.Diagnostics) {
-	tokens, diags := LexConfig(src, filename, start)
-	peeker := newPeeker(tokens, false)
-	parser := &parser{peeker: peeker}
-	body, parseDiags := parser.ParseBody(TokenEOF)
-	diags = append(diags, parseDiags...)
-
-	// Panic if the parser uses incorrect stack discipline with the peeker's
-	// newlines stack, since otherwise it will produce confusing downstream
-	// errors.
-	peeker.AssertEmptyIncludeNewlinesStack()
-
-	return &hcl.File{
-		Body:  body,
-		Bytes: src,
-
-		Nav: navigation{
-			root: body,
-		},
-	}, diags
+	return ParseConfigWithLimits(src, filename, start, Limits{})
 }
 
 // ParseExpression parses the given buffer as a standalone HCL expression,
 // returning it as an instance of Expression.
 func ParseExpression(src []byte, filename string, start hcl.Pos) (Expression, hcl.Diagnostics) {
-	tokens, diags := LexExpression(src, filename, start)
-	peeker := newPeeker(tokens, false)
-	parser := &parser{peeker: peeker}
-
-	// Bare expressions are always parsed in  "ignore newlines" mode, as if
-	// they were wrapped in parentheses.
-	parser.PushIncludeNewlines(false)
-
-	expr, parseDiags := parser.ParseExpression()
-	diags = append(diags, parseDiags...)
-
-	next := parser.Peek()
-	if next.Type != TokenEOF && !parser.recovery {
-		diags = append(diags, &hcl.Diagnostic{
-			Severity: hcl.DiagError,
-			Summary:  "Extra characters after expression",
-			Detail:   "An expression was successfully parsed, but extra characters were found after it.",
-			Subject:  &next.Range,
-		})
-	}
-
-	parser.PopIncludeNewlines()
-
-	// Panic if the parser uses incorrect stack discipline with the peeker's
-	// newlines stack, since otherwise it will produce confusing downstream
-	// errors.
-	peeker.AssertEmptyIncludeNewlinesStack()
-
-	return expr, diags
+	return ParseExpressionWithLimits(src, filename, start, Limits{})
 }
 
 // ParseTemplate parses the given buffer as a standalone HCL template,
 // returning it as an instance of Expression.
 func ParseTemplate(src []byte, filename string, start hcl.Pos) (Expression, hcl.Diagnostics) {
-	tokens, diags := LexTemplate(src, filename, start)
-	peeker := newPeeker(tokens, false)
-	parser := &parser{peeker: peeker}
-	expr, parseDiags := parser.ParseTemplate()
-	diags = append(diags, parseDiags...)
-
-	// Panic if the parser uses incorrect stack discipline with the peeker's
-	// newlines stack, since otherwise it will produce confusing downstream
-	// errors.
-	peeker.AssertEmptyIncludeNewlinesStack()
-
-	return expr, diags
+	return ParseTemplateWithLimits(src, filename, start, Limits{})
 }
 
 // ParseTraversalAbs parses the given buffer as a standalone absolute traversal.
@@ -94,25 +37,7 @@ func ParseTemplate(src []byte, filename string, start hcl.Pos) (Expression, hcl.
 // are useful as a syntax for referring to objects without necessarily
 // evaluating them.
 func ParseTraversalAbs(src []byte, filename string, start hcl.Pos) (hcl.Traversal, hcl.Diagnostics) {
-	tokens, diags := LexExpression(src, filename, start)
-	peeker := newPeeker(tokens, false)
-	parser := &parser{peeker: peeker}
-
-	// Bare traverals are always parsed in  "ignore newlines" mode, as if
-	// they were wrapped in parentheses.
-	parser.PushIncludeNewlines(false)
-
-	expr, parseDiags := parser.ParseTraversalAbs()
-	diags = append(diags, parseDiags...)
-
-	parser.PopIncludeNewlines()
-
-	// Panic if the parser uses incorrect stack discipline with the peeker's
-	// newlines stack, since otherwise it will produce confusing downstream
-	// errors.
-	peeker.AssertEmptyIncludeNewlinesStack()
-
-	return expr, diags
+	return ParseTraversalAbsWithLimits(src, filename, start, Limits{})
 }
 
 // LexConfig performs lexical analysis on the given buffer, treating it as a
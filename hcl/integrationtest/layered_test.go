@@ -0,0 +1,125 @@
+package integrationtest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+)
+
+// TestLayeredConfig merges a base configuration with environment-specific
+// and local overrides, as an application with layered configuration files
+// might do.
+func TestLayeredConfig(t *testing.T) {
+	sources := map[string]string{
+		"base.hcl": `
+name = "app"
+port = 8080
+
+service "web" {
+  replicas = 1
+  image    = "web:1"
+}
+
+service "worker" {
+  replicas = 1
+  image    = "worker:1"
+}
+
+hook {
+  command = "setup"
+}
+`,
+		"prod.hcl": `
+port = 443
+
+service "web" {
+  replicas = 3
+}
+
+hook {
+  command = "notify"
+}
+`,
+		"local.hcl": `
+service "web" {
+  image = "web:dev"
+}
+`,
+	}
+
+	var bodies []hcl.Body
+	for _, filename := range []string{"base.hcl", "prod.hcl", "local.hcl"} {
+		f, diags := hclsyntax.ParseConfig([]byte(sources[filename]), filename, hcl.Pos{Line: 1, Column: 1})
+		if diags.HasErrors() {
+			t.Fatalf("unexpected diagnostics parsing %s: %s", filename, diags.Error())
+		}
+		bodies = append(bodies, f.Body)
+	}
+
+	body := hcl.MergeBodiesWithOptions(bodies, hcl.MergeOptions{
+		Attributes: hcl.MergeAttributesOverride,
+		Blocks:     hcl.MergeBlocksDeep,
+		BlockTypes: map[string]hcl.BlockMergeMode{
+			"hook": hcl.MergeBlocksAppend,
+		},
+	})
+
+	type Service struct {
+		Name     string `hcl:"name,label"`
+		Replicas int    `hcl:"replicas"`
+		Image    string `hcl:"image"`
+	}
+	type Hook struct {
+		Command string `hcl:"command"`
+	}
+	type Root struct {
+		Name     string    `hcl:"name"`
+		Port     int       `hcl:"port"`
+		Services []Service `hcl:"service,block"`
+		Hooks    []Hook    `hcl:"hook,block"`
+	}
+	var got Root
+	diags := gohcl.DecodeBody(body, nil, &got)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %s", diags.Error())
+	}
+
+	want := Root{
+		Name: "app",
+		Port: 443,
+		Services: []Service{
+			{Name: "web", Replicas: 3, Image: "web:dev"},
+			{Name: "worker", Replicas: 1, Image: "worker:1"},
+		},
+		Hooks: []Hook{
+			{Command: "setup"},
+			{Command: "notify"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	// The provenance of the overridden port should be available so that
+	// the application can describe it in diagnostics.
+	content, _, _ := body.PartialContent(&hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{{Name: "port"}},
+	})
+	port := content.Attributes["port"]
+	if got, want := port.NameRange.Filename, "prod.hcl"; got != want {
+		t.Errorf("port is from %s; want %s", got, want)
+	}
+	overridden := hcl.OverriddenAttributes(port)
+	if len(overridden) != 1 {
+		t.Fatalf("wrong number of overridden attributes %d; want 1", len(overridden))
+	}
+	if got, want := overridden[0].NameRange.String(), "base.hcl:3,1-5"; got != want {
+		t.Errorf("overridden port is at %s; want %s", got, want)
+	}
+	if _, isLiteral := hcl.UnwrapExpression(port.Expr).(*hclsyntax.LiteralValueExpr); !isLiteral {
+		t.Errorf("unwrapped expression is %T; want *hclsyntax.LiteralValueExpr", hcl.UnwrapExpression(port.Expr))
+	}
+}
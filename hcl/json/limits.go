@@ -0,0 +1,20 @@
+package json
+
+// Limits describes optional resource limits to apply while parsing, for use
+// when the input comes from an untrusted source.
+//
+// The zero value of each field means that no limit is applied for that
+// aspect of the input, so the zero value of Limits as a whole is equivalent
+// to calling Parse.
+type Limits struct {
+	// MaxFileSize is the maximum number of bytes permitted in the source
+	// buffer.
+	MaxFileSize int
+
+	// MaxTokens is the maximum number of tokens the source buffer may
+	// produce when scanned, including the synthetic end-of-file token.
+	MaxTokens int
+
+	// MaxNestingDepth is the maximum depth of nested JSON objects and arrays.
+	MaxNestingDepth int
+}
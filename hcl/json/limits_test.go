@@ -0,0 +1,86 @@
+package json
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseWithLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		src         string
+		limits      Limits
+		wantSummary string // empty if no diagnostics expected
+	}{
+		{
+			"no limits",
+			`{"a": [[[[[[1]]]]]]}`,
+			Limits{},
+			"",
+		},
+		{
+			"within all limits",
+			`{"a": [[1]]}`,
+			Limits{
+				MaxFileSize:     100,
+				MaxTokens:       100,
+				MaxNestingDepth: 3,
+			},
+			"",
+		},
+		{
+			"file too large",
+			`{"a": 1}`,
+			Limits{MaxFileSize: 5},
+			"Configuration file too large",
+		},
+		{
+			"too many tokens",
+			`{"a": 1, "b": 2}`,
+			Limits{MaxTokens: 4},
+			"Too many tokens",
+		},
+		{
+			"nested too deeply",
+			`{"a": [[[[[[1]]]]]]}`,
+			Limits{MaxNestingDepth: 3},
+			"Nesting too deep",
+		},
+		{
+			"nested very deeply",
+			`{"a": ` + strings.Repeat("[", 100000) + strings.Repeat("]", 100000) + `}`,
+			Limits{MaxNestingDepth: 100},
+			"Nesting too deep",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file, diags := ParseWithLimits([]byte(test.src), "test.json", test.limits)
+			if file == nil || file.Body == nil {
+				t.Fatalf("got nil file or body; want placeholder")
+			}
+
+			if test.wantSummary == "" {
+				for _, diag := range diags {
+					t.Errorf("unexpected diagnostic: %s", diag.Error())
+				}
+				return
+			}
+
+			if len(diags) != 1 {
+				t.Errorf("wrong number of diagnostics %d; want 1", len(diags))
+				for _, diag := range diags {
+					t.Logf("- %s", diag.Error())
+				}
+				return
+			}
+			if got, want := diags[0].Summary, test.wantSummary; got != want {
+				t.Errorf("wrong summary %q; want %q", got, want)
+			}
+			if diags[0].Subject == nil {
+				t.Errorf("diagnostic has no subject")
+			}
+		})
+	}
+}
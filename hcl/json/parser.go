@@ -9,6 +9,10 @@ import (
 )
 
 func parseFileContent(buf []byte, filename string) (node, hcl.Diagnostics) {
+	return parseFileContentWithLimits(buf, filename, Limits{})
+}
+
+func parseFileContentWithLimits(buf []byte, filename string, limits Limits) (node, hcl.Diagnostics) {
 	tokens := scan(buf, pos{
 		Filename: filename,
 		Pos: hcl.Pos{
@@ -17,7 +21,21 @@ func parseFileContent(buf []byte, filename string) (node, hcl.Diagnostics) {
 			Column: 1,
 		},
 	})
+	if limits.MaxTokens > 0 && len(tokens) > limits.MaxTokens {
+		return nil, hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Too many tokens",
+				Detail: fmt.Sprintf(
+					"This file contains %d tokens, but the maximum permitted is %d.",
+					len(tokens), limits.MaxTokens,
+				),
+				Subject: &tokens[limits.MaxTokens].Range,
+			},
+		}
+	}
 	p := newPeeker(tokens)
+	p.maxDepth = limits.MaxNestingDepth
 	node, diags := parseValue(p)
 	if len(diags) == 0 && p.Peek().Type != tokenEOF {
 		diags = diags.Append(&hcl.Diagnostic{
@@ -41,9 +59,26 @@ func parseValue(p *peeker) (node, hcl.Diagnostics) {
 	}
 
 	switch tok.Type {
-	case tokenBraceO:
-		return wrapInvalid(parseObject(p))
-	case tokenBrackO:
+	case tokenBraceO, tokenBrackO:
+		if p.maxDepth > 0 && p.depth >= p.maxDepth {
+			skipNestedValue(p)
+			return wrapInvalid(nil, hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  "Nesting too deep",
+					Detail: fmt.Sprintf(
+						"JSON objects and arrays may be nested at most %d levels deep.",
+						p.maxDepth,
+					),
+					Subject: &tok.Range,
+				},
+			})
+		}
+		p.depth++
+		defer func() { p.depth-- }()
+		if tok.Type == tokenBraceO {
+			return wrapInvalid(parseObject(p))
+		}
 		return wrapInvalid(parseArray(p))
 	case tokenNumber:
 		return wrapInvalid(parseNumber(p))
@@ -90,6 +125,27 @@ func parseValue(p *peeker) (node, hcl.Diagnostics) {
 	}
 }
 
+// skipNestedValue moves the peeker past the object or array that begins at
+// its current position, without any recursion, so that we can skip over
+// values that are nested too deeply to parse.
+func skipNestedValue(p *peeker) {
+	nest := 0
+	for {
+		tok := p.Read()
+		switch tok.Type {
+		case tokenBraceO, tokenBrackO:
+			nest++
+		case tokenBraceC, tokenBrackC:
+			nest--
+			if nest <= 0 {
+				return
+			}
+		case tokenEOF:
+			return
+		}
+	}
+}
+
 func tokenCanStartValue(tok token) bool {
 	switch tok.Type {
 	case tokenBraceO, tokenBrackO, tokenNumber, tokenString, tokenKeyword:
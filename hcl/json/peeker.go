@@ -3,6 +3,12 @@ package json
 type peeker struct {
 	tokens []token
 	pos    int
+
+	// depth is the current nesting depth of objects and arrays, which is
+	// compared to maxDepth (if greater than zero) to detect input that is
+	// nested too deeply.
+	depth    int
+	maxDepth int
 }
 
 func newPeeker(tokens []token) *peeker {
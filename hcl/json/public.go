@@ -18,11 +18,48 @@ import (
 // from its HasErrors method. If HasErrors returns true, the file represents
 // the subset of data that was able to be parsed, which may be none.
 func Parse(src []byte, filename string) (*hcl.File, hcl.Diagnostics) {
-	rootNode, diags := parseFileContent(src, filename)
+	return ParseWithLimits(src, filename, Limits{})
+}
+
+// ParseWithLimits is a variant of Parse that enforces the given limits,
+// returning error diagnostics if the given buffer exceeds any of them.
+//
+// If the file size or token count limits are exceeded then the buffer is not
+// parsed at all and the returned file has an empty body. If the nesting depth
+// limit is exceeded then the value that exceeds it is skipped, and so the
+// returned body is incomplete.
+func ParseWithLimits(src []byte, filename string, limits Limits) (*hcl.File, hcl.Diagnostics) {
+	if limits.MaxFileSize > 0 && len(src) > limits.MaxFileSize {
+		rootNode := emptyRootNode(filename)
+		file := &hcl.File{
+			Body: &body{
+				val: rootNode,
+			},
+			Bytes: src,
+			Nav:   navigation{rootNode},
+		}
+		return file, hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Configuration file too large",
+				Detail: fmt.Sprintf(
+					"This file is %d bytes long, but the maximum permitted size is %d bytes.",
+					len(src), limits.MaxFileSize,
+				),
+				Subject: rootNode.SrcRange.Ptr(),
+			},
+		}
+	}
+
+	rootNode, diags := parseFileContentWithLimits(src, filename, limits)
 
 	switch rootNode.(type) {
 	case *objectVal, *arrayVal:
 		// okay
+	case nil:
+		// The content was not parsed at all, because it exceeded one of
+		// the given limits. We already produced an error message for that.
+		rootNode = emptyRootNode(filename)
 	default:
 		diags = diags.Append(&hcl.Diagnostic{
 			Severity: hcl.DiagError,
@@ -35,21 +72,7 @@ func Parse(src []byte, filename string) (*hcl.File, hcl.Diagnostics) {
 		// invalid, we'll return an empty placeholder here so that trying to
 		// extract content from our root body won't produce a redundant
 		// error saying the same thing again in more general terms.
-		fakePos := hcl.Pos{
-			Byte:   0,
-			Line:   1,
-			Column: 1,
-		}
-		fakeRange := hcl.Range{
-			Filename: filename,
-			Start:    fakePos,
-			End:      fakePos,
-		}
-		rootNode = &objectVal{
-			Attrs:     []*objectAttr{},
-			SrcRange:  fakeRange,
-			OpenRange: fakeRange,
-		}
+		rootNode = emptyRootNode(filename)
 	}
 
 	file := &hcl.File{
@@ -62,6 +85,26 @@ func Parse(src []byte, filename string) (*hcl.File, hcl.Diagnostics) {
 	return file, diags
 }
 
+// emptyRootNode returns an empty object to use as a placeholder root node
+// when the real root node is invalid or absent.
+func emptyRootNode(filename string) *objectVal {
+	fakePos := hcl.Pos{
+		Byte:   0,
+		Line:   1,
+		Column: 1,
+	}
+	fakeRange := hcl.Range{
+		Filename: filename,
+		Start:    fakePos,
+		End:      fakePos,
+	}
+	return &objectVal{
+		Attrs:     []*objectAttr{},
+		SrcRange:  fakeRange,
+		OpenRange: fakeRange,
+	}
+}
+
 // ParseFile is a convenience wrapper around Parse that first attempts to load
 // data from the given filename, passing the result to Parse if successful.
 //
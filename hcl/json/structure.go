@@ -229,6 +229,11 @@ func (b *body) MissingItemRange() hcl.Range {
 	}
 }
 
+// Range returns the range of the JSON value that the body was produced from.
+func (b *body) Range() hcl.Range {
+	return b.val.Range()
+}
+
 func (b *body) unpackBlock(v node, typeName string, typeRange *hcl.Range, labelsLeft []string, labelsUsed []string, labelRanges []hcl.Range, blocks *hcl.Blocks) (diags hcl.Diagnostics) {
 	if len(labelsLeft) > 0 {
 		labelName := labelsLeft[0]
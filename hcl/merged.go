@@ -22,6 +22,9 @@ func MergeFiles(files []*File) Body {
 
 // MergeBodies is like MergeFiles except it deals directly with bodies, rather
 // than with entire files.
+//
+// Use MergeBodiesWithOptions instead to allow later bodies to override or
+// extend the content of earlier ones.
 func MergeBodies(bodies []Body) Body {
 	if len(bodies) == 0 {
 		// Swap out for our singleton empty body, to reduce the number of
@@ -64,6 +67,189 @@ func MergeBodies(bodies []Body) Body {
 	return mergedBodies(new)
 }
 
+// MergeOptions customizes how MergeBodiesWithOptions combines the content
+// of its bodies when more than one of them defines the same attribute or
+// block.
+//
+// The zero value of MergeOptions gives the same behavior as MergeBodies.
+type MergeOptions struct {
+	// Attributes decides what happens when more than one body sets the same
+	// attribute.
+	Attributes AttributeMergeMode
+
+	// Blocks decides what happens when more than one body contains a block
+	// of the same type and labels, for any block type not in BlockTypes.
+	Blocks BlockMergeMode
+
+	// BlockTypes optionally overrides Blocks for specific block types, keyed
+	// by block type name.
+	BlockTypes map[string]BlockMergeMode
+
+	// Nested optionally gives the options for merging the bodies of blocks
+	// that are merged by MergeBlocksDeep or MergeBlocksOverride. If this is
+	// nil then the bodies are merged using the same options.
+	Nested *MergeOptions
+}
+
+func (o MergeOptions) blockMode(typeName string) BlockMergeMode {
+	if mode, exists := o.BlockTypes[typeName]; exists {
+		return mode
+	}
+	return o.Blocks
+}
+
+func (o MergeOptions) nested() MergeOptions {
+	if o.Nested != nil {
+		return *o.Nested
+	}
+	return o
+}
+
+func (o MergeOptions) isZero() bool {
+	return o.Attributes == MergeAttributesUnique && o.Blocks == MergeBlocksAppend && len(o.BlockTypes) == 0 && o.Nested == nil
+}
+
+// AttributeMergeMode is used in MergeOptions to decide how attributes of the
+// same name in different bodies are combined.
+type AttributeMergeMode int
+
+const (
+	// MergeAttributesUnique produces an error diagnostic if more than one
+	// body sets the same attribute. This is the behavior of MergeBodies.
+	MergeAttributesUnique AttributeMergeMode = iota
+
+	// MergeAttributesOverride uses the definition of an attribute from the
+	// last body that sets it, ignoring any earlier definitions. The earlier
+	// definitions can be recovered using OverriddenAttributes.
+	MergeAttributesOverride
+)
+
+// BlockMergeMode is used in MergeOptions to decide how blocks of the same
+// type and labels in different bodies are combined.
+type BlockMergeMode int
+
+const (
+	// MergeBlocksAppend returns all of the blocks from all of the bodies,
+	// in order. This is the behavior of MergeBodies.
+	MergeBlocksAppend BlockMergeMode = iota
+
+	// MergeBlocksReplace returns only the last block with a given type and
+	// labels, at the position of the first.
+	MergeBlocksReplace
+
+	// MergeBlocksDeep returns a single block for each distinct type and
+	// labels, at the position of the first, whose body is in turn a merge
+	// of the bodies of all of the blocks with those labels using the same
+	// options, or those given in MergeOptions.Nested.
+	MergeBlocksDeep
+
+	// MergeBlocksOverride treats the blocks of the first body as base blocks,
+	// which are returned in order, and merges each block from the later
+	// bodies into every base block of the same type and labels, as for
+	// MergeBlocksDeep. It is an error for a later body to contain a block
+	// for which there is no such base block.
+	MergeBlocksOverride
+
+	// MergeBlocksReplaceType returns the blocks of a given type only from
+	// the last body that contains any blocks of that type.
+	MergeBlocksReplaceType
+)
+
+// MergeBodiesWithOptions is like MergeBodies except that the given options
+// decide how content defined in more than one of the bodies is combined,
+// with later bodies taking precedence over earlier ones.
+//
+// This is intended for layered configuration, where for example a base
+// configuration is overridden by environment-specific settings and then by
+// local settings:
+//
+//	body := hcl.MergeBodiesWithOptions(
+//	    []hcl.Body{base.Body, env.Body, local.Body},
+//	    hcl.MergeOptions{
+//	        Attributes: hcl.MergeAttributesOverride,
+//	        Blocks:     hcl.MergeBlocksDeep,
+//	    },
+//	)
+//
+// The bodies of merged blocks and any remaining bodies returned from
+// PartialContent are merged using the same options.
+func MergeBodiesWithOptions(bodies []Body, opts MergeOptions) Body {
+	if opts.isZero() {
+		return MergeBodies(bodies)
+	}
+	if len(bodies) == 1 {
+		return bodies[0]
+	}
+	return &optionsMergedBodies{
+		bodies: bodies,
+		opts:   opts,
+	}
+}
+
+// optionsMergedBodies is the implementation of MergeBodiesWithOptions. Unlike
+// mergedBodies we cannot flatten nested merged bodies into this one, because
+// the nested bodies may have been merged with different options.
+type optionsMergedBodies struct {
+	bodies []Body
+	opts   MergeOptions
+}
+
+func (mb *optionsMergedBodies) Content(schema *BodySchema) (*BodyContent, Diagnostics) {
+	content, _, diags := mergedContent(mb.bodies, schema, false, mb.opts)
+	return content, diags
+}
+
+func (mb *optionsMergedBodies) PartialContent(schema *BodySchema) (*BodyContent, Body, Diagnostics) {
+	return mergedContent(mb.bodies, schema, true, mb.opts)
+}
+
+func (mb *optionsMergedBodies) JustAttributes() (Attributes, Diagnostics) {
+	return mergedAttributes(mb.bodies, mb.opts)
+}
+
+func (mb *optionsMergedBodies) MissingItemRange() Range {
+	return mergedBodies(mb.bodies).MissingItemRange()
+}
+
+// OverriddenAttributes returns the earlier definitions of the given attribute
+// that were overridden by it in a body produced by MergeBodiesWithOptions
+// using MergeAttributesOverride, in the order they were defined. The result
+// is nil if the attribute did not override any others.
+//
+// This allows applications to refer to all of the definitions of an attribute
+// in diagnostics, such as by noting that a value was overridden at the
+// location of the returned attribute.
+func OverriddenAttributes(attr *Attribute) []*Attribute {
+	if expr, isOverride := attr.Expr.(*overrideExpr); isOverride {
+		return expr.overridden
+	}
+	return nil
+}
+
+// overrideExpr wraps the expression of an attribute that overrides others in
+// a merged body, to record the overridden attributes for
+// OverriddenAttributes.
+type overrideExpr struct {
+	Expression
+	overridden []*Attribute
+}
+
+func (e *overrideExpr) UnwrapExpression() Expression {
+	return e.Expression
+}
+
+// unwrapOverride returns the given attribute without the record of any
+// attributes it overrode.
+func unwrapOverride(attr *Attribute) *Attribute {
+	expr, isOverride := attr.Expr.(*overrideExpr)
+	if !isOverride {
+		return attr
+	}
+	ret := *attr
+	ret.Expr = expr.Expression
+	return &ret
+}
+
 var emptyBody = mergedBodies([]Body{})
 
 // EmptyBody returns a body with no content. This body can be used as a
@@ -94,10 +280,14 @@ func (mb mergedBodies) PartialContent(schema *BodySchema) (*BodyContent, Body, D
 }
 
 func (mb mergedBodies) JustAttributes() (Attributes, Diagnostics) {
+	return mergedAttributes(mb, MergeOptions{})
+}
+
+func mergedAttributes(bodies []Body, opts MergeOptions) (Attributes, Diagnostics) {
 	attrs := make(map[string]*Attribute)
 	var diags Diagnostics
 
-	for _, body := range mb {
+	for _, body := range bodies {
 		thisAttrs, thisDiags := body.JustAttributes()
 
 		if len(thisDiags) != 0 {
@@ -106,20 +296,11 @@ func (mb mergedBodies) JustAttributes() (Attributes, Diagnostics) {
 
 		if thisAttrs != nil {
 			for name, attr := range thisAttrs {
-				if existing := attrs[name]; existing != nil {
-					diags = diags.Append(&Diagnostic{
-						Severity: DiagError,
-						Summary:  "Duplicate argument",
-						Detail: fmt.Sprintf(
-							"Argument %q was already set at %s",
-							name, existing.NameRange.String(),
-						),
-						Subject: &attr.NameRange,
-					})
-					continue
+				attr, attrDiags := mergeAttribute(attrs[name], attr, opts)
+				diags = append(diags, attrDiags...)
+				if attr != nil {
+					attrs[name] = attr
 				}
-
-				attrs[name] = attr
 			}
 		}
 	}
@@ -140,6 +321,10 @@ func (mb mergedBodies) MissingItemRange() Range {
 }
 
 func (mb mergedBodies) mergedContent(schema *BodySchema, partial bool) (*BodyContent, Body, Diagnostics) {
+	return mergedContent(mb, schema, partial, MergeOptions{})
+}
+
+func mergedContent(bodies []Body, schema *BodySchema, partial bool, opts MergeOptions) (*BodyContent, Body, Diagnostics) {
 	// We need to produce a new schema with none of the attributes marked as
 	// required, since _any one_ of our bodies can contribute an attribute value.
 	// We'll separately check that all required attributes are present at
@@ -159,7 +344,9 @@ func (mb mergedBodies) mergedContent(schema *BodySchema, partial bool) (*BodyCon
 	}
 
 	var diags Diagnostics
-	for _, body := range mb {
+	blockIdx := map[string][]int{} // indices of blocks by blockKey
+	typeBody := map[string]int{}   // body providing blocks of each type, for MergeBlocksReplaceType
+	for bodyIdx, body := range bodies {
 		var thisContent *BodyContent
 		var thisLeftovers Body
 		var thisDiags Diagnostics
@@ -179,25 +366,86 @@ func (mb mergedBodies) mergedContent(schema *BodySchema, partial bool) (*BodyCon
 
 		if thisContent.Attributes != nil {
 			for name, attr := range thisContent.Attributes {
-				if existing := content.Attributes[name]; existing != nil {
-					diags = diags.Append(&Diagnostic{
+				attr, attrDiags := mergeAttribute(content.Attributes[name], attr, opts)
+				diags = append(diags, attrDiags...)
+				if attr != nil {
+					content.Attributes[name] = attr
+				}
+			}
+		}
+
+		for _, block := range thisContent.Blocks {
+			switch mode := opts.blockMode(block.Type); mode {
+			case MergeBlocksAppend:
+				content.Blocks = append(content.Blocks, block)
+
+			case MergeBlocksReplaceType:
+				if prevIdx, exists := typeBody[block.Type]; exists && prevIdx != bodyIdx {
+					// Replaced blocks are set to nil and removed below, so
+					// that the indices in blockIdx remain valid meanwhile.
+					for i, existing := range content.Blocks {
+						if existing != nil && existing.Type == block.Type {
+							content.Blocks[i] = nil
+						}
+					}
+				}
+				typeBody[block.Type] = bodyIdx
+				content.Blocks = append(content.Blocks, block)
+
+			case MergeBlocksOverride:
+				key := blockKey(block)
+				if bodyIdx == 0 {
+					blockIdx[key] = append(blockIdx[key], len(content.Blocks))
+					content.Blocks = append(content.Blocks, block)
+					continue
+				}
+				if len(blockIdx[key]) == 0 {
+					diags = append(diags, &Diagnostic{
 						Severity: DiagError,
-						Summary:  "Duplicate argument",
-						Detail: fmt.Sprintf(
-							"Argument %q was already set at %s",
-							name, existing.NameRange.String(),
-						),
-						Subject: &attr.NameRange,
+						Summary:  "Missing base block",
+						Detail:   fmt.Sprintf("There is no %s block with the same labels in the base configuration for this block to override.", block.Type),
+						Subject:  block.DefRange.Ptr(),
 					})
 					continue
 				}
-				content.Attributes[name] = attr
+				for _, idx := range blockIdx[key] {
+					existing := content.Blocks[idx]
+					merged := *existing
+					merged.Body = MergeBodiesWithOptions([]Body{existing.Body, block.Body}, opts.nested())
+					content.Blocks[idx] = &merged
+				}
+
+			default:
+				key := blockKey(block)
+				idxs, exists := blockIdx[key]
+				if !exists {
+					blockIdx[key] = []int{len(content.Blocks)}
+					content.Blocks = append(content.Blocks, block)
+					continue
+				}
+
+				idx := idxs[0]
+				if mode == MergeBlocksDeep {
+					// The merged block takes its position and ranges from the
+					// first definition, with its body combining all of them.
+					existing := content.Blocks[idx]
+					merged := *existing
+					merged.Body = MergeBodiesWithOptions([]Body{existing.Body, block.Body}, opts.nested())
+					block = &merged
+				}
+				content.Blocks[idx] = block
 			}
 		}
+	}
 
-		if len(thisContent.Blocks) != 0 {
-			content.Blocks = append(content.Blocks, thisContent.Blocks...)
+	if len(typeBody) != 0 {
+		blocks := content.Blocks[:0]
+		for _, block := range content.Blocks {
+			if block != nil {
+				blocks = append(blocks, block)
+			}
 		}
+		content.Blocks = blocks
 	}
 
 	// Finally, we check for required attributes.
@@ -221,6 +469,43 @@ func (mb mergedBodies) mergedContent(schema *BodySchema, partial bool) (*BodyCon
 		}
 	}
 
-	leftoverBody := MergeBodies(mergedLeftovers)
+	leftoverBody := MergeBodiesWithOptions(mergedLeftovers, opts)
 	return content, leftoverBody, diags
 }
+
+// mergeAttribute combines an attribute with an existing attribute of the same
+// name from an earlier body, which may be nil, according to the given
+// options. The result is nil if the existing attribute should be retained.
+func mergeAttribute(existing, attr *Attribute, opts MergeOptions) (*Attribute, Diagnostics) {
+	if existing == nil {
+		return attr, nil
+	}
+
+	if opts.Attributes != MergeAttributesOverride {
+		return nil, Diagnostics{
+			&Diagnostic{
+				Severity: DiagError,
+				Summary:  "Duplicate argument",
+				Detail: fmt.Sprintf(
+					"Argument %q was already set at %s",
+					attr.Name, existing.NameRange.String(),
+				),
+				Subject: &attr.NameRange,
+			},
+		}
+	}
+
+	overridden := append(OverriddenAttributes(existing), unwrapOverride(existing))
+	ret := *attr
+	ret.Expr = &overrideExpr{
+		Expression: attr.Expr,
+		overridden: overridden,
+	}
+	return &ret, nil
+}
+
+// blockKey returns a string that identifies blocks of the same type and
+// labels.
+func blockKey(block *Block) string {
+	return fmt.Sprintf("%q %q", block.Type, block.Labels)
+}
@@ -681,3 +681,136 @@ func (v *testMergedBodiesVictim) MissingItemRange() Range {
 		Filename: v.Name,
 	}
 }
+
+func TestMergeBodiesWithOptions(t *testing.T) {
+	bodies := []Body{
+		&testMergedBodiesVictim{
+			Name:          "base",
+			HasAttributes: []string{"name", "size"},
+			HasBlocks:     map[string]int{"item": 1, "rule": 1},
+		},
+		&testMergedBodiesVictim{
+			Name:          "env",
+			HasAttributes: []string{"name"},
+			HasBlocks:     map[string]int{"item": 1, "rule": 2},
+		},
+		&testMergedBodiesVictim{
+			Name:          "local",
+			HasAttributes: []string{"name"},
+		},
+	}
+	schema := &BodySchema{
+		Attributes: []AttributeSchema{
+			{Name: "name"},
+			{Name: "size"},
+		},
+		Blocks: []BlockHeaderSchema{
+			{Type: "item"},
+			{Type: "rule"},
+		},
+	}
+
+	merged := MergeBodiesWithOptions(bodies, MergeOptions{
+		Attributes: MergeAttributesOverride,
+		Blocks:     MergeBlocksReplace,
+		BlockTypes: map[string]BlockMergeMode{
+			"rule": MergeBlocksAppend,
+		},
+	})
+	content, diags := merged.Content(schema)
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %s", diags.Error())
+	}
+
+	name := content.Attributes["name"]
+	if got, want := name.NameRange.Filename, "local"; got != want {
+		t.Errorf("name is from %q; want %q", got, want)
+	}
+	var overridden []string
+	for _, attr := range OverriddenAttributes(name) {
+		overridden = append(overridden, attr.NameRange.Filename)
+	}
+	if want := []string{"base", "env"}; !reflect.DeepEqual(overridden, want) {
+		t.Errorf("wrong overridden attributes %#v; want %#v", overridden, want)
+	}
+	if got := OverriddenAttributes(content.Attributes["size"]); got != nil {
+		t.Errorf("size should not override anything, but got %#v", got)
+	}
+
+	var blocks []string
+	for _, block := range content.Blocks {
+		blocks = append(blocks, block.Type+" from "+block.DefRange.Filename)
+	}
+	want := []string{
+		"item from env",
+		"rule from base",
+		"rule from env",
+		"rule from env",
+	}
+	if !reflect.DeepEqual(blocks, want) {
+		t.Errorf("wrong blocks\ngot:  %#v\nwant: %#v", blocks, want)
+	}
+
+	attrs, diags := merged.JustAttributes()
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics from JustAttributes: %s", diags.Error())
+	}
+	if got, want := attrs["name"].NameRange.Filename, "local"; got != want {
+		t.Errorf("JustAttributes name is from %q; want %q", got, want)
+	}
+}
+
+func TestMergeBodiesWithOptionsOverride(t *testing.T) {
+	bodies := []Body{
+		&testMergedBodiesVictim{
+			Name:      "base",
+			HasBlocks: map[string]int{"item": 2, "rule": 1},
+		},
+		&testMergedBodiesVictim{
+			Name:      "env",
+			HasBlocks: map[string]int{"item": 1, "rule": 2},
+		},
+		&testMergedBodiesVictim{
+			Name:      "local",
+			HasBlocks: map[string]int{"rule": 1, "other": 1},
+		},
+	}
+	schema := &BodySchema{
+		Blocks: []BlockHeaderSchema{
+			{Type: "item"},
+			{Type: "rule"},
+			{Type: "other"},
+		},
+	}
+
+	merged := MergeBodiesWithOptions(bodies, MergeOptions{
+		Blocks: MergeBlocksOverride,
+		BlockTypes: map[string]BlockMergeMode{
+			"rule": MergeBlocksReplaceType,
+		},
+	})
+	content, diags := merged.Content(schema)
+	if len(diags) != 1 {
+		t.Fatalf("got %d diagnostics; want 1: %s", len(diags), diags.Error())
+	}
+	if got, want := diags[0].Summary, "Missing base block"; got != want {
+		t.Errorf("wrong summary %q; want %q", got, want)
+	}
+	if got, want := diags[0].Subject.Filename, "local"; got != want {
+		t.Errorf("wrong subject filename %q; want %q", got, want)
+	}
+
+	var blocks []string
+	for _, block := range content.Blocks {
+		_, isMerged := block.Body.(*optionsMergedBodies)
+		blocks = append(blocks, fmt.Sprintf("%s from %s (merged: %v)", block.Type, block.DefRange.Filename, isMerged))
+	}
+	want := []string{
+		"item from base (merged: true)",
+		"item from base (merged: true)",
+		"rule from local (merged: false)",
+	}
+	if !reflect.DeepEqual(blocks, want) {
+		t.Errorf("wrong blocks\ngot:  %#v\nwant: %#v", blocks, want)
+	}
+}
@@ -0,0 +1,99 @@
+package hcl
+
+import (
+	"github.com/zclconf/go-cty/cty"
+)
+
+// SensitiveFunc is the signature of EvalContext.Sensitive, which decides
+// whether the value resulting from a given absolute traversal is sensitive.
+type SensitiveFunc func(traversal Traversal) bool
+
+// SensitiveNames returns a SensitiveFunc that treats the values of the
+// variables with the given names, and everything within them, as sensitive.
+func SensitiveNames(names ...string) SensitiveFunc {
+	set := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
+	return func(traversal Traversal) bool {
+		_, sensitive := set[traversal.RootName()]
+		return sensitive
+	}
+}
+
+// IsSensitive returns true if the value resulting from the given absolute
+// traversal is sensitive, as decided by the Sensitive function of whichever
+// context in the receiver's ancestry defines the traversal's root variable.
+//
+// It is safe to call IsSensitive on a nil context, which has no sensitive
+// values.
+func (ctx *EvalContext) IsSensitive(traversal Traversal) bool {
+	if traversal.IsRelative() {
+		return false
+	}
+
+	for thisCtx := ctx; thisCtx != nil; thisCtx = thisCtx.parent {
+		if !thisCtx.hasVariables() {
+			continue
+		}
+		_, exists, diags := thisCtx.lookupVariable(traversal)
+		if diags.HasErrors() || !exists {
+			continue
+		}
+		return thisCtx.Sensitive != nil && thisCtx.Sensitive(traversal)
+	}
+	return false
+}
+
+// IsSensitiveExpr returns true if the given expression refers to any
+// sensitive values in the receiver, in which case its result is derived
+// from sensitive values and must itself be treated as sensitive.
+//
+// It is safe to call IsSensitiveExpr on a nil context, which has no
+// sensitive values.
+func (ctx *EvalContext) IsSensitiveExpr(expr Expression) bool {
+	if ctx == nil {
+		return false
+	}
+	for _, traversal := range expr.Variables() {
+		if ctx.IsSensitive(traversal) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsSensitive returns true if the given value, which is the result of
+// the given absolute traversal, is itself sensitive or contains any nested
+// values that are sensitive.
+func (ctx *EvalContext) containsSensitive(traversal Traversal, val cty.Value) bool {
+	if ctx == nil || traversal.IsRelative() {
+		return false
+	}
+	if ctx.IsSensitive(traversal) {
+		return true
+	}
+
+	found := false
+	cty.Walk(val, func(path cty.Path, v cty.Value) (bool, error) {
+		if found {
+			return false, nil
+		}
+		if len(path) == 0 {
+			return true, nil // the value itself, which we already checked
+		}
+		nested := make(Traversal, len(traversal), len(traversal)+len(path))
+		copy(nested, traversal)
+		for _, step := range path {
+			switch tStep := step.(type) {
+			case cty.GetAttrStep:
+				nested = append(nested, TraverseAttr{Name: tStep.Name})
+			case cty.IndexStep:
+				nested = append(nested, TraverseIndex{Key: tStep.Key})
+			}
+		}
+		found = ctx.IsSensitive(nested)
+		return !found, nil
+	})
+	return found
+}
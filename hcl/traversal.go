@@ -71,14 +71,18 @@ func (t Traversal) TraverseAbs(ctx *EvalContext) (cty.Value, Diagnostics) {
 	thisCtx := ctx
 	hasNonNil := false
 	for thisCtx != nil {
-		if thisCtx.Variables == nil {
+		if !thisCtx.hasVariables() {
 			thisCtx = thisCtx.parent
 			continue
 		}
 		hasNonNil = true
-		val, exists := thisCtx.Variables[name]
+		val, exists, diags := thisCtx.lookupVariable(t)
+		if diags.HasErrors() {
+			return cty.DynamicVal, diags
+		}
 		if exists {
-			return split.Rel.TraverseRel(val)
+			val, relDiags := split.Rel.TraverseRel(val)
+			return val, append(diags, relDiags...)
 		}
 		thisCtx = thisCtx.parent
 	}
@@ -97,9 +101,7 @@ func (t Traversal) TraverseAbs(ctx *EvalContext) (cty.Value, Diagnostics) {
 	suggestions := make([]string, 0, len(ctx.Variables))
 	thisCtx = ctx
 	for thisCtx != nil {
-		for k := range thisCtx.Variables {
-			suggestions = append(suggestions, k)
-		}
+		suggestions = append(suggestions, thisCtx.variableNames()...)
 		thisCtx = thisCtx.parent
 	}
 	suggestion := nameSuggestion(name, suggestions)
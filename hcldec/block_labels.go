@@ -4,15 +4,17 @@ import (
 	"github.com/hashicorp/hcl2/hcl"
 )
 
-type blockLabel struct {
+// BlockLabel is a label of the block whose body is being decoded, as given
+// to the methods of a CustomSpecImpl.
+type BlockLabel struct {
 	Value string
 	Range hcl.Range
 }
 
-func labelsForBlock(block *hcl.Block) []blockLabel {
-	ret := make([]blockLabel, len(block.Labels))
+func labelsForBlock(block *hcl.Block) []BlockLabel {
+	ret := make([]BlockLabel, len(block.Labels))
 	for i := range block.Labels {
-		ret[i] = blockLabel{
+		ret[i] = BlockLabel{
 			Value: block.Labels[i],
 			Range: block.LabelRanges[i],
 		}
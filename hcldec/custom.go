@@ -0,0 +1,131 @@
+package hcldec
+
+import (
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// CustomSpecImpl is the interface that must be implemented by spec types
+// defined outside of this package. Such implementations can be included in
+// a spec tree by wrapping them in a CustomSpec.
+//
+// An implementation that requires attributes or blocks from the body must
+// also implement CustomSpecAttributes or CustomSpecBlocks respectively, and
+// an implementation that evaluates expressions should also implement
+// CustomSpecVariables.
+type CustomSpecImpl interface {
+	// Decode produces a value from the given body content, in the context
+	// of the given block labels (which may be empty), using the given eval
+	// context.
+	//
+	// Implementations that wrap other specs can decode them from the same
+	// content using DecodeContent.
+	Decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics)
+
+	// ImpliedType returns the type of the value that Decode will produce.
+	ImpliedType() cty.Type
+
+	// SourceRange returns the source range of the value that Decode would
+	// produce from the given content. If the corresponding item is missing,
+	// it should return a place where it might be inserted.
+	SourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range
+
+	// SameBodyChildren returns any nested specs that are decoded from the
+	// same body content as the receiver. It should not include specs used
+	// to decode the bodies of nested blocks.
+	SameBodyChildren() []Spec
+}
+
+// CustomSpecAttributes is an optional interface for a CustomSpecImpl that
+// requires attributes from the body.
+type CustomSpecAttributes interface {
+	AttributeSchemata() []hcl.AttributeSchema
+}
+
+// CustomSpecBlocks is an optional interface for a CustomSpecImpl that
+// requires blocks from the body.
+type CustomSpecBlocks interface {
+	BlockHeaderSchemata() []hcl.BlockHeaderSchema
+
+	// NestedSpec returns the spec used to decode the bodies of the blocks,
+	// or nil if the bodies are not decoded using a spec.
+	NestedSpec() Spec
+}
+
+// CustomSpecVariables is an optional interface for a CustomSpecImpl that
+// evaluates expressions from the body, to declare which variables those
+// expressions require.
+type CustomSpecVariables interface {
+	VariablesNeeded(content *hcl.BodyContent) []hcl.Traversal
+}
+
+// A CustomSpec is a Spec that delegates to an implementation defined outside
+// of this package.
+type CustomSpec struct {
+	Impl CustomSpecImpl
+}
+
+func (s *CustomSpec) visitSameBodyChildren(cb visitFunc) {
+	for _, child := range s.Impl.SameBodyChildren() {
+		cb(child)
+	}
+}
+
+func (s *CustomSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	return s.Impl.Decode(content, blockLabels, ctx)
+}
+
+func (s *CustomSpec) impliedType() cty.Type {
+	return s.Impl.ImpliedType()
+}
+
+func (s *CustomSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
+	return s.Impl.SourceRange(content, blockLabels)
+}
+
+func (s *CustomSpec) attrSchemata() []hcl.AttributeSchema {
+	if as, ok := s.Impl.(CustomSpecAttributes); ok {
+		return as.AttributeSchemata()
+	}
+	return nil
+}
+
+func (s *CustomSpec) blockHeaderSchemata() []hcl.BlockHeaderSchema {
+	if bs, ok := s.Impl.(CustomSpecBlocks); ok {
+		return bs.BlockHeaderSchemata()
+	}
+	return nil
+}
+
+func (s *CustomSpec) nestedSpec() Spec {
+	if bs, ok := s.Impl.(CustomSpecBlocks); ok {
+		return bs.NestedSpec()
+	}
+	return nil
+}
+
+func (s *CustomSpec) variablesNeeded(content *hcl.BodyContent) []hcl.Traversal {
+	if vs, ok := s.Impl.(CustomSpecVariables); ok {
+		return vs.VariablesNeeded(content)
+	}
+	return nil
+}
+
+// DecodeContent decodes the given body content, which must have been
+// obtained using a schema that includes ImpliedSchema(spec), using the given
+// spec.
+//
+// This is intended for use by CustomSpecImpl implementations that wrap other
+// specs, and most callers should use Decode instead.
+func DecodeContent(spec Spec, content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	return spec.decode(content, blockLabels, ctx)
+}
+
+// ContentSourceRange is like SourceRange but works with body content that
+// was already obtained using a schema that includes ImpliedSchema(spec).
+//
+// This is intended for use by CustomSpecImpl implementations that wrap other
+// specs, and most callers should use SourceRange instead.
+func ContentSourceRange(spec Spec, content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
+	return spec.sourceRange(content, blockLabels)
+}
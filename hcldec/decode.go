@@ -5,7 +5,7 @@ import (
 	"github.com/zclconf/go-cty/cty"
 )
 
-func decode(body hcl.Body, blockLabels []blockLabel, ctx *hcl.EvalContext, spec Spec, partial bool) (cty.Value, hcl.Body, hcl.Diagnostics) {
+func decode(body hcl.Body, blockLabels []BlockLabel, ctx *hcl.EvalContext, spec Spec, partial bool) (cty.Value, hcl.Body, hcl.Diagnostics) {
 	schema := ImpliedSchema(spec)
 
 	var content *hcl.BodyContent
@@ -28,7 +28,7 @@ func impliedType(spec Spec) cty.Type {
 	return spec.impliedType()
 }
 
-func sourceRange(body hcl.Body, blockLabels []blockLabel, spec Spec) hcl.Range {
+func sourceRange(body hcl.Body, blockLabels []BlockLabel, spec Spec) hcl.Range {
 	schema := ImpliedSchema(spec)
 	content, _, _ := body.PartialContent(schema)
 
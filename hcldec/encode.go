@@ -0,0 +1,358 @@
+package hcldec
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Encode is the inverse of Decode, writing into the given body the attributes
+// and blocks that would decode to the given value using the given spec.
+//
+// The value must conform to the implied type of the spec. Object attributes
+// become attributes or blocks according to the corresponding specs: the
+// elements of a BlockListSpec or BlockSetSpec become repeated blocks, the
+// elements of a BlockMapSpec become blocks labelled with their map keys, and
+// the values of any BlockLabelSpec within a block's nested spec become
+// labels of that block.
+//
+// Specs whose result cannot be mapped back to the body, such as LiteralSpec,
+// ExprSpec and the transform specs, are skipped, as are CustomSpecs whose
+// implementations do not implement CustomSpecEncoder. A DefaultSpec is
+// encoded using its Primary spec, and a DeprecatedSpec using the new name of
+// its wrapped spec, removing any attributes or blocks that use its OldName.
+//
+// The body is updated in place, so that it may be one that was parsed from
+// an existing configuration file, or one that the same value was already
+// encoded into:
+//
+//   - An attribute that is already present has its expression replaced.
+//   - Blocks that are already present are updated in place, recursively, when
+//     they have the same labels as the corresponding value. The blocks of a
+//     BlockMapSpec are matched by their labels, and all others by position.
+//     Existing blocks with no corresponding value are removed.
+//   - A null value removes the corresponding attributes and blocks, since
+//     their absence would decode to null.
+//   - An unknown value, which has no literal representation, leaves the
+//     corresponding attributes and blocks untouched.
+//   - New attributes and blocks are appended to the body, with attributes
+//     written before blocks.
+//
+// Attributes and blocks that do not correspond to any spec are left
+// untouched.
+func Encode(val cty.Value, spec Spec, dst *hclwrite.Body) {
+	encode(val, spec, dst, nil)
+}
+
+// CustomSpecEncoder is an optional interface for a CustomSpecImpl that can
+// write the content that would decode to a given value, allowing Encode to
+// be used with specs that include it.
+//
+// Implementations that wrap other specs can encode the corresponding parts
+// of the value into the same body using Encode.
+type CustomSpecEncoder interface {
+	// Encode writes into the given body the content that would decode to
+	// the given value, which conforms to the receiver's implied type and is
+	// never unknown, but may be null. It should update existing content in
+	// place, in the same way as the Encode function.
+	Encode(val cty.Value, dst *hclwrite.Body)
+}
+
+// encode writes the given value into the given body according to the given
+// spec, and records the values of any BlockLabelSpec into labels.
+//
+// If body is nil then only the labels are recorded, which we use to find
+// the labels of a block before creating it.
+func encode(val cty.Value, spec Spec, body *hclwrite.Body, labels []string) {
+	if !val.IsKnown() {
+		return
+	}
+
+	switch s := spec.(type) {
+
+	case ObjectSpec:
+		for _, k := range encodeOrder(s) {
+			switch {
+			case val.IsNull():
+				encode(cty.NullVal(cty.DynamicPseudoType), s[k], body, labels)
+			case val.Type().IsObjectType() && val.Type().HasAttribute(k):
+				encode(val.GetAttr(k), s[k], body, labels)
+			}
+		}
+
+	case *OneOfSpec:
+		encode(val, s.Specs, body, labels)
+
+	case TupleSpec:
+		for i, elemSpec := range s {
+			switch {
+			case val.IsNull():
+				encode(cty.NullVal(cty.DynamicPseudoType), elemSpec, body, labels)
+			case val.Type().IsTupleType() && i < val.LengthInt():
+				encode(val.Index(cty.NumberIntVal(int64(i))), elemSpec, body, labels)
+			}
+		}
+
+	case *AttrSpec:
+		switch {
+		case body == nil:
+		case val.IsNull():
+			body.RemoveAttribute(s.Name)
+		case val.IsWhollyKnown():
+			body.SetAttributeValue(s.Name, val)
+		}
+
+	case *BlockLabelSpec:
+		if !val.IsNull() && s.Index < len(labels) && val.Type() == cty.String {
+			labels[s.Index] = val.AsString()
+		}
+
+	case *BlockSpec:
+		if body != nil {
+			var vals []encodeBlockValue
+			if !val.IsNull() {
+				vals = append(vals, encodeBlockValue{val: val})
+			}
+			updateBlocks(body, s.TypeName, vals, 0, s.Nested)
+		}
+
+	case *BlockListSpec:
+		if body != nil {
+			updateBlocks(body, s.TypeName, encodeBlockValues(val), 0, s.Nested)
+		}
+
+	case *BlockSetSpec:
+		if body != nil {
+			updateBlocks(body, s.TypeName, encodeBlockValues(val), 0, s.Nested)
+		}
+
+	case *BlockMapSpec:
+		if body != nil {
+			depth := len(s.LabelNames)
+			updateBlocks(body, s.TypeName, encodeBlockMapValues(val, depth, nil), depth, s.Nested)
+		}
+
+	case *BlockAttrsSpec:
+		if body != nil {
+			updateBlockAttrs(body, s.TypeName, val)
+		}
+
+	case *DefaultSpec:
+		encode(val, s.Primary, body, labels)
+
+	case *ValidateSpec:
+		encode(val, s.Wrapped, body, labels)
+
+	case *DeprecatedSpec:
+		if body != nil && s.OldName != "" {
+			// The value is always written using the new name, so anything
+			// using the old name would conflict with it.
+			if _, isBlock := s.newName(); isBlock {
+				for _, block := range body.Blocks() {
+					if block.Type() == s.OldName {
+						body.RemoveBlock(block)
+					}
+				}
+			} else {
+				body.RemoveAttribute(s.OldName)
+			}
+		}
+		encode(val, s.Wrapped, body, labels)
+
+	case *CustomSpec:
+		if enc, ok := s.Impl.(CustomSpecEncoder); ok && body != nil {
+			enc.Encode(val, body)
+		}
+
+	default:
+		// All other specs produce values that don't correspond directly to
+		// body content, so we can't encode them.
+	}
+}
+
+// encodeBlockValue is a value to be written as a block, along with any keys
+// from the block map it belongs to.
+type encodeBlockValue struct {
+	val  cty.Value
+	keys []string
+}
+
+// encodeBlockValues returns the elements of the given collection that are
+// to be written as blocks.
+func encodeBlockValues(val cty.Value) []encodeBlockValue {
+	if val.IsNull() || !val.CanIterateElements() {
+		return nil
+	}
+	var ret []encodeBlockValue
+	for it := val.ElementIterator(); it.Next(); {
+		_, elem := it.Element()
+		if elem.IsNull() || !elem.IsKnown() {
+			continue
+		}
+		ret = append(ret, encodeBlockValue{val: elem})
+	}
+	return ret
+}
+
+// encodeBlockMapValues returns the leaf elements of the given map, which has
+// the given number of levels of nesting, in lexical order of their keys.
+func encodeBlockMapValues(val cty.Value, depth int, keys []string) []encodeBlockValue {
+	if val.IsNull() || !val.IsKnown() {
+		return nil
+	}
+	if depth == 0 {
+		return []encodeBlockValue{{val: val, keys: keys}}
+	}
+	if !val.CanIterateElements() {
+		return nil
+	}
+
+	// The keys of a cty map are already iterated in lexical order, but
+	// an object representing a map may not be, so we'll sort to be sure.
+	var ks []string
+	elems := make(map[string]cty.Value)
+	for it := val.ElementIterator(); it.Next(); {
+		k, v := it.Element()
+		if k.Type() != cty.String || !k.IsKnown() {
+			continue
+		}
+		ks = append(ks, k.AsString())
+		elems[k.AsString()] = v
+	}
+	sort.Strings(ks)
+
+	var ret []encodeBlockValue
+	for _, k := range ks {
+		childKeys := make([]string, len(keys), len(keys)+1)
+		copy(childKeys, keys)
+		childKeys = append(childKeys, k)
+		ret = append(ret, encodeBlockMapValues(elems[k], depth-1, childKeys)...)
+	}
+	return ret
+}
+
+// updateBlocks makes the blocks of the given type in the given body
+// correspond to the given values, updating existing blocks in place where
+// possible.
+//
+// If mapDepth is zero then existing blocks are matched with values by
+// position. Otherwise, they are matched by their first mapDepth labels.
+func updateBlocks(body *hclwrite.Body, typeName string, vals []encodeBlockValue, mapDepth int, nested Spec) {
+	var existing []*hclwrite.Block
+	byKey := map[string]*hclwrite.Block{}
+	for _, block := range body.Blocks() {
+		if block.Type() != typeName {
+			continue
+		}
+		existing = append(existing, block)
+		if labels := block.Labels(); mapDepth > 0 && len(labels) >= mapDepth {
+			key := strings.Join(labels[:mapDepth], "\x00")
+			if _, exists := byKey[key]; !exists {
+				byKey[key] = block
+			}
+		}
+	}
+
+	used := map[*hclwrite.Block]bool{}
+	for i, bv := range vals {
+		// The first labels are the map keys, and the remainder come from
+		// any BlockLabelSpec in the nested spec.
+		labels := make([]string, len(findLabelSpecs(nested)))
+		encode(bv.val, nested, nil, labels)
+		labels = append(append([]string(nil), bv.keys...), labels...)
+
+		var match *hclwrite.Block
+		if mapDepth > 0 {
+			match = byKey[strings.Join(bv.keys, "\x00")]
+		} else if i < len(existing) {
+			match = existing[i]
+		}
+		if match != nil && !used[match] && stringsEqual(match.Labels(), labels) {
+			used[match] = true
+			encode(bv.val, nested, match.Body(), nil)
+			continue
+		}
+
+		block := body.AppendNewBlock(typeName, labels)
+		encode(bv.val, nested, block.Body(), nil)
+	}
+
+	for _, block := range existing {
+		if !used[block] {
+			body.RemoveBlock(block)
+		}
+	}
+}
+
+// updateBlockAttrs makes the single block of the given type in the given
+// body have an attribute for each element of the given map, updating any
+// existing block in place.
+func updateBlockAttrs(body *hclwrite.Body, typeName string, val cty.Value) {
+	var block *hclwrite.Block
+	for _, existing := range body.Blocks() {
+		if existing.Type() != typeName {
+			continue
+		}
+		if block != nil || len(existing.Labels()) != 0 || val.IsNull() {
+			body.RemoveBlock(existing)
+			continue
+		}
+		block = existing
+	}
+	if val.IsNull() || !val.CanIterateElements() {
+		return
+	}
+	if block == nil {
+		block = body.AppendNewBlock(typeName, nil)
+	}
+
+	attrs := map[string]bool{}
+	for it := val.ElementIterator(); it.Next(); {
+		k, v := it.Element()
+		if k.Type() != cty.String || !k.IsKnown() {
+			continue
+		}
+		if v.IsNull() {
+			continue
+		}
+		attrs[k.AsString()] = true
+		if v.IsWhollyKnown() {
+			block.Body().SetAttributeValue(k.AsString(), v)
+		}
+	}
+	for name := range block.Body().Attributes() {
+		if !attrs[name] {
+			block.Body().RemoveAttribute(name)
+		}
+	}
+}
+
+// encodeOrder returns the keys of the given object spec in the order their
+// values should be written: attributes before blocks, each in lexical order.
+func encodeOrder(spec ObjectSpec) []string {
+	var attrs, blocks []string
+	for k, child := range spec {
+		if len(ImpliedSchema(child).Blocks) > 0 {
+			blocks = append(blocks, k)
+		} else {
+			attrs = append(attrs, k)
+		}
+	}
+	sort.Strings(attrs)
+	sort.Strings(blocks)
+	return append(attrs, blocks...)
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,354 @@
+package hcldec
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/hashicorp/hcl2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestEncode(t *testing.T) {
+	spec := ObjectSpec{
+		"name": &AttrSpec{
+			Name: "name",
+			Type: cty.String,
+		},
+		"size": &DefaultSpec{
+			Primary: &AttrSpec{
+				Name: "size",
+				Type: cty.Number,
+			},
+			Default: &LiteralSpec{
+				Value: cty.NumberIntVal(1),
+			},
+		},
+		"network": &BlockSpec{
+			TypeName: "network",
+			Nested: ObjectSpec{
+				"cidr": &AttrSpec{
+					Name: "cidr",
+					Type: cty.String,
+				},
+			},
+		},
+		"disks": &BlockListSpec{
+			TypeName: "disk",
+			Nested: ObjectSpec{
+				"name": &BlockLabelSpec{
+					Index: 0,
+					Name:  "name",
+				},
+				"size": &AttrSpec{
+					Name: "size",
+					Type: cty.Number,
+				},
+			},
+		},
+		"rules": &BlockMapSpec{
+			TypeName:   "rule",
+			LabelNames: []string{"direction", "name"},
+			Nested: ObjectSpec{
+				"port": &AttrSpec{
+					Name: "port",
+					Type: cty.Number,
+				},
+			},
+		},
+		"tags": &BlockAttrsSpec{
+			TypeName:    "tags",
+			ElementType: cty.String,
+		},
+	}
+
+	val := cty.ObjectVal(map[string]cty.Value{
+		"name": cty.StringVal("web"),
+		"size": cty.NumberIntVal(3),
+		"network": cty.ObjectVal(map[string]cty.Value{
+			"cidr": cty.StringVal("10.0.0.0/8"),
+		}),
+		"disks": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("root"),
+				"size": cty.NumberIntVal(10),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("data"),
+				"size": cty.NullVal(cty.Number),
+			}),
+		}),
+		"rules": cty.MapVal(map[string]cty.Value{
+			"ingress": cty.MapVal(map[string]cty.Value{
+				"https": cty.ObjectVal(map[string]cty.Value{
+					"port": cty.NumberIntVal(443),
+				}),
+				"http": cty.ObjectVal(map[string]cty.Value{
+					"port": cty.NumberIntVal(80),
+				}),
+			}),
+		}),
+		"tags": cty.MapVal(map[string]cty.Value{
+			"env": cty.StringVal("prod"),
+		}),
+	})
+
+	f := hclwrite.NewFile()
+	Encode(val, spec, f.Body())
+	got := string(f.Bytes())
+	want := `name = "web"
+size = 3
+disk "root" {
+  size = 10
+}
+disk "data" {
+}
+network {
+  cidr = "10.0.0.0/8"
+}
+rule "ingress" "http" {
+  port = 80
+}
+rule "ingress" "https" {
+  port = 443
+}
+tags {
+  env = "prod"
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The result should decode back to the original value.
+	file, diags := hclsyntax.ParseConfig(f.Bytes(), "", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+	decoded, diags := Decode(file.Body, spec, nil)
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+	if !decoded.RawEquals(val) {
+		t.Errorf("wrong decoded result\ngot:  %#v\nwant: %#v", decoded, val)
+	}
+}
+
+func TestEncodeUpdate(t *testing.T) {
+	spec := ObjectSpec{
+		"name": &AttrSpec{
+			Name: "name",
+			Type: cty.String,
+		},
+		"size": &AttrSpec{
+			Name: "size",
+			Type: cty.Number,
+		},
+		"disks": &BlockListSpec{
+			TypeName: "disk",
+			Nested: ObjectSpec{
+				"name": &BlockLabelSpec{
+					Index: 0,
+					Name:  "name",
+				},
+				"size": &AttrSpec{
+					Name: "size",
+					Type: cty.Number,
+				},
+			},
+		},
+		"rules": &BlockMapSpec{
+			TypeName:   "rule",
+			LabelNames: []string{"name"},
+			Nested: ObjectSpec{
+				"port": &AttrSpec{
+					Name: "port",
+					Type: cty.Number,
+				},
+			},
+		},
+		"tags": &BlockAttrsSpec{
+			TypeName:    "tags",
+			ElementType: cty.String,
+		},
+		"upper": &CustomSpec{
+			Impl: &testUpperSpec{Name: "upper"},
+		},
+	}
+
+	src := `# The name of the instance.
+name  = "old"
+size  = 1
+other = true
+
+disk "root" {
+  # Keep this comment.
+  size = 5
+  type = "ssd"
+}
+disk "old" {
+  size = 1
+}
+rule "http" {
+  port = 8080
+}
+rule "ssh" {
+  port = 22
+}
+tags {
+  env  = "dev"
+  team = "a"
+}
+`
+	f, diags := hclwrite.ParseConfig([]byte(src), "", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	val := cty.ObjectVal(map[string]cty.Value{
+		"name": cty.StringVal("web"),
+		"size": cty.NullVal(cty.Number),
+		"disks": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("root"),
+				"size": cty.NumberIntVal(10),
+			}),
+		}),
+		"rules": cty.MapVal(map[string]cty.Value{
+			"http": cty.ObjectVal(map[string]cty.Value{
+				"port": cty.NumberIntVal(80),
+			}),
+			"https": cty.ObjectVal(map[string]cty.Value{
+				"port": cty.NumberIntVal(443),
+			}),
+		}),
+		"tags": cty.MapVal(map[string]cty.Value{
+			"env": cty.StringVal("prod"),
+		}),
+		"upper": cty.StringVal("LOUD"),
+	})
+
+	// Encoding twice must give the same result as encoding once.
+	Encode(val, spec, f.Body())
+	Encode(val, spec, f.Body())
+	got := string(f.Bytes())
+	want := `# The name of the instance.
+name  = "web"
+other = true
+
+disk "root" {
+  # Keep this comment.
+  size = 10
+  type = "ssd"
+}
+rule "http" {
+  port = 80
+}
+tags {
+  env = "prod"
+}
+upper = "LOUD"
+rule "https" {
+  port = 443
+}
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEncodeDeprecated(t *testing.T) {
+	spec := ObjectSpec{
+		"name": &DeprecatedSpec{
+			Wrapped: &AttrSpec{
+				Name: "name",
+				Type: cty.String,
+			},
+			OldName: "old_name",
+		},
+		"disk": &DeprecatedSpec{
+			Wrapped: &BlockSpec{
+				TypeName: "disk",
+				Nested: &AttrSpec{
+					Name: "size",
+					Type: cty.Number,
+				},
+			},
+			OldName: "old_disk",
+		},
+	}
+
+	tests := map[string]struct {
+		val  cty.Value
+		want string
+	}{
+		"value": {
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+				"disk": cty.NumberIntVal(10),
+			}),
+			`other = true
+name  = "web"
+disk {
+  size = 10
+}
+`,
+		},
+		"null": {
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.NullVal(cty.String),
+				"disk": cty.NullVal(cty.Number),
+			}),
+			`other = true
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			src := `old_name = "old"
+other    = true
+old_disk {
+  size = 1
+}
+`
+			f, diags := hclwrite.ParseConfig([]byte(src), "", hcl.Pos{Line: 1, Column: 1})
+			if diags.HasErrors() {
+				t.Fatal(diags.Error())
+			}
+
+			Encode(test.val, spec, f.Body())
+			got := string(f.Bytes())
+			if got != test.want {
+				t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, test.want)
+			}
+
+			parsed, diags := hclsyntax.ParseConfig(f.Bytes(), "", hcl.Pos{Line: 1, Column: 1})
+			if diags.HasErrors() {
+				t.Fatal(diags.Error())
+			}
+			decodeSpec := ObjectSpec{
+				"spec": spec,
+				"other": &AttrSpec{
+					Name: "other",
+					Type: cty.Bool,
+				},
+			}
+			decoded, diags := Decode(parsed.Body, decodeSpec, nil)
+			for _, diag := range diags {
+				t.Errorf("unexpected diagnostic: %s", diag.Error())
+			}
+			if got := decoded.GetAttr("spec"); !got.RawEquals(test.val) {
+				t.Errorf("wrong decoded value\ngot:  %#v\nwant: %#v", got, test.val)
+			}
+		})
+	}
+}
+
+func (s *testUpperSpec) Encode(val cty.Value, dst *hclwrite.Body) {
+	if val.IsNull() {
+		dst.RemoveAttribute(s.Name)
+		return
+	}
+	dst.SetAttributeValue(s.Name, val)
+}
@@ -9,6 +9,10 @@ func init() {
 	// specs can be sent over gob channels, such as using
 	// github.com/hashicorp/go-plugin with plugins that need to describe
 	// what shape of configuration they are expecting.
+	//
+	// Specs that contain expressions, evaluation contexts or Go functions,
+	// such as ValidateSpec and TransformExprSpec, can't be encoded and so
+	// are not registered.
 	gob.Register(ObjectSpec(nil))
 	gob.Register(TupleSpec(nil))
 	gob.Register((*AttrSpec)(nil))
@@ -20,4 +24,7 @@ func init() {
 	gob.Register((*BlockMapSpec)(nil))
 	gob.Register((*BlockLabelSpec)(nil))
 	gob.Register((*DefaultSpec)(nil))
+	gob.Register((*DeprecatedSpec)(nil))
+	gob.Register((*OneOfSpec)(nil))
+	gob.Register((*CustomSpec)(nil))
 }
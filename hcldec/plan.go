@@ -0,0 +1,273 @@
+package hcldec
+
+import (
+	"sort"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// UnknownValue describes a part of a decoded value that is unknown, typically
+// because it was derived from a variable whose value is not yet known.
+type UnknownValue struct {
+	// Path is the location of the unknown value within the overall result.
+	Path cty.Path
+
+	// Range is the source range of the expression that produced the value,
+	// or of the configuration construct that the value was derived from if
+	// there is no single expression.
+	Range hcl.Range
+
+	// Traversals are the variable references within that source range whose
+	// values are not wholly known in the EvalContext used for decoding, and
+	// which therefore caused the value to be unknown. This is empty if the
+	// value is unknown for some other reason, such as a function that
+	// returned an unknown result.
+	Traversals []hcl.Traversal
+}
+
+// PlanDecode is like Decode except that it also returns a description of each
+// part of the result that is unknown.
+//
+// This is intended for applications that validate configuration before the
+// values of all of its variables are available, by placing cty.UnknownVal
+// values into the given EvalContext for the variables that are not yet
+// known. The result then conforms to the spec's implied type but has unknown
+// values where the configuration depends on those variables, and the
+// returned UnknownValue objects describe where each of them came from.
+//
+// Unknown values are reported at the deepest path possible, so for example
+// a list whose second element is unknown is reported as an unknown value at
+// index 1 of the list rather than as an unknown list.
+func PlanDecode(body hcl.Body, spec Spec, ctx *hcl.EvalContext) (cty.Value, []UnknownValue, hcl.Diagnostics) {
+	val, _, diags := decode(body, nil, ctx, spec, false)
+
+	content, _, _ := body.PartialContent(ImpliedSchema(spec))
+	unknowns := findUnknowns(val, spec, content, nil, ctx, nil)
+
+	return val, unknowns, diags
+}
+
+// findUnknowns returns the unknown values within the given value, which was
+// produced by decoding the given content with the given spec, with paths
+// relative to the given path.
+func findUnknowns(val cty.Value, spec Spec, content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext, path cty.Path) []UnknownValue {
+	if val.IsWhollyKnown() {
+		return nil
+	}
+
+	// Where possible we recurse into the structure of the spec so that we
+	// can report the specific expression that produced each unknown value.
+	// Anything we can't see into is handled by unknownsForSpec below.
+	switch s := spec.(type) {
+
+	case ObjectSpec:
+		if !val.IsKnown() || !val.Type().IsObjectType() {
+			break
+		}
+		keys := make([]string, 0, len(s))
+		for k := range s {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var ret []UnknownValue
+		for _, k := range keys {
+			if !val.Type().HasAttribute(k) {
+				continue
+			}
+			ret = append(ret, findUnknowns(val.GetAttr(k), s[k], content, blockLabels, ctx, path.GetAttr(k))...)
+		}
+		return ret
+
+	case TupleSpec:
+		if !val.IsKnown() || !val.Type().IsTupleType() {
+			break
+		}
+		var ret []UnknownValue
+		for i, elemSpec := range s {
+			if i >= val.LengthInt() {
+				break
+			}
+			idx := cty.NumberIntVal(int64(i))
+			ret = append(ret, findUnknowns(val.Index(idx), elemSpec, content, blockLabels, ctx, path.Index(idx))...)
+		}
+		return ret
+
+	case *OneOfSpec:
+		return findUnknowns(val, s.Specs, content, blockLabels, ctx, path)
+
+	case *ValidateSpec:
+		return findUnknowns(val, s.Wrapped, content, blockLabels, ctx, path)
+
+	case *DeprecatedSpec:
+		return findUnknowns(val, s.Wrapped, content, blockLabels, ctx, path)
+
+	case *DefaultSpec:
+		// An unknown primary value is never null, so if the primary spec
+		// contributes any unknowns then the default was not used.
+		if ret := findUnknowns(val, s.Primary, content, blockLabels, ctx, path); len(ret) > 0 {
+			return ret
+		}
+		return findUnknowns(val, s.Default, content, blockLabels, ctx, path)
+
+	case *AttrSpec:
+		if attr, exists := content.Attributes[s.Name]; exists {
+			return exprUnknowns(val, attr.Expr, ctx, path)
+		}
+
+	case *BlockSpec:
+		for _, block := range content.Blocks {
+			if block.Type == s.TypeName {
+				return blockUnknowns(val, s.Nested, block, nil, ctx, path)
+			}
+		}
+
+	case *BlockListSpec:
+		if !val.IsKnown() || !val.CanIterateElements() {
+			break
+		}
+		var ret []UnknownValue
+		i := 0
+		for _, block := range content.Blocks {
+			if block.Type != s.TypeName {
+				continue
+			}
+			if i >= val.LengthInt() {
+				break
+			}
+			idx := cty.NumberIntVal(int64(i))
+			ret = append(ret, blockUnknowns(val.Index(idx), s.Nested, block, nil, ctx, path.Index(idx))...)
+			i++
+		}
+		return ret
+
+	case *BlockSetSpec:
+		if !val.IsKnown() || !val.CanIterateElements() {
+			break
+		}
+		// Set elements are identified by their values, so we must decode
+		// each block separately to find which element it produced.
+		var ret []UnknownValue
+		for _, block := range content.Blocks {
+			if block.Type != s.TypeName {
+				continue
+			}
+			elem, _, _ := decode(block.Body, labelsForBlock(block), ctx, s.Nested, false)
+			ret = append(ret, blockUnknowns(elem, s.Nested, block, nil, ctx, path.Index(elem))...)
+		}
+		return ret
+
+	case *BlockMapSpec:
+		if !val.IsKnown() {
+			break
+		}
+		var ret []UnknownValue
+		for _, block := range content.Blocks {
+			if block.Type != s.TypeName || len(block.Labels) < len(s.LabelNames) {
+				continue
+			}
+			var rel cty.Path
+			for _, key := range block.Labels[:len(s.LabelNames)] {
+				rel = rel.Index(cty.StringVal(key))
+			}
+			elem, err := rel.Apply(val)
+			if err != nil {
+				// Probably a duplicate block that was ignored during decoding
+				continue
+			}
+			childLabels := labelsForBlock(block)[len(s.LabelNames):]
+			ret = append(ret, blockUnknowns(elem, s.Nested, block, childLabels, ctx, append(path.Copy(), rel...))...)
+		}
+		return ret
+
+	case *BlockAttrsSpec:
+		if !val.IsKnown() || !val.CanIterateElements() {
+			break
+		}
+		for _, block := range content.Blocks {
+			if block.Type != s.TypeName {
+				continue
+			}
+			attrs, _ := block.Body.JustAttributes()
+			names := make([]string, 0, len(attrs))
+			for name := range attrs {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			var ret []UnknownValue
+			for _, name := range names {
+				key := cty.StringVal(name)
+				if !val.HasIndex(key).True() {
+					continue
+				}
+				ret = append(ret, exprUnknowns(val.Index(key), attrs[name].Expr, ctx, path.Index(key))...)
+			}
+			return ret
+		}
+	}
+
+	return unknownsForSpec(val, spec, content, blockLabels, ctx, path)
+}
+
+// blockUnknowns returns the unknown values within the given value, which was
+// produced by decoding the body of the given block with the given spec.
+//
+// If blockLabels is nil then all of the block's labels are used.
+func blockUnknowns(val cty.Value, spec Spec, block *hcl.Block, blockLabels []BlockLabel, ctx *hcl.EvalContext, path cty.Path) []UnknownValue {
+	if blockLabels == nil {
+		blockLabels = labelsForBlock(block)
+	}
+	content, _, _ := block.Body.PartialContent(ImpliedSchema(spec))
+	return findUnknowns(val, spec, content, blockLabels, ctx, path)
+}
+
+// exprUnknowns returns the unknown values within the given value, which was
+// produced by evaluating the given expression.
+func exprUnknowns(val cty.Value, expr hcl.Expression, ctx *hcl.EvalContext, path cty.Path) []UnknownValue {
+	return unknownLeaves(val, path, expr.Range(), unknownTraversals(expr.Variables(), ctx))
+}
+
+// unknownsForSpec is the fallback for specs whose results can't be traced
+// back to individual expressions, reporting the unknown values within the
+// given value against the source range of the whole spec.
+func unknownsForSpec(val cty.Value, spec Spec, content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext, path cty.Path) []UnknownValue {
+	rng := spec.sourceRange(content, blockLabels)
+	return unknownLeaves(val, path, rng, unknownTraversals(variablesNeeded(spec, content), ctx))
+}
+
+// unknownLeaves returns an UnknownValue with the given range and traversals
+// for each of the deepest unknown values within the given value.
+func unknownLeaves(val cty.Value, path cty.Path, rng hcl.Range, traversals []hcl.Traversal) []UnknownValue {
+	var ret []UnknownValue
+	cty.Walk(val, func(rel cty.Path, v cty.Value) (bool, error) {
+		if v.IsKnown() {
+			return true, nil
+		}
+		ret = append(ret, UnknownValue{
+			Path:       append(path.Copy(), rel...),
+			Range:      rng,
+			Traversals: traversals,
+		})
+		return false, nil
+	})
+	return ret
+}
+
+// unknownTraversals returns those of the given traversals whose values are
+// not wholly known in the given context.
+func unknownTraversals(traversals []hcl.Traversal, ctx *hcl.EvalContext) []hcl.Traversal {
+	if ctx == nil {
+		return nil
+	}
+	var ret []hcl.Traversal
+	for _, traversal := range traversals {
+		v, diags := traversal.TraverseAbs(ctx)
+		if diags.HasErrors() {
+			continue
+		}
+		if !v.IsWhollyKnown() {
+			ret = append(ret, traversal)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,145 @@
+package hcldec
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+func TestPlanDecode(t *testing.T) {
+	spec := ObjectSpec{
+		"name": &AttrSpec{
+			Name: "name",
+			Type: cty.String,
+		},
+		"ports": &AttrSpec{
+			Name: "ports",
+			Type: cty.List(cty.Number),
+		},
+		"size": &DefaultSpec{
+			Primary: &AttrSpec{
+				Name: "size",
+				Type: cty.Number,
+			},
+			Default: &LiteralSpec{
+				Value: cty.NumberIntVal(1),
+			},
+		},
+		"rules": &BlockListSpec{
+			TypeName: "rule",
+			Nested: ObjectSpec{
+				"cidr": &AttrSpec{
+					Name: "cidr",
+					Type: cty.String,
+				},
+			},
+		},
+		"networks": &BlockMapSpec{
+			TypeName:   "network",
+			LabelNames: []string{"name"},
+			Nested: ObjectSpec{
+				"id": &AttrSpec{
+					Name: "id",
+					Type: cty.String,
+				},
+			},
+		},
+		"upper": &TransformFuncSpec{
+			Wrapped: &AttrSpec{
+				Name: "upper",
+				Type: cty.String,
+			},
+			Func: stdlib.UpperFunc,
+		},
+	}
+
+	src := `
+name  = "web-${var.env}"
+ports = [80, var.port]
+upper = var.env
+
+rule {
+  cidr = "10.0.0.0/8"
+}
+rule {
+  cidr = var.cidr
+}
+
+network "a" {
+  id = var.network_ids.a
+}
+`
+	f, diags := hclsyntax.ParseConfig([]byte(src), "test.hcl", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var": cty.ObjectVal(map[string]cty.Value{
+				"env":  cty.UnknownVal(cty.String),
+				"port": cty.UnknownVal(cty.Number),
+				"cidr": cty.UnknownVal(cty.String),
+				"network_ids": cty.ObjectVal(map[string]cty.Value{
+					"a": cty.UnknownVal(cty.String),
+				}),
+			}),
+		},
+	}
+
+	got, unknowns, diags := PlanDecode(f.Body, spec, ctx)
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	want := cty.ObjectVal(map[string]cty.Value{
+		"name":  cty.UnknownVal(cty.String),
+		"ports": cty.ListVal([]cty.Value{cty.NumberIntVal(80), cty.UnknownVal(cty.Number)}),
+		"size":  cty.NumberIntVal(1),
+		"rules": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{"cidr": cty.StringVal("10.0.0.0/8")}),
+			cty.ObjectVal(map[string]cty.Value{"cidr": cty.UnknownVal(cty.String)}),
+		}),
+		"networks": cty.MapVal(map[string]cty.Value{
+			"a": cty.ObjectVal(map[string]cty.Value{"id": cty.UnknownVal(cty.String)}),
+		}),
+		"upper": cty.UnknownVal(cty.String),
+	})
+	if !got.RawEquals(want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	var gotUnknowns []string
+	for _, u := range unknowns {
+		var traversals []string
+		for _, traversal := range u.Traversals {
+			traversals = append(traversals, fmt.Sprintf("%s", traversal.SourceRange()))
+		}
+		gotUnknowns = append(gotUnknowns, fmt.Sprintf("%#v at %s from %s", u.Path, u.Range, traversals))
+	}
+	wantUnknowns := []string{
+		fmt.Sprintf("%#v at test.hcl:2,9-25 from [test.hcl:2,16-23]", cty.Path{cty.GetAttrStep{Name: "name"}}),
+		fmt.Sprintf(
+			"%#v at test.hcl:14,8-25 from [test.hcl:14,8-25]",
+			cty.Path{cty.GetAttrStep{Name: "networks"}, cty.IndexStep{Key: cty.StringVal("a")}, cty.GetAttrStep{Name: "id"}},
+		),
+		fmt.Sprintf(
+			"%#v at test.hcl:3,9-23 from [test.hcl:3,14-22]",
+			cty.Path{cty.GetAttrStep{Name: "ports"}, cty.IndexStep{Key: cty.NumberIntVal(1)}},
+		),
+		fmt.Sprintf(
+			"%#v at test.hcl:10,10-18 from [test.hcl:10,10-18]",
+			cty.Path{cty.GetAttrStep{Name: "rules"}, cty.IndexStep{Key: cty.NumberIntVal(1)}, cty.GetAttrStep{Name: "cidr"}},
+		),
+		fmt.Sprintf("%#v at test.hcl:4,9-16 from [test.hcl:4,9-16]", cty.Path{cty.GetAttrStep{Name: "upper"}}),
+	}
+	if !reflect.DeepEqual(gotUnknowns, wantUnknowns) {
+		t.Errorf("wrong unknowns\ngot:\n%s\nwant:\n%s", strings.Join(gotUnknowns, "\n"), strings.Join(wantUnknowns, "\n"))
+	}
+}
@@ -24,6 +24,10 @@ func ImpliedSchema(spec Spec) *hcl.BodySchema {
 			blocks = append(blocks, bs.blockHeaderSchemata()...)
 		}
 
+		if _, ok := s.(schemaWrapperSpec); ok {
+			// This spec has already included the schema of its children.
+			return
+		}
 		s.visitSameBodyChildren(visit)
 	}
 
@@ -6,6 +6,7 @@ import (
 	"sort"
 
 	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/internal/prose"
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/convert"
 	"github.com/zclconf/go-cty/cty/function"
@@ -16,13 +17,17 @@ import (
 // The various other types in this package whose names end in "Spec" are
 // the spec implementations. The most common top-level spec is ObjectSpec,
 // which decodes body content into a cty.Value of an object type.
+//
+// The specs that describe attributes and blocks have a Description field,
+// which does not affect decoding but can be included in documentation
+// generated from a spec.
 type Spec interface {
 	// Perform the decode operation on the given body, in the context of
 	// the given block (which might be null), using the given eval context.
 	//
 	// "block" is provided only by the nested calls performed by the spec
 	// types that work on block bodies.
-	decode(content *hcl.BodyContent, blockLabels []blockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics)
+	decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics)
 
 	// Return the cty.Type that should be returned when decoding a body with
 	// this spec.
@@ -37,11 +42,22 @@ type Spec interface {
 	// spec in the given content, in the context of the given block
 	// (which might be null). If the corresponding item is missing, return
 	// a place where it might be inserted.
-	sourceRange(content *hcl.BodyContent, blockLabels []blockLabel) hcl.Range
+	sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range
 }
 
 type visitFunc func(spec Spec)
 
+// schemaWrapperSpec is implemented by specs that adjust the schema of the
+// specs they wrap, and so must be the only source of schema and variables
+// for their same-body children. ImpliedSchema and Variables do not visit
+// the children of such specs.
+type schemaWrapperSpec interface {
+	attrSpec
+	blockSpec
+	specNeedingVariables
+	wrapsSchema()
+}
+
 // An ObjectSpec is a Spec that produces a cty.Value of an object type whose
 // attributes correspond to the keys of the spec map.
 type ObjectSpec map[string]Spec
@@ -64,12 +80,19 @@ type specNeedingVariables interface {
 }
 
 func (s ObjectSpec) visitSameBodyChildren(cb visitFunc) {
-	for _, c := range s {
-		cb(c)
+	// We visit in a predictable order so that callers of the public Walk
+	// function get consistent results.
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		cb(s[k])
 	}
 }
 
-func (s ObjectSpec) decode(content *hcl.BodyContent, blockLabels []blockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+func (s ObjectSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 	vals := make(map[string]cty.Value, len(s))
 	var diags hcl.Diagnostics
 
@@ -94,7 +117,7 @@ func (s ObjectSpec) impliedType() cty.Type {
 	return cty.Object(attrTypes)
 }
 
-func (s ObjectSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLabel) hcl.Range {
+func (s ObjectSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
 	// This is not great, but the best we can do. In practice, it's rather
 	// strange to ask for the source range of an entire top-level body, since
 	// that's already readily available to the caller.
@@ -111,7 +134,7 @@ func (s TupleSpec) visitSameBodyChildren(cb visitFunc) {
 	}
 }
 
-func (s TupleSpec) decode(content *hcl.BodyContent, blockLabels []blockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+func (s TupleSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 	vals := make([]cty.Value, len(s))
 	var diags hcl.Diagnostics
 
@@ -136,7 +159,7 @@ func (s TupleSpec) impliedType() cty.Type {
 	return cty.Tuple(attrTypes)
 }
 
-func (s TupleSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLabel) hcl.Range {
+func (s TupleSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
 	// This is not great, but the best we can do. In practice, it's rather
 	// strange to ask for the source range of an entire top-level body, since
 	// that's already readily available to the caller.
@@ -147,9 +170,10 @@ func (s TupleSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLabe
 // the body and returns its resulting value converted to the requested type,
 // or produces a diagnostic if the type is incorrect.
 type AttrSpec struct {
-	Name     string
-	Type     cty.Type
-	Required bool
+	Name        string
+	Type        cty.Type
+	Required    bool
+	Description string
 }
 
 func (s *AttrSpec) visitSameBodyChildren(cb visitFunc) {
@@ -176,7 +200,7 @@ func (s *AttrSpec) attrSchemata() []hcl.AttributeSchema {
 	}
 }
 
-func (s *AttrSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLabel) hcl.Range {
+func (s *AttrSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
 	attr, exists := content.Attributes[s.Name]
 	if !exists {
 		return content.MissingItemRange
@@ -185,7 +209,7 @@ func (s *AttrSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLabe
 	return attr.Expr.Range()
 }
 
-func (s *AttrSpec) decode(content *hcl.BodyContent, blockLabels []blockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+func (s *AttrSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 	attr, exists := content.Attributes[s.Name]
 	if !exists {
 		// We don't need to check required and emit a diagnostic here, because
@@ -231,7 +255,7 @@ func (s *LiteralSpec) visitSameBodyChildren(cb visitFunc) {
 	// leaf node
 }
 
-func (s *LiteralSpec) decode(content *hcl.BodyContent, blockLabels []blockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+func (s *LiteralSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 	return s.Value, nil
 }
 
@@ -239,7 +263,7 @@ func (s *LiteralSpec) impliedType() cty.Type {
 	return s.Value.Type()
 }
 
-func (s *LiteralSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLabel) hcl.Range {
+func (s *LiteralSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
 	// No sensible range to return for a literal, so the caller had better
 	// ensure it doesn't cause any diagnostics.
 	return hcl.Range{
@@ -262,7 +286,7 @@ func (s *ExprSpec) variablesNeeded(content *hcl.BodyContent) []hcl.Traversal {
 	return s.Expr.Variables()
 }
 
-func (s *ExprSpec) decode(content *hcl.BodyContent, blockLabels []blockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+func (s *ExprSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 	return s.Expr.Value(ctx)
 }
 
@@ -271,7 +295,7 @@ func (s *ExprSpec) impliedType() cty.Type {
 	return cty.DynamicPseudoType
 }
 
-func (s *ExprSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLabel) hcl.Range {
+func (s *ExprSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
 	return s.Expr.Range()
 }
 
@@ -282,9 +306,10 @@ func (s *ExprSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLabe
 // case a null value is produced. If it _is_ set, an error diagnostic is
 // produced if there are no nested blocks of the given type.
 type BlockSpec struct {
-	TypeName string
-	Nested   Spec
-	Required bool
+	TypeName    string
+	Nested      Spec
+	Required    bool
+	Description string
 }
 
 func (s *BlockSpec) visitSameBodyChildren(cb visitFunc) {
@@ -325,7 +350,7 @@ func (s *BlockSpec) variablesNeeded(content *hcl.BodyContent) []hcl.Traversal {
 	return Variables(childBlock.Body, s.Nested)
 }
 
-func (s *BlockSpec) decode(content *hcl.BodyContent, blockLabels []blockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+func (s *BlockSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 	var diags hcl.Diagnostics
 
 	var childBlock *hcl.Block
@@ -376,7 +401,7 @@ func (s *BlockSpec) impliedType() cty.Type {
 	return s.Nested.impliedType()
 }
 
-func (s *BlockSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLabel) hcl.Range {
+func (s *BlockSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
 	var childBlock *hcl.Block
 	for _, candidate := range content.Blocks {
 		if candidate.Type != s.TypeName {
@@ -397,10 +422,11 @@ func (s *BlockSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLab
 // A BlockListSpec is a Spec that produces a cty list of the results of
 // decoding all of the nested blocks of a given type, using a nested spec.
 type BlockListSpec struct {
-	TypeName string
-	Nested   Spec
-	MinItems int
-	MaxItems int
+	TypeName    string
+	Nested      Spec
+	MinItems    int
+	MaxItems    int
+	Description string
 }
 
 func (s *BlockListSpec) visitSameBodyChildren(cb visitFunc) {
@@ -437,7 +463,7 @@ func (s *BlockListSpec) variablesNeeded(content *hcl.BodyContent) []hcl.Traversa
 	return ret
 }
 
-func (s *BlockListSpec) decode(content *hcl.BodyContent, blockLabels []blockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+func (s *BlockListSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 	var diags hcl.Diagnostics
 
 	if s.Nested == nil {
@@ -526,7 +552,7 @@ func (s *BlockListSpec) impliedType() cty.Type {
 	return cty.List(s.Nested.impliedType())
 }
 
-func (s *BlockListSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLabel) hcl.Range {
+func (s *BlockListSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
 	// We return the source range of the _first_ block of the given type,
 	// since they are not guaranteed to form a contiguous range.
 
@@ -594,7 +620,7 @@ func (s *BlockTupleSpec) variablesNeeded(content *hcl.BodyContent) []hcl.Travers
 	return ret
 }
 
-func (s *BlockTupleSpec) decode(content *hcl.BodyContent, blockLabels []blockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+func (s *BlockTupleSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 	var diags hcl.Diagnostics
 
 	if s.Nested == nil {
@@ -647,7 +673,7 @@ func (s *BlockTupleSpec) impliedType() cty.Type {
 	return cty.DynamicPseudoType
 }
 
-func (s *BlockTupleSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLabel) hcl.Range {
+func (s *BlockTupleSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
 	// We return the source range of the _first_ block of the given type,
 	// since they are not guaranteed to form a contiguous range.
 
@@ -671,10 +697,11 @@ func (s *BlockTupleSpec) sourceRange(content *hcl.BodyContent, blockLabels []blo
 // A BlockSetSpec is a Spec that produces a cty set of the results of
 // decoding all of the nested blocks of a given type, using a nested spec.
 type BlockSetSpec struct {
-	TypeName string
-	Nested   Spec
-	MinItems int
-	MaxItems int
+	TypeName    string
+	Nested      Spec
+	MinItems    int
+	MaxItems    int
+	Description string
 }
 
 func (s *BlockSetSpec) visitSameBodyChildren(cb visitFunc) {
@@ -711,7 +738,7 @@ func (s *BlockSetSpec) variablesNeeded(content *hcl.BodyContent) []hcl.Traversal
 	return ret
 }
 
-func (s *BlockSetSpec) decode(content *hcl.BodyContent, blockLabels []blockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+func (s *BlockSetSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 	var diags hcl.Diagnostics
 
 	if s.Nested == nil {
@@ -800,7 +827,7 @@ func (s *BlockSetSpec) impliedType() cty.Type {
 	return cty.Set(s.Nested.impliedType())
 }
 
-func (s *BlockSetSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLabel) hcl.Range {
+func (s *BlockSetSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
 	// We return the source range of the _first_ block of the given type,
 	// since they are not guaranteed to form a contiguous range.
 
@@ -827,9 +854,10 @@ func (s *BlockSetSpec) sourceRange(content *hcl.BodyContent, blockLabels []block
 // One level of map structure is created for each of the given label names.
 // There must be at least one given label name.
 type BlockMapSpec struct {
-	TypeName   string
-	LabelNames []string
-	Nested     Spec
+	TypeName    string
+	LabelNames  []string
+	Nested      Spec
+	Description string
 }
 
 func (s *BlockMapSpec) visitSameBodyChildren(cb visitFunc) {
@@ -866,7 +894,7 @@ func (s *BlockMapSpec) variablesNeeded(content *hcl.BodyContent) []hcl.Traversal
 	return ret
 }
 
-func (s *BlockMapSpec) decode(content *hcl.BodyContent, blockLabels []blockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+func (s *BlockMapSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 	var diags hcl.Diagnostics
 
 	if s.Nested == nil {
@@ -945,7 +973,7 @@ func (s *BlockMapSpec) impliedType() cty.Type {
 	return ret
 }
 
-func (s *BlockMapSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLabel) hcl.Range {
+func (s *BlockMapSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
 	// We return the source range of the _first_ block of the given type,
 	// since they are not guaranteed to form a contiguous range.
 
@@ -1015,7 +1043,7 @@ func (s *BlockObjectSpec) variablesNeeded(content *hcl.BodyContent) []hcl.Traver
 	return ret
 }
 
-func (s *BlockObjectSpec) decode(content *hcl.BodyContent, blockLabels []blockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+func (s *BlockObjectSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 	var diags hcl.Diagnostics
 
 	if s.Nested == nil {
@@ -1089,7 +1117,7 @@ func (s *BlockObjectSpec) impliedType() cty.Type {
 	return cty.DynamicPseudoType
 }
 
-func (s *BlockObjectSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLabel) hcl.Range {
+func (s *BlockObjectSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
 	// We return the source range of the _first_ block of the given type,
 	// since they are not guaranteed to form a contiguous range.
 
@@ -1129,6 +1157,7 @@ type BlockAttrsSpec struct {
 	TypeName    string
 	ElementType cty.Type
 	Required    bool
+	Description string
 }
 
 func (s *BlockAttrsSpec) visitSameBodyChildren(cb visitFunc) {
@@ -1183,7 +1212,7 @@ func (s *BlockAttrsSpec) variablesNeeded(content *hcl.BodyContent) []hcl.Travers
 	return vars
 }
 
-func (s *BlockAttrsSpec) decode(content *hcl.BodyContent, blockLabels []blockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+func (s *BlockAttrsSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 	var diags hcl.Diagnostics
 
 	block, other := s.findBlock(content)
@@ -1245,7 +1274,7 @@ func (s *BlockAttrsSpec) impliedType() cty.Type {
 	return cty.Map(s.ElementType)
 }
 
-func (s *BlockAttrsSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLabel) hcl.Range {
+func (s *BlockAttrsSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
 	block, _ := s.findBlock(content)
 	if block == nil {
 		return content.MissingItemRange
@@ -1287,7 +1316,7 @@ func (s *BlockLabelSpec) visitSameBodyChildren(cb visitFunc) {
 	// leaf node
 }
 
-func (s *BlockLabelSpec) decode(content *hcl.BodyContent, blockLabels []blockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+func (s *BlockLabelSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 	if s.Index >= len(blockLabels) {
 		panic("BlockListSpec used in non-block context")
 	}
@@ -1299,7 +1328,7 @@ func (s *BlockLabelSpec) impliedType() cty.Type {
 	return cty.String // labels are always strings
 }
 
-func (s *BlockLabelSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLabel) hcl.Range {
+func (s *BlockLabelSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
 	if s.Index >= len(blockLabels) {
 		panic("BlockListSpec used in non-block context")
 	}
@@ -1370,7 +1399,7 @@ func (s *DefaultSpec) visitSameBodyChildren(cb visitFunc) {
 	cb(s.Default)
 }
 
-func (s *DefaultSpec) decode(content *hcl.BodyContent, blockLabels []blockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+func (s *DefaultSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 	val, diags := s.Primary.decode(content, blockLabels, ctx)
 	if val.IsNull() {
 		var moreDiags hcl.Diagnostics
@@ -1416,7 +1445,7 @@ func (s *DefaultSpec) nestedSpec() Spec {
 	return nil
 }
 
-func (s *DefaultSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLabel) hcl.Range {
+func (s *DefaultSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
 	// We can't tell from here which of the two specs will ultimately be used
 	// in our result, so we'll just assume the first. This is usually the right
 	// choice because the default is often a literal spec that doesn't have a
@@ -1442,7 +1471,7 @@ func (s *TransformExprSpec) visitSameBodyChildren(cb visitFunc) {
 	cb(s.Wrapped)
 }
 
-func (s *TransformExprSpec) decode(content *hcl.BodyContent, blockLabels []blockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+func (s *TransformExprSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 	wrappedVal, diags := s.Wrapped.decode(content, blockLabels, ctx)
 	if diags.HasErrors() {
 		// We won't try to run our function in this case, because it'll probably
@@ -1470,7 +1499,7 @@ func (s *TransformExprSpec) impliedType() cty.Type {
 	return resultVal.Type()
 }
 
-func (s *TransformExprSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLabel) hcl.Range {
+func (s *TransformExprSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
 	// We'll just pass through our wrapped range here, even though that's
 	// not super-accurate, because there's nothing better to return.
 	return s.Wrapped.sourceRange(content, blockLabels)
@@ -1498,7 +1527,7 @@ func (s *TransformFuncSpec) visitSameBodyChildren(cb visitFunc) {
 	cb(s.Wrapped)
 }
 
-func (s *TransformFuncSpec) decode(content *hcl.BodyContent, blockLabels []blockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+func (s *TransformFuncSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 	wrappedVal, diags := s.Wrapped.decode(content, blockLabels, ctx)
 	if diags.HasErrors() {
 		// We won't try to run our function in this case, because it'll probably
@@ -1535,19 +1564,569 @@ func (s *TransformFuncSpec) impliedType() cty.Type {
 	return resultTy
 }
 
-func (s *TransformFuncSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLabel) hcl.Range {
+func (s *TransformFuncSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
 	// We'll just pass through our wrapped range here, even though that's
 	// not super-accurate, because there's nothing better to return.
 	return s.Wrapped.sourceRange(content, blockLabels)
 }
 
+// ValidateSpec is a spec that wraps another and then checks the result
+// against a set of conditions, producing an error diagnostic for each
+// condition that does not hold.
+//
+// The subject of each diagnostic is the source range of the wrapped spec,
+// so that errors are reported against the relevant part of the input rather
+// than in a separate validation step after decoding.
+//
+// Conditions are not checked if decoding the wrapped spec produces errors.
+// ValidateSpec creates no schema requirements of its own, but passes on the
+// requirements from its wrapped spec.
+//
+// Unlike most specs, ValidateSpec is not registered with gob and so cannot
+// be sent over gob channels: gob silently drops the Func of each condition,
+// and cannot encode Expr or EvalCtx at all.
+type ValidateSpec struct {
+	Wrapped    Spec
+	Conditions []ValidateCondition
+}
+
+// ValidateCondition is a single condition checked by a ValidateSpec.
+//
+// Exactly one of Func and Expr must be set. Func is a Go callback that
+// returns a non-nil error if the given value is invalid, in which case the
+// error message is used in the resulting diagnostic. Expr is an expression
+// that must return a boolean, evaluated in a child of EvalCtx where the
+// variable "self" is the result of the wrapped spec. If it returns false
+// then ErrorMessage is used in the resulting diagnostic.
+//
+// Func is called only when the value is wholly known, and an unknown result
+// from Expr is considered to be valid, since the value may become valid
+// once it is known.
+type ValidateCondition struct {
+	Func func(val cty.Value) error
+
+	Expr         hcl.Expression
+	EvalCtx      *hcl.EvalContext
+	ErrorMessage string
+}
+
+func (s *ValidateSpec) visitSameBodyChildren(cb visitFunc) {
+	cb(s.Wrapped)
+}
+
+func (s *ValidateSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	val, diags := s.Wrapped.decode(content, blockLabels, ctx)
+	if diags.HasErrors() {
+		// The conditions would probably fail for an erroneous value,
+		// generating confusing additional errors that would distract from
+		// the root cause.
+		return val, diags
+	}
+
+	for i := range s.Conditions {
+		cond := &s.Conditions[i]
+		diags = append(diags, cond.check(val, s.Wrapped.sourceRange(content, blockLabels))...)
+	}
+
+	return val, diags
+}
+
+func (c *ValidateCondition) check(val cty.Value, rng hcl.Range) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	if c.Func != nil {
+		if !val.IsWhollyKnown() {
+			return diags
+		}
+		if err := c.Func(val); err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid value",
+				Detail:   err.Error(),
+				Subject:  &rng,
+			})
+		}
+		return diags
+	}
+
+	if c.Expr == nil {
+		// Should never happen with a correctly-configured spec
+		return diags
+	}
+
+	chiCtx := c.EvalCtx.NewChild()
+	chiCtx.Variables = map[string]cty.Value{
+		"self": val,
+	}
+	result, resultDiags := c.Expr.Value(chiCtx)
+	diags = append(diags, resultDiags...)
+	if resultDiags.HasErrors() {
+		return diags
+	}
+
+	result, err := convert.Convert(result, cty.Bool)
+	if err != nil || result.IsNull() {
+		// This is reporting a problem in the spec rather than the input,
+		// but we can only detect it once we have a value to check.
+		detail := "Validation condition must produce a boolean value, not null."
+		if err != nil {
+			detail = fmt.Sprintf("Invalid validation condition result: %s.", err)
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid validation condition",
+			Detail:   detail,
+			Subject:  c.Expr.Range().Ptr(),
+		})
+		return diags
+	}
+	if !result.IsKnown() || result.True() {
+		return diags
+	}
+
+	detail := c.ErrorMessage
+	if detail == "" {
+		detail = "The value does not meet the validation conditions."
+	}
+	diags = append(diags, &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Invalid value",
+		Detail:   detail,
+		Subject:  &rng,
+	})
+	return diags
+}
+
+func (s *ValidateSpec) impliedType() cty.Type {
+	return s.Wrapped.impliedType()
+}
+
+func (s *ValidateSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
+	return s.Wrapped.sourceRange(content, blockLabels)
+}
+
+// DeprecatedSpec is a spec that wraps an attribute or block spec, such as
+// AttrSpec or BlockListSpec, to mark it as deprecated or renamed.
+//
+// If OldName is empty then any use of the wrapped attribute or block produces
+// a warning diagnostic including Message, but is otherwise decoded as normal.
+//
+// If OldName is set then the wrapped attribute or block was previously known
+// by that name. The schema then accepts either name, but not both at once,
+// and a value given under the old name is decoded as if it had been given
+// under the new name, along with a warning diagnostic including Message. In
+// this case the wrapped spec must describe exactly one attribute or exactly
+// one block type, or the result is undefined.
+type DeprecatedSpec struct {
+	Wrapped Spec
+	OldName string
+	Message string
+}
+
+func (s *DeprecatedSpec) visitSameBodyChildren(cb visitFunc) {
+	cb(s.Wrapped)
+}
+
+// schemaWrapperSpec implementation
+func (s *DeprecatedSpec) wrapsSchema() {}
+
+// attrSpec implementation
+func (s *DeprecatedSpec) attrSchemata() []hcl.AttributeSchema {
+	schema := ImpliedSchema(s.Wrapped)
+	newName, isBlock := s.newName()
+	if s.OldName == "" || isBlock {
+		return schema.Attributes
+	}
+
+	ret := make([]hcl.AttributeSchema, 0, len(schema.Attributes)+1)
+	for _, attrS := range schema.Attributes {
+		if attrS.Name == newName {
+			// We must check whether required attributes are present
+			// ourselves, since either of the names may be used.
+			attrS.Required = false
+			ret = append(ret, attrS, hcl.AttributeSchema{Name: s.OldName})
+			continue
+		}
+		ret = append(ret, attrS)
+	}
+	return ret
+}
+
+// blockSpec implementation
+func (s *DeprecatedSpec) blockHeaderSchemata() []hcl.BlockHeaderSchema {
+	schema := ImpliedSchema(s.Wrapped)
+	newName, isBlock := s.newName()
+	if s.OldName == "" || !isBlock {
+		return schema.Blocks
+	}
+
+	ret := make([]hcl.BlockHeaderSchema, 0, len(schema.Blocks)+1)
+	for _, blockS := range schema.Blocks {
+		ret = append(ret, blockS)
+		if blockS.Type == newName {
+			ret = append(ret, hcl.BlockHeaderSchema{
+				Type:       s.OldName,
+				LabelNames: blockS.LabelNames,
+			})
+		}
+	}
+	return ret
+}
+
+// blockSpec implementation
+func (s *DeprecatedSpec) nestedSpec() Spec {
+	if bs, ok := s.Wrapped.(blockSpec); ok {
+		return bs.nestedSpec()
+	}
+	return nil
+}
+
+// specNeedingVariables implementation
+func (s *DeprecatedSpec) variablesNeeded(content *hcl.BodyContent) []hcl.Traversal {
+	return variablesNeeded(s.Wrapped, s.renameContent(content))
+}
+
+func (s *DeprecatedSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+
+	newName, isBlock := s.newName()
+	switch {
+	case s.OldName == "":
+		schema := ImpliedSchema(s.Wrapped)
+		for _, attrS := range schema.Attributes {
+			if attr, exists := content.Attributes[attrS.Name]; exists {
+				diags = append(diags, s.deprecatedDiagnostic("argument", attrS.Name, attr.NameRange))
+			}
+		}
+		for _, blockS := range schema.Blocks {
+			for _, block := range content.Blocks {
+				if block.Type == blockS.Type {
+					diags = append(diags, s.deprecatedDiagnostic("block", blockS.Type, block.TypeRange))
+				}
+			}
+		}
+
+	case isBlock:
+		var newBlock *hcl.Block
+		for _, block := range content.Blocks {
+			if block.Type == newName {
+				newBlock = block
+				break
+			}
+		}
+		for _, block := range content.Blocks {
+			if block.Type != s.OldName {
+				continue
+			}
+			if newBlock != nil {
+				diags = append(diags, s.conflictDiagnostic("block", newName, block.TypeRange, newBlock.DefRange))
+				continue
+			}
+			diags = append(diags, s.renamedDiagnostic("block", newName, block.TypeRange))
+		}
+
+	default:
+		oldAttr, oldExists := content.Attributes[s.OldName]
+		newAttr, newExists := content.Attributes[newName]
+		switch {
+		case oldExists && newExists:
+			diags = append(diags, s.conflictDiagnostic("argument", newName, oldAttr.NameRange, newAttr.Range))
+		case oldExists:
+			diags = append(diags, s.renamedDiagnostic("argument", newName, oldAttr.NameRange))
+		case !newExists && s.newRequired(newName):
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Missing required argument",
+				Detail:   fmt.Sprintf("The argument %q is required, but no definition was found.", newName),
+				Subject:  content.MissingItemRange.Ptr(),
+			})
+		}
+	}
+
+	val, moreDiags := s.Wrapped.decode(s.renameContent(content), blockLabels, ctx)
+	diags = append(diags, moreDiags...)
+	return val, diags
+}
+
+func (s *DeprecatedSpec) impliedType() cty.Type {
+	return s.Wrapped.impliedType()
+}
+
+func (s *DeprecatedSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
+	return s.Wrapped.sourceRange(s.renameContent(content), blockLabels)
+}
+
+// newName returns the name of the attribute or block type described by the
+// wrapped spec, along with a flag that is true if it is a block type.
+func (s *DeprecatedSpec) newName() (string, bool) {
+	schema := ImpliedSchema(s.Wrapped)
+	if len(schema.Attributes) > 0 {
+		return schema.Attributes[0].Name, false
+	}
+	if len(schema.Blocks) > 0 {
+		return schema.Blocks[0].Type, true
+	}
+	return "", false
+}
+
+func (s *DeprecatedSpec) newRequired(newName string) bool {
+	for _, attrS := range ImpliedSchema(s.Wrapped).Attributes {
+		if attrS.Name == newName && attrS.Required {
+			return true
+		}
+	}
+	return false
+}
+
+// renameContent returns a copy of the given content where any attribute or
+// blocks using the old name are changed to use the new name, so that the
+// wrapped spec can find them. If both names are used then the items using
+// the old name are discarded.
+func (s *DeprecatedSpec) renameContent(content *hcl.BodyContent) *hcl.BodyContent {
+	newName, isBlock := s.newName()
+	if s.OldName == "" || newName == "" {
+		return content
+	}
+
+	ret := *content
+	if isBlock {
+		hasNew := false
+		for _, block := range content.Blocks {
+			if block.Type == newName {
+				hasNew = true
+				break
+			}
+		}
+		ret.Blocks = make(hcl.Blocks, 0, len(content.Blocks))
+		for _, block := range content.Blocks {
+			if block.Type == s.OldName {
+				if hasNew {
+					continue
+				}
+				renamed := *block
+				renamed.Type = newName
+				block = &renamed
+			}
+			ret.Blocks = append(ret.Blocks, block)
+		}
+		return &ret
+	}
+
+	oldAttr, exists := content.Attributes[s.OldName]
+	if !exists {
+		return content
+	}
+	ret.Attributes = make(hcl.Attributes, len(content.Attributes))
+	for name, attr := range content.Attributes {
+		if name != s.OldName {
+			ret.Attributes[name] = attr
+		}
+	}
+	if _, exists := ret.Attributes[newName]; !exists {
+		renamed := *oldAttr
+		renamed.Name = newName
+		ret.Attributes[newName] = &renamed
+	}
+	return &ret
+}
+
+func (s *DeprecatedSpec) deprecatedDiagnostic(kind, name string, rng hcl.Range) *hcl.Diagnostic {
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagWarning,
+		Summary:  fmt.Sprintf("Deprecated %s", kind),
+		Detail:   s.detail(fmt.Sprintf("The %s %q is deprecated.", kind, name)),
+		Subject:  &rng,
+	}
+}
+
+func (s *DeprecatedSpec) renamedDiagnostic(kind, newName string, rng hcl.Range) *hcl.Diagnostic {
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagWarning,
+		Summary:  fmt.Sprintf("Deprecated %s name", kind),
+		Detail:   s.detail(fmt.Sprintf("The %s %q has been renamed to %q.", kind, s.OldName, newName)),
+		Subject:  &rng,
+	}
+}
+
+func (s *DeprecatedSpec) conflictDiagnostic(kind, newName string, rng, newRng hcl.Range) *hcl.Diagnostic {
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  fmt.Sprintf("Conflicting %s names", kind),
+		Detail: fmt.Sprintf(
+			"The %s %q has been renamed to %q, and another definition using the new name was already given at %s. Remove the %s using the old name.",
+			kind, s.OldName, newName, newRng, kind,
+		),
+		Subject: &rng,
+	}
+}
+
+func (s *DeprecatedSpec) detail(msg string) string {
+	if s.Message == "" {
+		return msg
+	}
+	return msg + " " + s.Message
+}
+
+// OneOfSpec is a spec that produces an object value in the same way as
+// ObjectSpec, but additionally requires that the body contains the items
+// for at most one of the given specs, such as when a configuration value
+// may be given either as a "source_file" or a "source_inline" argument.
+//
+// If Required is set then the body must contain the items for exactly one
+// of the given specs. The nested specs should not themselves be required,
+// since that would contradict the purpose of this spec.
+//
+// AtMostOneOf and ExactlyOneOf are convenience constructors for this spec.
+type OneOfSpec struct {
+	Specs    ObjectSpec
+	Required bool
+}
+
+// AtMostOneOf returns a OneOfSpec that allows the body to contain the items
+// for at most one of the given specs.
+func AtMostOneOf(specs ObjectSpec) *OneOfSpec {
+	return &OneOfSpec{
+		Specs: specs,
+	}
+}
+
+// ExactlyOneOf returns a OneOfSpec that requires the body to contain the
+// items for exactly one of the given specs.
+func ExactlyOneOf(specs ObjectSpec) *OneOfSpec {
+	return &OneOfSpec{
+		Specs:    specs,
+		Required: true,
+	}
+}
+
+// oneOfItem describes an attribute or block found in the body for one of
+// the specs of a OneOfSpec.
+type oneOfItem struct {
+	Desc  string
+	Range hcl.Range
+}
+
+func (s *OneOfSpec) visitSameBodyChildren(cb visitFunc) {
+	s.Specs.visitSameBodyChildren(cb)
+}
+
+func (s *OneOfSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+
+	var found []oneOfItem
+	var names []string
+	for _, k := range s.keys() {
+		schema := ImpliedSchema(s.Specs[k])
+		for _, attrS := range schema.Attributes {
+			names = append(names, fmt.Sprintf("%q", attrS.Name))
+		}
+		for _, blockS := range schema.Blocks {
+			names = append(names, fmt.Sprintf("%q", blockS.Type))
+		}
+		if items := s.findItems(content, schema); len(items) > 0 {
+			// We only report the first item for each spec, since multiple
+			// items for the same spec are not a conflict between specs.
+			found = append(found, items[0])
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Range.Start.Byte < found[j].Range.Start.Byte
+	})
+
+	switch {
+	case len(found) > 1:
+		var buf bytes.Buffer
+		for i, item := range found {
+			switch {
+			case i == len(found)-1:
+				buf.WriteString(" and ")
+			case i > 0:
+				buf.WriteString(", ")
+			}
+			fmt.Fprintf(&buf, "%s at %s", item.Desc, item.Range)
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Conflicting configuration arguments",
+			Detail: fmt.Sprintf(
+				"Only one of %s may be set, but found %s.",
+				prose.JoinAlternatives(names), buf.String(),
+			),
+			Subject: found[1].Range.Ptr(),
+		})
+	case len(found) == 0 && s.Required:
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Missing required configuration argument",
+			Detail:   fmt.Sprintf("Exactly one of %s must be set.", prose.JoinAlternatives(names)),
+			Subject:  content.MissingItemRange.Ptr(),
+		})
+	}
+
+	val, moreDiags := s.Specs.decode(content, blockLabels, ctx)
+	diags = append(diags, moreDiags...)
+	return val, diags
+}
+
+func (s *OneOfSpec) impliedType() cty.Type {
+	return s.Specs.impliedType()
+}
+
+func (s *OneOfSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
+	// If one of our specs is set then its items are the most interesting
+	// part of the body.
+	for _, k := range s.keys() {
+		if items := s.findItems(content, ImpliedSchema(s.Specs[k])); len(items) > 0 {
+			return items[0].Range
+		}
+	}
+	return content.MissingItemRange
+}
+
+func (s *OneOfSpec) keys() []string {
+	keys := make([]string, 0, len(s.Specs))
+	for k := range s.Specs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// findItems returns the attributes and blocks in the given content that
+// are described by the given schema, in source order.
+func (s *OneOfSpec) findItems(content *hcl.BodyContent, schema *hcl.BodySchema) []oneOfItem {
+	var ret []oneOfItem
+	for _, attrS := range schema.Attributes {
+		if attr, exists := content.Attributes[attrS.Name]; exists {
+			ret = append(ret, oneOfItem{
+				Desc:  fmt.Sprintf("the argument %q", attr.Name),
+				Range: attr.NameRange,
+			})
+		}
+	}
+	for _, blockS := range schema.Blocks {
+		for _, block := range content.Blocks {
+			if block.Type == blockS.Type {
+				ret = append(ret, oneOfItem{
+					Desc:  fmt.Sprintf("a block of type %q", block.Type),
+					Range: block.DefRange,
+				})
+			}
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Range.Start.Byte < ret[j].Range.Start.Byte
+	})
+	return ret
+}
+
 // noopSpec is a placeholder spec that does nothing, used in situations where
 // a non-nil placeholder spec is required. It is not exported because there is
 // no reason to use it directly; it is always an implementation detail only.
 type noopSpec struct {
 }
 
-func (s noopSpec) decode(content *hcl.BodyContent, blockLabels []blockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+func (s noopSpec) decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 	return cty.NullVal(cty.DynamicPseudoType), nil
 }
 
@@ -1559,7 +2138,7 @@ func (s noopSpec) visitSameBodyChildren(cb visitFunc) {
 	// nothing to do
 }
 
-func (s noopSpec) sourceRange(content *hcl.BodyContent, blockLabels []blockLabel) hcl.Range {
+func (s noopSpec) sourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
 	// No useful range for a noopSpec, and nobody should be calling this anyway.
 	return hcl.Range{
 		Filename: "noopSpec",
@@ -1,7 +1,9 @@
 package hcldec
 
 import (
+	"fmt"
 	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/apparentlymart/go-dump/dump"
@@ -26,9 +28,14 @@ var _ Spec = (*BlockLabelSpec)(nil)
 var _ Spec = (*DefaultSpec)(nil)
 var _ Spec = (*TransformExprSpec)(nil)
 var _ Spec = (*TransformFuncSpec)(nil)
+var _ Spec = (*ValidateSpec)(nil)
+var _ Spec = (*DeprecatedSpec)(nil)
+var _ Spec = (*OneOfSpec)(nil)
+var _ Spec = (*CustomSpec)(nil)
 
 var _ attrSpec = (*AttrSpec)(nil)
 var _ attrSpec = (*DefaultSpec)(nil)
+var _ attrSpec = (*CustomSpec)(nil)
 
 var _ blockSpec = (*BlockSpec)(nil)
 var _ blockSpec = (*BlockListSpec)(nil)
@@ -36,6 +43,7 @@ var _ blockSpec = (*BlockSetSpec)(nil)
 var _ blockSpec = (*BlockMapSpec)(nil)
 var _ blockSpec = (*BlockAttrsSpec)(nil)
 var _ blockSpec = (*DefaultSpec)(nil)
+var _ blockSpec = (*CustomSpec)(nil)
 
 var _ specNeedingVariables = (*AttrSpec)(nil)
 var _ specNeedingVariables = (*BlockSpec)(nil)
@@ -43,6 +51,10 @@ var _ specNeedingVariables = (*BlockListSpec)(nil)
 var _ specNeedingVariables = (*BlockSetSpec)(nil)
 var _ specNeedingVariables = (*BlockMapSpec)(nil)
 var _ specNeedingVariables = (*BlockAttrsSpec)(nil)
+var _ specNeedingVariables = (*CustomSpec)(nil)
+var _ specNeedingVariables = (*DeprecatedSpec)(nil)
+
+var _ schemaWrapperSpec = (*DeprecatedSpec)(nil)
 
 func TestDefaultSpec(t *testing.T) {
 	config := `
@@ -139,3 +151,311 @@ bar = barval
 		}
 	})
 }
+
+func TestValidateSpec(t *testing.T) {
+	config := `
+port = 0
+name = "foo"
+`
+	f, diags := hclsyntax.ParseConfig([]byte(config), "", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	condExpr, diags := hclsyntax.ParseExpression([]byte(`self > 0`), "spec.hcl", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	spec := ObjectSpec{
+		"port": &ValidateSpec{
+			Wrapped: &AttrSpec{
+				Name: "port",
+				Type: cty.Number,
+			},
+			Conditions: []ValidateCondition{
+				{
+					Expr:         condExpr,
+					ErrorMessage: "The port number must be positive.",
+				},
+			},
+		},
+		"name": &ValidateSpec{
+			Wrapped: &AttrSpec{
+				Name: "name",
+				Type: cty.String,
+			},
+			Conditions: []ValidateCondition{
+				{
+					Func: func(val cty.Value) error {
+						if val.AsString() == "foo" {
+							return fmt.Errorf("The name %q is reserved.", val.AsString())
+						}
+						return nil
+					},
+				},
+			},
+		},
+	}
+
+	got, diags := Decode(f.Body, spec, nil)
+	want := cty.ObjectVal(map[string]cty.Value{
+		"port": cty.NumberIntVal(0),
+		"name": cty.StringVal("foo"),
+	})
+	if !got.RawEquals(want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	if len(diags) != 2 {
+		t.Fatalf("wrong number of diagnostics %d; want 2\n%s", len(diags), diags.Error())
+	}
+	gotDetails := map[string]int{}
+	for _, diag := range diags {
+		gotDetails[diag.Detail] = diag.Subject.Start.Line
+	}
+	wantDetails := map[string]int{
+		"The port number must be positive.": 2,
+		`The name "foo" is reserved.`:       3,
+	}
+	if !reflect.DeepEqual(gotDetails, wantDetails) {
+		t.Errorf("wrong diagnostics\ngot:  %#v\nwant: %#v", gotDetails, wantDetails)
+	}
+}
+
+func TestDeprecatedSpec(t *testing.T) {
+	spec := ObjectSpec{
+		"size": &DeprecatedSpec{
+			Wrapped: &AttrSpec{
+				Name:     "size",
+				Type:     cty.Number,
+				Required: true,
+			},
+			OldName: "capacity",
+			Message: "Use \"size\" instead.",
+		},
+		"legacy": &DeprecatedSpec{
+			Wrapped: &AttrSpec{
+				Name: "legacy",
+				Type: cty.Bool,
+			},
+			Message: "It has no effect.",
+		},
+		"rules": &DeprecatedSpec{
+			Wrapped: &BlockListSpec{
+				TypeName: "rule",
+				Nested: &AttrSpec{
+					Name: "port",
+					Type: cty.Number,
+				},
+			},
+			OldName: "ingress",
+		},
+	}
+
+	tests := map[string]struct {
+		config       string
+		want         cty.Value
+		wantSeverity []hcl.DiagnosticSeverity
+		wantSummary  []string
+	}{
+		"new names": {
+			`
+size = 1
+rule {
+  port = 80
+}
+`,
+			cty.ObjectVal(map[string]cty.Value{
+				"size":   cty.NumberIntVal(1),
+				"legacy": cty.NullVal(cty.Bool),
+				"rules": cty.ListVal([]cty.Value{
+					cty.NumberIntVal(80),
+				}),
+			}),
+			nil,
+			nil,
+		},
+		"old names": {
+			`
+capacity = 2
+legacy = true
+ingress {
+  port = 443
+}
+`,
+			cty.ObjectVal(map[string]cty.Value{
+				"size":   cty.NumberIntVal(2),
+				"legacy": cty.True,
+				"rules": cty.ListVal([]cty.Value{
+					cty.NumberIntVal(443),
+				}),
+			}),
+			[]hcl.DiagnosticSeverity{hcl.DiagWarning, hcl.DiagWarning, hcl.DiagWarning},
+			[]string{"Deprecated argument", "Deprecated argument name", "Deprecated block name"},
+		},
+		"both names": {
+			`
+size = 1
+capacity = 2
+`,
+			cty.ObjectVal(map[string]cty.Value{
+				"size":   cty.NumberIntVal(1),
+				"legacy": cty.NullVal(cty.Bool),
+				"rules":  cty.ListValEmpty(cty.Number),
+			}),
+			[]hcl.DiagnosticSeverity{hcl.DiagError},
+			[]string{"Conflicting argument names"},
+		},
+		"neither name": {
+			``,
+			cty.ObjectVal(map[string]cty.Value{
+				"size":   cty.NullVal(cty.Number),
+				"legacy": cty.NullVal(cty.Bool),
+				"rules":  cty.ListValEmpty(cty.Number),
+			}),
+			[]hcl.DiagnosticSeverity{hcl.DiagError},
+			[]string{"Missing required argument"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, diags := hclsyntax.ParseConfig([]byte(test.config), "", hcl.Pos{Line: 1, Column: 1})
+			if diags.HasErrors() {
+				t.Fatal(diags.Error())
+			}
+
+			got, diags := Decode(f.Body, spec, nil)
+			if !got.RawEquals(test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+
+			var gotSeverity []hcl.DiagnosticSeverity
+			var gotSummary []string
+			for _, diag := range diags {
+				gotSeverity = append(gotSeverity, diag.Severity)
+				gotSummary = append(gotSummary, diag.Summary)
+			}
+			sort.Strings(gotSummary)
+			if !reflect.DeepEqual(gotSeverity, test.wantSeverity) || !reflect.DeepEqual(gotSummary, test.wantSummary) {
+				t.Errorf("wrong diagnostics\ngot:  %#v\nwant: %#v\n%s", gotSummary, test.wantSummary, diags.Error())
+			}
+		})
+	}
+}
+
+func TestDeprecatedSpecVariables(t *testing.T) {
+	config := `
+capacity = foo
+`
+	f, diags := hclsyntax.ParseConfig([]byte(config), "", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	spec := &DeprecatedSpec{
+		Wrapped: &AttrSpec{
+			Name: "size",
+			Type: cty.Number,
+		},
+		OldName: "capacity",
+	}
+	vars := Variables(f.Body, spec)
+	if len(vars) != 1 || vars[0].RootName() != "foo" {
+		t.Errorf("wrong variables %#v", vars)
+	}
+}
+
+func TestOneOfSpec(t *testing.T) {
+	specs := ObjectSpec{
+		"source_file": &AttrSpec{
+			Name: "source_file",
+			Type: cty.String,
+		},
+		"source_inline": &AttrSpec{
+			Name: "source_inline",
+			Type: cty.String,
+		},
+	}
+	wantTy := cty.Object(map[string]cty.Type{
+		"source_file":   cty.String,
+		"source_inline": cty.String,
+	})
+
+	tests := map[string]struct {
+		config      string
+		spec        Spec
+		want        cty.Value
+		wantSummary string
+		wantDetail  string
+	}{
+		"one set": {
+			`source_file = "a.txt"`,
+			ExactlyOneOf(specs),
+			cty.ObjectVal(map[string]cty.Value{
+				"source_file":   cty.StringVal("a.txt"),
+				"source_inline": cty.NullVal(cty.String),
+			}),
+			"",
+			"",
+		},
+		"none set, optional": {
+			``,
+			AtMostOneOf(specs),
+			cty.NullVal(wantTy),
+			"",
+			"",
+		},
+		"none set, required": {
+			``,
+			ExactlyOneOf(specs),
+			cty.NullVal(wantTy),
+			"Missing required configuration argument",
+			`Exactly one of "source_file" or "source_inline" must be set.`,
+		},
+		"both set": {
+			"source_inline = \"hi\"\nsource_file = \"a.txt\"\n",
+			AtMostOneOf(specs),
+			cty.ObjectVal(map[string]cty.Value{
+				"source_file":   cty.StringVal("a.txt"),
+				"source_inline": cty.StringVal("hi"),
+			}),
+			"Conflicting configuration arguments",
+			`Only one of "source_file" or "source_inline" may be set, but found the argument "source_inline" at test.hcl:1,1-14 and the argument "source_file" at test.hcl:2,1-12.`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, diags := hclsyntax.ParseConfig([]byte(test.config), "test.hcl", hcl.Pos{Line: 1, Column: 1})
+			if diags.HasErrors() {
+				t.Fatal(diags.Error())
+			}
+
+			got, diags := Decode(f.Body, test.spec, nil)
+			if !test.want.IsNull() && !got.RawEquals(test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+			if !got.Type().Equals(wantTy) {
+				t.Errorf("wrong result type %#v", got.Type())
+			}
+
+			switch {
+			case test.wantSummary == "":
+				if len(diags) != 0 {
+					t.Errorf("unexpected diagnostics\n%s", diags.Error())
+				}
+			case len(diags) != 1:
+				t.Errorf("wrong number of diagnostics %d; want 1\n%s", len(diags), diags.Error())
+			default:
+				if got, want := diags[0].Summary, test.wantSummary; got != want {
+					t.Errorf("wrong summary\ngot:  %s\nwant: %s", got, want)
+				}
+				if got, want := diags[0].Detail, test.wantDetail; got != want {
+					t.Errorf("wrong detail\ngot:  %s\nwant: %s", got, want)
+				}
+			}
+		})
+	}
+}
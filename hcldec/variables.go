@@ -15,22 +15,29 @@ import (
 // be incomplete, but that's assumed to be okay because the eventual call
 // to Decode will produce error diagnostics anyway.
 func Variables(body hcl.Body, spec Spec) []hcl.Traversal {
-	var vars []hcl.Traversal
 	schema := ImpliedSchema(spec)
 	content, _, _ := body.PartialContent(schema)
 
-	if vs, ok := spec.(specNeedingVariables); ok {
-		vars = append(vars, vs.variablesNeeded(content)...)
-	}
+	return variablesNeeded(spec, content)
+}
+
+// variablesNeeded returns the variables needed by the given spec and its
+// same-body children to decode the given content.
+func variablesNeeded(spec Spec, content *hcl.BodyContent) []hcl.Traversal {
+	var vars []hcl.Traversal
 
 	var visitFn visitFunc
 	visitFn = func(s Spec) {
 		if vs, ok := s.(specNeedingVariables); ok {
 			vars = append(vars, vs.variablesNeeded(content)...)
 		}
+		if _, ok := s.(schemaWrapperSpec); ok {
+			// This spec has already included the variables of its children.
+			return
+		}
 		s.visitSameBodyChildren(visitFn)
 	}
-	spec.visitSameBodyChildren(visitFn)
+	visitFn(spec)
 
 	return vars
 }
@@ -0,0 +1,103 @@
+package hcldec
+
+import (
+	"reflect"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// WalkFunc is the callback signature for Walk. If it returns false, Walk
+// does not descend into the children of the given spec.
+type WalkFunc func(spec Spec) bool
+
+// Walk calls the given function for the given spec and then, depth-first,
+// for each of its descendents.
+//
+// The descendents include both the specs that are decoded from the same body
+// as their parent (as returned by SameBodyChildren) and the specs used to
+// decode the bodies of nested blocks (as returned by NestedSpec). The
+// children of an ObjectSpec are visited in lexical order by key, while the
+// children of all other specs are visited in their natural order.
+func Walk(spec Spec, fn WalkFunc) {
+	if !fn(spec) {
+		return
+	}
+	children := SameBodyChildren(spec)
+	for _, child := range children {
+		Walk(child, fn)
+	}
+	if nested := NestedSpec(spec); nested != nil {
+		for _, child := range children {
+			// Wrapper specs like DefaultSpec report the nested spec of the
+			// spec they wrap, which we've already visited above.
+			if childNested := NestedSpec(child); childNested != nil && sameSpec(childNested, nested) {
+				return
+			}
+		}
+		Walk(nested, fn)
+	}
+}
+
+// sameSpec returns true if the two given specs are the same spec, even if
+// they are of a type that cannot be compared with ==, such as ObjectSpec.
+func sameSpec(a, b Spec) bool {
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	if av.Type() != bv.Type() {
+		return false
+	}
+	switch av.Kind() {
+	case reflect.Map, reflect.Slice:
+		return av.Pointer() == bv.Pointer() && av.Len() == bv.Len()
+	}
+	return av.Type().Comparable() && a == b
+}
+
+// SameBodyChildren returns the specs nested directly inside the given spec
+// that are decoded from the same body as it, such as the attribute specs of
+// an ObjectSpec or the wrapped spec of a DefaultSpec.
+//
+// The result does not include the spec used to decode the bodies of nested
+// blocks, which can be obtained using NestedSpec.
+func SameBodyChildren(spec Spec) []Spec {
+	var ret []Spec
+	spec.visitSameBodyChildren(func(child Spec) {
+		ret = append(ret, child)
+	})
+	return ret
+}
+
+// NestedSpec returns the spec used to decode the bodies of the blocks that
+// the given spec requires, or nil if it does not decode block bodies using
+// a spec.
+func NestedSpec(spec Spec) Spec {
+	if bs, ok := spec.(blockSpec); ok {
+		nested := bs.nestedSpec()
+		if _, isNoop := nested.(noopSpec); isNoop {
+			// BlockAttrsSpec uses this placeholder, but it's an
+			// implementation detail that callers should not see.
+			return nil
+		}
+		return nested
+	}
+	return nil
+}
+
+// AttributeSchemata returns the schemata for the attributes that the given
+// spec itself requires from the body, not including those required by its
+// children.
+func AttributeSchemata(spec Spec) []hcl.AttributeSchema {
+	if as, ok := spec.(attrSpec); ok {
+		return as.attrSchemata()
+	}
+	return nil
+}
+
+// BlockHeaderSchemata returns the schemata for the blocks that the given
+// spec itself requires from the body, not including those required by its
+// children.
+func BlockHeaderSchemata(spec Spec) []hcl.BlockHeaderSchema {
+	if bs, ok := spec.(blockSpec); ok {
+		return bs.blockHeaderSchemata()
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package hcldec
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestWalk(t *testing.T) {
+	spec := ObjectSpec{
+		"name": &AttrSpec{
+			Name: "name",
+			Type: cty.String,
+		},
+		"count": &DefaultSpec{
+			Primary: &AttrSpec{
+				Name: "count",
+				Type: cty.Number,
+			},
+			Default: &LiteralSpec{
+				Value: cty.NumberIntVal(1),
+			},
+		},
+		"rules": &BlockListSpec{
+			TypeName: "rule",
+			Nested: ObjectSpec{
+				"port": &AttrSpec{
+					Name: "port",
+					Type: cty.Number,
+				},
+			},
+		},
+		"tags": &BlockAttrsSpec{
+			TypeName:    "tags",
+			ElementType: cty.String,
+		},
+	}
+
+	var got []string
+	Walk(spec, func(s Spec) bool {
+		switch s := s.(type) {
+		case *AttrSpec:
+			got = append(got, "attr "+s.Name)
+		case *BlockListSpec:
+			got = append(got, "block list "+s.TypeName)
+		case *BlockAttrsSpec:
+			got = append(got, "block attrs "+s.TypeName)
+		default:
+			got = append(got, fmt.Sprintf("%T", s))
+		}
+		return true
+	})
+
+	want := []string{
+		"hcldec.ObjectSpec",
+		"*hcldec.DefaultSpec",
+		"attr count",
+		"*hcldec.LiteralSpec",
+		"attr name",
+		"block list rule",
+		"hcldec.ObjectSpec",
+		"attr port",
+		"block attrs tags",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestWalkSkipChildren(t *testing.T) {
+	spec := ObjectSpec{
+		"rules": &BlockListSpec{
+			TypeName: "rule",
+			Nested: &AttrSpec{
+				Name: "port",
+				Type: cty.Number,
+			},
+		},
+	}
+
+	count := 0
+	Walk(spec, func(s Spec) bool {
+		count++
+		_, isBlock := s.(*BlockListSpec)
+		return !isBlock
+	})
+	if got, want := count, 2; got != want {
+		t.Errorf("visited %d specs; want %d", got, want)
+	}
+}
+
+func TestWalkNestedAndSameBody(t *testing.T) {
+	nested := ObjectSpec{
+		"port": &AttrSpec{
+			Name: "port",
+			Type: cty.Number,
+		},
+	}
+	spec := TupleSpec{
+		// This custom spec has both a same-body child and a nested spec,
+		// which must both be visited.
+		&CustomSpec{
+			Impl: &testBlockSpec{
+				testUpperSpec: testUpperSpec{Name: "name"},
+				Child: &AttrSpec{
+					Name: "name",
+					Type: cty.String,
+				},
+				Nested: nested,
+			},
+		},
+		// This wrapper reports the nested spec of the spec it wraps, which
+		// must be visited only once.
+		&DefaultSpec{
+			Primary: &BlockListSpec{
+				TypeName: "rule",
+				Nested:   nested,
+			},
+			Default: &LiteralSpec{
+				Value: cty.ListValEmpty(cty.EmptyObject),
+			},
+		},
+	}
+
+	var got []string
+	Walk(spec, func(s Spec) bool {
+		switch s := s.(type) {
+		case *AttrSpec:
+			got = append(got, "attr "+s.Name)
+		default:
+			got = append(got, fmt.Sprintf("%T", s))
+		}
+		return true
+	})
+
+	want := []string{
+		"hcldec.TupleSpec",
+		"*hcldec.CustomSpec",
+		"attr name",
+		"hcldec.ObjectSpec",
+		"attr port",
+		"*hcldec.DefaultSpec",
+		"*hcldec.BlockListSpec",
+		"hcldec.ObjectSpec",
+		"attr port",
+		"*hcldec.LiteralSpec",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
+
+// testBlockSpec is a CustomSpecImpl that requires both an attribute, which
+// it decodes using a same-body child, and blocks with a nested spec.
+type testBlockSpec struct {
+	testUpperSpec
+	Child  Spec
+	Nested Spec
+}
+
+func (s *testBlockSpec) SameBodyChildren() []Spec {
+	return []Spec{s.Child}
+}
+
+func (s *testBlockSpec) BlockHeaderSchemata() []hcl.BlockHeaderSchema {
+	return []hcl.BlockHeaderSchema{
+		{Type: "item"},
+	}
+}
+
+func (s *testBlockSpec) NestedSpec() Spec {
+	return s.Nested
+}
+
+// testUpperSpec is a CustomSpecImpl that decodes a string attribute and
+// converts it to uppercase.
+type testUpperSpec struct {
+	Name string
+}
+
+func (s *testUpperSpec) Decode(content *hcl.BodyContent, blockLabels []BlockLabel, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	attr, exists := content.Attributes[s.Name]
+	if !exists {
+		return cty.NullVal(cty.String), nil
+	}
+	val, diags := attr.Expr.Value(ctx)
+	if diags.HasErrors() || val.IsNull() || !val.IsKnown() {
+		return cty.UnknownVal(cty.String), diags
+	}
+	var buf []byte
+	for _, c := range []byte(val.AsString()) {
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		buf = append(buf, c)
+	}
+	return cty.StringVal(string(buf)), diags
+}
+
+func (s *testUpperSpec) ImpliedType() cty.Type {
+	return cty.String
+}
+
+func (s *testUpperSpec) SourceRange(content *hcl.BodyContent, blockLabels []BlockLabel) hcl.Range {
+	if attr, exists := content.Attributes[s.Name]; exists {
+		return attr.Expr.Range()
+	}
+	return content.MissingItemRange
+}
+
+func (s *testUpperSpec) SameBodyChildren() []Spec {
+	return nil
+}
+
+func (s *testUpperSpec) AttributeSchemata() []hcl.AttributeSchema {
+	return []hcl.AttributeSchema{
+		{Name: s.Name},
+	}
+}
+
+func (s *testUpperSpec) VariablesNeeded(content *hcl.BodyContent) []hcl.Traversal {
+	if attr, exists := content.Attributes[s.Name]; exists {
+		return attr.Expr.Variables()
+	}
+	return nil
+}
+
+func TestCustomSpec(t *testing.T) {
+	config := `
+name = "hello ${who}"
+`
+	f, diags := hclsyntax.ParseConfig([]byte(config), "", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+
+	spec := ObjectSpec{
+		"name": &CustomSpec{
+			Impl: &testUpperSpec{Name: "name"},
+		},
+	}
+
+	if got, want := ImpliedType(spec), cty.Object(map[string]cty.Type{"name": cty.String}); !got.Equals(want) {
+		t.Errorf("wrong implied type %#v; want %#v", got, want)
+	}
+
+	vars := Variables(f.Body, spec)
+	if len(vars) != 1 || vars[0].RootName() != "who" {
+		t.Errorf("wrong variables %#v", vars)
+	}
+
+	got, diags := Decode(f.Body, spec, &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"who": cty.StringVal("world"),
+		},
+	})
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+	want := cty.ObjectVal(map[string]cty.Value{
+		"name": cty.StringVal("HELLO WORLD"),
+	})
+	if !got.RawEquals(want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
@@ -0,0 +1,195 @@
+package hclparse
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// CacheValidation selects how a Cache decides whether a file it has cached
+// is still current.
+type CacheValidation int
+
+const (
+	// CacheByContent compares a hash of the file's content with the content
+	// that was cached. The file is read each time it is requested, but is
+	// parsed again only if its content has changed.
+	CacheByContent CacheValidation = iota
+
+	// CacheByModTime compares the file's size and modification time with
+	// those of the file that was cached, so that unchanged files need not
+	// even be read. This is faster than CacheByContent, but won't notice a
+	// change that preserves both the size and the modification time.
+	CacheByModTime
+)
+
+// Cache retains parsed files so that they can be returned again by any
+// parser created with NewParserWithCache, without parsing them again, for
+// as long as they have not changed.
+//
+// A cached file is returned along with the diagnostics that were produced
+// when it was originally parsed. The same *hcl.File object is shared by all
+// of the parsers that return it, so callers must not modify it.
+//
+// A Cache is safe for concurrent use by multiple goroutines.
+type Cache struct {
+	validation CacheValidation
+
+	mu      sync.Mutex
+	entries map[cacheKey]*cacheEntry
+}
+
+type cacheKey struct {
+	filename string
+	format   fileFormat
+}
+
+type cacheEntry struct {
+	hash  [sha256.Size]byte
+	file  *hcl.File
+	diags hcl.Diagnostics
+
+	// modTime and size are set only for entries read from files, whatever
+	// the validation method.
+	modTime time.Time
+	size    int64
+}
+
+// NewCache creates a new, empty cache that uses the given method to decide
+// whether cached files are still current.
+//
+// Files given to a parser as source buffers, rather than read by the parser
+// from disk, are always validated by content.
+func NewCache(validation CacheValidation) *Cache {
+	return &Cache{
+		validation: validation,
+		entries:    map[cacheKey]*cacheEntry{},
+	}
+}
+
+// Forget removes any cached data for the given filename, so that it will be
+// parsed again the next time it is requested.
+func (c *Cache) Forget(filename string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, cacheKey{filename, formatHCL})
+	delete(c.entries, cacheKey{filename, formatJSON})
+}
+
+// Retain removes the cached data for all files except those with the given
+// filenames, such as the files used by the most recent parser, so that a
+// long-lived cache does not keep files that are no longer needed.
+func (c *Cache) Retain(filenames []string) {
+	keep := make(map[string]bool, len(filenames))
+	for _, filename := range filenames {
+		keep[filename] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if !keep[key.filename] {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Stale returns those of the given filenames whose cached data is no longer
+// current, because the file has changed since it was read, judged by the
+// same method used to validate cached files when they are requested again.
+// Filenames that are not cached, or that were cached only from source
+// buffers, are not returned.
+//
+// This allows a caller that begins watching files only after parsing them
+// to notice changes made in between.
+func (c *Cache) Stale(filenames []string) []string {
+	var stale []string
+	for _, filename := range filenames {
+		for _, format := range []fileFormat{formatHCL, formatJSON} {
+			entry := c.get(cacheKey{filename, format})
+			if entry != nil && !c.current(filename, entry) {
+				stale = append(stale, filename)
+				break
+			}
+		}
+	}
+	return stale
+}
+
+// current returns true if the given entry still matches the named file.
+func (c *Cache) current(filename string, entry *cacheEntry) bool {
+	if entry.modTime.IsZero() {
+		// The entry was not read from a file.
+		return true
+	}
+	if c.validation == CacheByModTime {
+		info, err := os.Stat(filename)
+		return err == nil && entry.size == info.Size() && entry.modTime.Equal(info.ModTime())
+	}
+	src, err := ioutil.ReadFile(filename)
+	return err == nil && sha256.Sum256(src) == entry.hash
+}
+
+func (c *Cache) get(key cacheKey) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[key]
+}
+
+// parseFile reads and parses the given file, unless it is already cached.
+func (c *Cache) parseFile(filename string, format fileFormat) (*hcl.File, hcl.Diagnostics) {
+	// We record the file information even when validating by content, so
+	// that Stale can tell which entries were read from files.
+	info, err := os.Stat(filename)
+	if err != nil {
+		info = nil
+	} else if c.validation == CacheByModTime {
+		entry := c.get(cacheKey{filename, format})
+		if entry != nil && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+			return entry.file, entry.diags
+		}
+	}
+
+	src, diags := readFile(filename)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return c.parse(src, filename, format, info)
+}
+
+// parse parses the given source, unless the same source is already cached
+// for the given filename. If info is non-nil then it describes the file the
+// source was read from.
+func (c *Cache) parse(src []byte, filename string, format fileFormat, info os.FileInfo) (*hcl.File, hcl.Diagnostics) {
+	key := cacheKey{filename, format}
+	hash := sha256.Sum256(src)
+
+	entry := c.get(key)
+	if entry == nil || entry.hash != hash {
+		file, diags := parseSource(src, filename, format)
+		entry = &cacheEntry{
+			hash:  hash,
+			file:  file,
+			diags: diags,
+		}
+	} else {
+		// We'll update the file information below, so we mustn't modify
+		// the entry that other goroutines may be reading.
+		copied := *entry
+		entry = &copied
+	}
+	if info != nil {
+		entry.modTime = info.ModTime()
+		entry.size = info.Size()
+	}
+
+	c.mu.Lock()
+	c.entries[key] = entry
+	c.mu.Unlock()
+
+	return entry.file, entry.diags
+}
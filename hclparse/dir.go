@@ -0,0 +1,122 @@
+package hclparse
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// LoadDir parses all of the configuration files in the given directory and
+// returns a single body representing their combined content.
+//
+// Files whose names end in ".hcl" are parsed as native syntax and those whose
+// names end in ".hcl.json" are parsed as JSON. Subdirectories and files with
+// other names are ignored, as are files whose names start with "." or "#" or
+// end with "~", which are typically created by text editors.
+//
+// Files for which IsOverrideFile returns true are override files. All of the
+// other files, the primary files, are merged as with hcl.MergeFiles, and then
+// each override file is applied in lexical order of filename:
+//
+//   - A top-level attribute in an override file replaces the attribute of the
+//     same name from the primary files.
+//   - A top-level block in an override file is merged into each block from the
+//     primary files that has the same type and labels, and it is an error if
+//     there is no such block. Attributes inside the block replace those of the
+//     same name individually, while nested blocks replace all of the nested
+//     blocks of the same type.
+//
+// This is the same as merging the primary files and then each of the override
+// files using hcl.MergeBodiesWithOptions, with hcl.MergeBlocksOverride at the
+// top level and hcl.MergeBlocksReplaceType within blocks. The earlier
+// definitions of an attribute that an override file replaced are therefore
+// available from hcl.OverriddenAttributes.
+//
+// All of the attributes and blocks in the result retain the source ranges from
+// the files they were defined in, and all of the files are recorded in the
+// parser so that they are available for printing diagnostics.
+func (p *Parser) LoadDir(dir string) (hcl.Body, hcl.Diagnostics) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return hcl.EmptyBody(), hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Failed to read directory",
+				Detail:   fmt.Sprintf("The configuration directory %q could not be read.", dir),
+			},
+		}
+	}
+
+	var names []string
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || isIgnoredFile(name) {
+			continue
+		}
+		if strings.HasSuffix(name, ".hcl") || strings.HasSuffix(name, ".hcl.json") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = filepath.Join(dir, name)
+	}
+	files, diags := p.ParseFiles(paths)
+
+	var primary []*hcl.File
+	var overrides []hcl.Body
+	for i, file := range files {
+		if file == nil {
+			continue
+		}
+		if IsOverrideFile(names[i]) {
+			overrides = append(overrides, file.Body)
+		} else {
+			primary = append(primary, file)
+		}
+	}
+
+	if len(overrides) == 0 {
+		return hcl.MergeFiles(primary), diags
+	}
+	bodies := append([]hcl.Body{hcl.MergeFiles(primary)}, overrides...)
+	return hcl.MergeBodiesWithOptions(bodies, overrideMergeOptions), diags
+}
+
+// IsOverrideFile returns true if the configuration file of the given name
+// is an override file for the purposes of LoadDir: if it is named
+// "override.hcl" or "override.hcl.json", or if the name before the extension
+// ends with "_override".
+func IsOverrideFile(filename string) bool {
+	name := filepath.Base(filename)
+	switch {
+	case strings.HasSuffix(name, ".hcl.json"):
+		name = strings.TrimSuffix(name, ".hcl.json")
+	case strings.HasSuffix(name, ".hcl"):
+		name = strings.TrimSuffix(name, ".hcl")
+	default:
+		return false
+	}
+	return name == "override" || strings.HasSuffix(name, "_override")
+}
+
+func isIgnoredFile(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "#") || strings.HasSuffix(name, "~")
+}
+
+// overrideMergeOptions are the options for merging override files into the
+// primary files, as described in the LoadDir documentation.
+var overrideMergeOptions = hcl.MergeOptions{
+	Attributes: hcl.MergeAttributesOverride,
+	Blocks:     hcl.MergeBlocksOverride,
+	Nested: &hcl.MergeOptions{
+		Attributes: hcl.MergeAttributesOverride,
+		Blocks:     hcl.MergeBlocksReplaceType,
+	},
+}
@@ -0,0 +1,205 @@
+package hclparse
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestLoadDir(t *testing.T) {
+	files := map[string]string{
+		"main.hcl": `
+name = "app"
+port = 8080
+
+service "web" {
+  image    = "web:1"
+  replicas = 1
+
+  env {
+    name = "A"
+  }
+  env {
+    name = "B"
+  }
+}
+
+service "worker" {
+  image = "worker:1"
+}
+`,
+		"extra.hcl.json": `{"region": "eu"}`,
+		"override.hcl": `
+port = 443
+`,
+		"z_override.hcl.json": `{
+  "service": {
+    "web": {
+      "replicas": 3,
+      "env": {"name": "C"}
+    }
+  }
+}`,
+		"notes.txt":           `not = "config"`,
+		".hidden.hcl":         `broken {`,
+		"main.hcl~":           `broken {`,
+		"sub/nested.hcl":      `broken {`,
+		"sub/nested_override": `broken {`,
+	}
+	dir := t.TempDir()
+	for name, src := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := NewParser()
+	body, diags := p.LoadDir(dir)
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %s", diags.Error())
+	}
+	if got, want := len(p.Files()), 4; got != want {
+		t.Errorf("parser has %d files; want %d", got, want)
+	}
+
+	content, diags := body.Content(&hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{
+			{Name: "name", Required: true},
+			{Name: "port"},
+			{Name: "region"},
+		},
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "service", LabelNames: []string{"name"}},
+		},
+	})
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %s", diags.Error())
+	}
+
+	gotAttrs := map[string]cty.Value{}
+	for name, attr := range content.Attributes {
+		gotAttrs[name], _ = attr.Expr.Value(nil)
+	}
+	wantAttrs := map[string]cty.Value{
+		"name":   cty.StringVal("app"),
+		"port":   cty.NumberIntVal(443),
+		"region": cty.StringVal("eu"),
+	}
+	if len(gotAttrs) != len(wantAttrs) {
+		t.Errorf("wrong attributes\ngot:  %#v\nwant: %#v", gotAttrs, wantAttrs)
+	}
+	for name, want := range wantAttrs {
+		if got := gotAttrs[name]; !want.RawEquals(got) {
+			t.Errorf("wrong value for %s %#v; want %#v", name, got, want)
+		}
+	}
+	if got, want := content.Attributes["port"].Range.Filename, filepath.Join(dir, "override.hcl"); got != want {
+		t.Errorf("port is from %s; want %s", got, want)
+	}
+	if overridden := hcl.OverriddenAttributes(content.Attributes["port"]); len(overridden) != 1 || overridden[0].Range.Filename != filepath.Join(dir, "main.hcl") {
+		t.Errorf("wrong overridden attributes for port %#v", overridden)
+	}
+
+	if got, want := len(content.Blocks), 2; got != want {
+		t.Fatalf("got %d blocks; want %d", got, want)
+	}
+	web := content.Blocks[0]
+	if got, want := web.DefRange.Filename, filepath.Join(dir, "main.hcl"); got != want {
+		t.Errorf("web block is from %s; want %s", got, want)
+	}
+	webContent, diags := web.Body.Content(&hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{
+			{Name: "image"},
+			{Name: "replicas"},
+		},
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "env"},
+		},
+	})
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %s", diags.Error())
+	}
+	image, _ := webContent.Attributes["image"].Expr.Value(nil)
+	replicas, _ := webContent.Attributes["replicas"].Expr.Value(nil)
+	if !image.RawEquals(cty.StringVal("web:1")) || !replicas.RawEquals(cty.NumberIntVal(3)) {
+		t.Errorf("wrong web attributes: image = %#v, replicas = %#v", image, replicas)
+	}
+	if got, want := len(webContent.Blocks), 1; got != want {
+		t.Fatalf("got %d env blocks; want %d", got, want)
+	}
+	envAttrs, _ := webContent.Blocks[0].Body.JustAttributes()
+	envName, _ := envAttrs["name"].Expr.Value(nil)
+	if !envName.RawEquals(cty.StringVal("C")) {
+		t.Errorf("wrong env name %#v; want %#v", envName, cty.StringVal("C"))
+	}
+}
+
+func TestLoadDirMissingBaseBlock(t *testing.T) {
+	files := map[string]string{
+		"main.hcl": `
+service "web" {
+}
+`,
+		"main_override.hcl": `
+service "wbe" {
+}
+`,
+	}
+	dir := t.TempDir()
+	for name, src := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	body, diags := NewParser().LoadDir(dir)
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %s", diags.Error())
+	}
+	_, diags = body.Content(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "service", LabelNames: []string{"name"}},
+		},
+	})
+	if len(diags) != 1 {
+		t.Fatalf("got %d diagnostics; want 1: %s", len(diags), diags.Error())
+	}
+	if got, want := diags[0].Summary, "Missing base block"; got != want {
+		t.Errorf("wrong summary %q; want %q", got, want)
+	}
+	if got, want := diags[0].Subject.Filename, filepath.Join(dir, "main_override.hcl"); got != want {
+		t.Errorf("wrong subject filename %q; want %q", got, want)
+	}
+}
+
+func TestIsOverrideFile(t *testing.T) {
+	tests := map[string]bool{
+		"override.hcl":           true,
+		"override.hcl.json":      true,
+		"foo_override.hcl":       true,
+		"dir/foo_override.hcl":   true,
+		"foo_override.hcl.json":  true,
+		"foo.hcl":                false,
+		"overrides.hcl":          false,
+		"foo_override.json":      false,
+		"myoverride.hcl":         false,
+		"foo_override.hcl.extra": false,
+	}
+	for name, want := range tests {
+		if got := IsOverrideFile(name); got != want {
+			t.Errorf("IsOverrideFile(%q) = %v; want %v", name, got, want)
+		}
+	}
+}
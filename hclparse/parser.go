@@ -3,6 +3,9 @@ package hclparse
 import (
 	"fmt"
 	"io/ioutil"
+	"runtime"
+	"strings"
+	"sync"
 
 	"github.com/hashicorp/hcl2/hcl"
 	"github.com/hashicorp/hcl2/hcl/hclsyntax"
@@ -23,8 +26,14 @@ import (
 // call to parse that file. Callers are expected to collect up diagnostics
 // and present them together, so returning diagnostics for the same file
 // multiple times would create a confusing result.
+//
+// A Parser is safe for concurrent use by multiple goroutines, with the
+// exception of the map returned by Files.
 type Parser struct {
-	files map[string]*hcl.File
+	mu          sync.Mutex
+	files       map[string]*hcl.File
+	cache       *Cache
+	concurrency int
 }
 
 // NewParser creates a new parser, ready to parse configuration files.
@@ -34,69 +43,108 @@ func NewParser() *Parser {
 	}
 }
 
+// NewParserWithCache creates a new parser that consults the given cache
+// before parsing any file, and records the files it parses in the cache.
+//
+// A cache may be shared between any number of parsers, so that an
+// application that creates a new parser each time it reloads its
+// configuration can avoid re-parsing files that have not changed.
+func NewParserWithCache(cache *Cache) *Parser {
+	p := NewParser()
+	p.cache = cache
+	return p
+}
+
+// SetConcurrency sets the maximum number of files that ParseFiles will parse
+// at once. If n is zero or less, the value of runtime.GOMAXPROCS is used,
+// which is also the default.
+func (p *Parser) SetConcurrency(n int) {
+	p.mu.Lock()
+	p.concurrency = n
+	p.mu.Unlock()
+}
+
 // ParseHCL parses the given buffer (which is assumed to have been loaded from
 // the given filename) as a native-syntax configuration file and returns the
 // hcl.File object representing it.
 func (p *Parser) ParseHCL(src []byte, filename string) (*hcl.File, hcl.Diagnostics) {
-	if existing := p.files[filename]; existing != nil {
-		return existing, nil
-	}
-
-	file, diags := hclsyntax.ParseConfig(src, filename, hcl.Pos{Byte: 0, Line: 1, Column: 1})
-	p.files[filename] = file
-	return file, diags
+	return p.parse(src, filename, formatHCL)
 }
 
 // ParseHCLFile reads the given filename and parses it as a native-syntax HCL
 // configuration file. An error diagnostic is returned if the given file
 // cannot be read.
 func (p *Parser) ParseHCLFile(filename string) (*hcl.File, hcl.Diagnostics) {
-	if existing := p.files[filename]; existing != nil {
-		return existing, nil
-	}
-
-	src, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return nil, hcl.Diagnostics{
-			{
-				Severity: hcl.DiagError,
-				Summary:  "Failed to read file",
-				Detail:   fmt.Sprintf("The configuration file %q could not be read.", filename),
-			},
-		}
-	}
-
-	return p.ParseHCL(src, filename)
+	return p.parseFile(filename, formatHCL)
 }
 
 // ParseJSON parses the given JSON buffer (which is assumed to have been loaded
 // from the given filename) and returns the hcl.File object representing it.
 func (p *Parser) ParseJSON(src []byte, filename string) (*hcl.File, hcl.Diagnostics) {
-	if existing := p.files[filename]; existing != nil {
-		return existing, nil
-	}
-
-	file, diags := json.Parse(src, filename)
-	p.files[filename] = file
-	return file, diags
+	return p.parse(src, filename, formatJSON)
 }
 
 // ParseJSONFile reads the given filename and parses it as JSON, similarly to
 // ParseJSON. An error diagnostic is returned if the given file cannot be read.
 func (p *Parser) ParseJSONFile(filename string) (*hcl.File, hcl.Diagnostics) {
-	if existing := p.files[filename]; existing != nil {
-		return existing, nil
+	return p.parseFile(filename, formatJSON)
+}
+
+// ParseFiles reads and parses all of the given files, parsing several of them
+// at once as permitted by SetConcurrency. Files whose names end in ".json"
+// are parsed as JSON, and all others as native syntax.
+//
+// The result contains the file for each of the given paths in the same
+// order, with nil for any that could not be read. The diagnostics for all of
+// the files are returned together, also in the order of the given paths.
+func (p *Parser) ParseFiles(paths []string) ([]*hcl.File, hcl.Diagnostics) {
+	files := make([]*hcl.File, len(paths))
+	fileDiags := make([]hcl.Diagnostics, len(paths))
+
+	p.mu.Lock()
+	workers := p.concurrency
+	p.mu.Unlock()
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(paths) {
+		workers = len(paths)
 	}
 
-	file, diags := json.ParseFile(filename)
-	p.files[filename] = file
-	return file, diags
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				format := formatHCL
+				if strings.HasSuffix(paths[i], ".json") {
+					format = formatJSON
+				}
+				files[i], fileDiags[i] = p.parseFile(paths[i], format)
+			}
+		}()
+	}
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var diags hcl.Diagnostics
+	for _, d := range fileDiags {
+		diags = append(diags, d...)
+	}
+	return files, diags
 }
 
 // AddFile allows a caller to record in a parser a file that was parsed some
 // other way, thus allowing it to be included in the registry of sources.
 func (p *Parser) AddFile(filename string, file *hcl.File) {
+	p.mu.Lock()
 	p.files[filename] = file
+	p.mu.Unlock()
 }
 
 // Sources returns a map from filenames to the raw source code that was
@@ -105,6 +153,9 @@ func (p *Parser) AddFile(filename string, file *hcl.File) {
 //
 // The arrays underlying the returned slices should not be modified.
 func (p *Parser) Sources() map[string][]byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	ret := make(map[string][]byte)
 	for fn, f := range p.files {
 		ret[fn] = f.Bytes
@@ -116,8 +167,98 @@ func (p *Parser) Sources() map[string][]byte {
 // This is intended to be used, for example, to print diagnostics with
 // contextual information.
 //
-// The returned map and all of the objects it refers to directly or indirectly
-// must not be modified.
+// The returned map is the parser's own registry, which is updated as further
+// files are parsed, so it may be retrieved before parsing begins. For that
+// reason it must not be read while other goroutines may be parsing with the
+// same parser, and it must not be modified.
 func (p *Parser) Files() map[string]*hcl.File {
 	return p.files
 }
+
+type fileFormat int
+
+const (
+	formatHCL fileFormat = iota
+	formatJSON
+)
+
+func (p *Parser) existing(filename string) *hcl.File {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.files[filename]
+}
+
+// register records the given file in the registry, unless another file of
+// the same name was registered while it was being parsed, in which case
+// that one is returned instead so that all callers see the same object.
+func (p *Parser) register(filename string, file *hcl.File, diags hcl.Diagnostics) (*hcl.File, hcl.Diagnostics) {
+	if file == nil {
+		return nil, diags
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if existing := p.files[filename]; existing != nil {
+		return existing, nil
+	}
+	p.files[filename] = file
+	return file, diags
+}
+
+func (p *Parser) parse(src []byte, filename string, format fileFormat) (*hcl.File, hcl.Diagnostics) {
+	if existing := p.existing(filename); existing != nil {
+		return existing, nil
+	}
+
+	var file *hcl.File
+	var diags hcl.Diagnostics
+	if p.cache != nil {
+		file, diags = p.cache.parse(src, filename, format, nil)
+	} else {
+		file, diags = parseSource(src, filename, format)
+	}
+	return p.register(filename, file, diags)
+}
+
+func (p *Parser) parseFile(filename string, format fileFormat) (*hcl.File, hcl.Diagnostics) {
+	if existing := p.existing(filename); existing != nil {
+		return existing, nil
+	}
+
+	var file *hcl.File
+	var diags hcl.Diagnostics
+	if p.cache != nil {
+		file, diags = p.cache.parseFile(filename, format)
+	} else {
+		var src []byte
+		src, diags = readFile(filename)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		file, diags = parseSource(src, filename, format)
+	}
+	return p.register(filename, file, diags)
+}
+
+func parseSource(src []byte, filename string, format fileFormat) (*hcl.File, hcl.Diagnostics) {
+	switch format {
+	case formatJSON:
+		return json.Parse(src, filename)
+	default:
+		return hclsyntax.ParseConfig(src, filename, hcl.Pos{Byte: 0, Line: 1, Column: 1})
+	}
+}
+
+func readFile(filename string) ([]byte, hcl.Diagnostics) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Failed to read file",
+				Detail:   fmt.Sprintf("The configuration file %q could not be read.", filename),
+			},
+		}
+	}
+	return src, nil
+}
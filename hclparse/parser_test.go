@@ -0,0 +1,234 @@
+package hclparse
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+func TestParserConcurrent(t *testing.T) {
+	p := NewParser()
+
+	var wg sync.WaitGroup
+	results := make([]*hcl.File, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			filename := fmt.Sprintf("f%d.hcl", i%10)
+			results[i], _ = p.ParseHCL([]byte(fmt.Sprintf("a = %d\n", i%10)), filename)
+			p.Sources()
+		}(i)
+	}
+	wg.Wait()
+
+	if got, want := len(p.Files()), 10; got != want {
+		t.Errorf("parser has %d files; want %d", got, want)
+	}
+	for i, file := range results {
+		filename := fmt.Sprintf("f%d.hcl", i%10)
+		if got := p.Files()[filename]; got != file {
+			t.Errorf("registry has a different file for %s", filename)
+		}
+	}
+}
+
+func TestParserFilesLive(t *testing.T) {
+	// Callers commonly retrieve the files map before parsing anything, to
+	// give to a diagnostic writer, so it must see files parsed later.
+	p := NewParser()
+	files := p.Files()
+	file, _ := p.ParseHCL([]byte("a = 1\n"), "a.hcl")
+	if got := files["a.hcl"]; got != file {
+		t.Errorf("files map does not include the parsed file")
+	}
+}
+
+func TestParseFiles(t *testing.T) {
+	files := map[string]string{}
+	var paths []string
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("f%02d.hcl", i)
+		if i%2 == 1 {
+			name += ".json"
+			files[name] = fmt.Sprintf(`{"a": %d}`, i)
+		} else {
+			files[name] = fmt.Sprintf("a = %d\n", i)
+		}
+		paths = append(paths, name)
+	}
+	files["broken.hcl"] = "a = \n"
+	dir := t.TempDir()
+	for name, src := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, name := range paths {
+		paths[i] = filepath.Join(dir, name)
+	}
+	paths = append(paths, filepath.Join(dir, "missing.hcl"), filepath.Join(dir, "broken.hcl"))
+
+	p := NewParser()
+	p.SetConcurrency(3)
+	got, diags := p.ParseFiles(paths)
+	if len(got) != len(paths) {
+		t.Fatalf("got %d files; want %d", len(got), len(paths))
+	}
+	for i := 0; i < 20; i++ {
+		if got[i] == nil {
+			t.Errorf("file %d is nil", i)
+			continue
+		}
+		attrs, _ := got[i].Body.JustAttributes()
+		v, _ := attrs["a"].Expr.Value(nil)
+		if n, _ := v.AsBigFloat().Int64(); n != int64(i) {
+			t.Errorf("file %d has a = %d", i, n)
+		}
+	}
+	if got[20] != nil {
+		t.Errorf("missing file is not nil")
+	}
+
+	if len(diags) != 2 {
+		t.Fatalf("got %d diagnostics; want 2: %s", len(diags), diags.Error())
+	}
+	if got, want := diags[0].Summary, "Failed to read file"; got != want {
+		t.Errorf("wrong first diagnostic %q; want %q", got, want)
+	}
+	if got, want := diags[1].Subject.Filename, paths[21]; got != want {
+		t.Errorf("second diagnostic is for %s; want %s", got, want)
+	}
+}
+
+func TestCache(t *testing.T) {
+	validations := map[string]CacheValidation{
+		"content":  CacheByContent,
+		"mod time": CacheByModTime,
+	}
+	for name, validation := range validations {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "a.hcl")
+			if err := ioutil.WriteFile(filename, []byte("a = 1\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cache := NewCache(validation)
+			first, _ := NewParserWithCache(cache).ParseHCLFile(filename)
+			second, _ := NewParserWithCache(cache).ParseHCLFile(filename)
+			if first != second {
+				t.Errorf("unchanged file was parsed again")
+			}
+
+			// Change the content and the modification time.
+			if err := ioutil.WriteFile(filename, []byte("a = 22\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			later := time.Now().Add(time.Minute)
+			if err := os.Chtimes(filename, later, later); err != nil {
+				t.Fatal(err)
+			}
+			third, _ := NewParserWithCache(cache).ParseHCLFile(filename)
+			if third == second {
+				t.Errorf("changed file was not parsed again")
+			}
+
+			// Source buffers are always validated by content.
+			fourth, _ := NewParserWithCache(cache).ParseHCL([]byte("a = 22\n"), filename)
+			if fourth != third {
+				t.Errorf("same source was parsed again")
+			}
+
+			cache.Forget(filename)
+			fifth, _ := NewParserWithCache(cache).ParseHCLFile(filename)
+			if fifth == third {
+				t.Errorf("forgotten file was not parsed again")
+			}
+		})
+	}
+}
+
+func TestCacheStale(t *testing.T) {
+	validations := map[string]CacheValidation{
+		"content":  CacheByContent,
+		"mod time": CacheByModTime,
+	}
+	for name, validation := range validations {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			a := filepath.Join(dir, "a.hcl")
+			b := filepath.Join(dir, "b.hcl")
+			for _, filename := range []string{a, b} {
+				if err := ioutil.WriteFile(filename, []byte("a = 1\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cache := NewCache(validation)
+			p := NewParserWithCache(cache)
+			p.ParseHCLFile(a)
+			p.ParseHCLFile(b)
+			p.ParseHCL([]byte("a = 1\n"), "buffer.hcl")
+			if got := cache.Stale([]string{a, b, "buffer.hcl", "uncached.hcl"}); len(got) != 0 {
+				t.Errorf("unchanged files reported as stale: %q", got)
+			}
+
+			if err := ioutil.WriteFile(b, []byte("a = 22\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			later := time.Now().Add(time.Minute)
+			if err := os.Chtimes(b, later, later); err != nil {
+				t.Fatal(err)
+			}
+			if got := cache.Stale([]string{a, b}); len(got) != 1 || got[0] != b {
+				t.Errorf("wrong stale files %q; want %q", got, []string{b})
+			}
+		})
+	}
+}
+
+func TestCacheRetain(t *testing.T) {
+	cache := NewCache(CacheByContent)
+	p := NewParserWithCache(cache)
+	a, _ := p.ParseHCL([]byte("a = 1\n"), "a.hcl")
+	b, _ := p.ParseHCL([]byte("b = 1\n"), "b.hcl")
+
+	cache.Retain([]string{"a.hcl"})
+	p = NewParserWithCache(cache)
+	if got, _ := p.ParseHCL([]byte("a = 1\n"), "a.hcl"); got != a {
+		t.Errorf("retained file was parsed again")
+	}
+	if got, _ := p.ParseHCL([]byte("b = 1\n"), "b.hcl"); got == b {
+		t.Errorf("file that was not retained was not parsed again")
+	}
+}
+
+func TestCacheDiagnostics(t *testing.T) {
+	cache := NewCache(CacheByContent)
+	src := []byte("a = \n")
+
+	p := NewParserWithCache(cache)
+	_, diags := p.ParseHCL(src, "a.hcl")
+	if !diags.HasErrors() {
+		t.Fatalf("no errors for invalid source")
+	}
+	_, diags = p.ParseHCL(src, "a.hcl")
+	if len(diags) != 0 {
+		t.Errorf("diagnostics returned twice by the same parser")
+	}
+	_, diags = NewParserWithCache(cache).ParseHCL(src, "a.hcl")
+	if !diags.HasErrors() {
+		t.Errorf("cached diagnostics not returned by a new parser")
+	}
+}
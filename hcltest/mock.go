@@ -180,6 +180,15 @@ func (e mockExprVariable) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostic
 		if val, ok := ctx.Variables[name]; ok {
 			return val, nil
 		}
+		if ctx.VariableResolver != nil {
+			val, exists, diags := ctx.VariableResolver.ResolveVariable(e.Variables()[0])
+			if diags.HasErrors() {
+				return cty.DynamicVal, diags
+			}
+			if exists {
+				return val, diags
+			}
+		}
 		ctx = ctx.Parent()
 	}
 
@@ -46,3 +46,10 @@ func (a *Attribute) init(name string, expr *Expression) {
 func (a *Attribute) Expr() *Expression {
 	return a.expr.content.(*Expression)
 }
+
+// SetLeadComment replaces any comments on the lines before the attribute with
+// the given text, written as one "#" comment per line. An empty string
+// removes the comments.
+func (a *Attribute) SetLeadComment(text string) {
+	a.leadComments = a.leadComments.ReplaceWith(newComments(commentTokens(text)))
+}
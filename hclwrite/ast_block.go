@@ -1,6 +1,7 @@
 package hclwrite
 
 import (
+	"github.com/hashicorp/hcl2/hcl"
 	"github.com/hashicorp/hcl2/hcl/hclsyntax"
 	"github.com/zclconf/go-cty/cty"
 )
@@ -72,3 +73,47 @@ func (b *Block) init(typeName string, labels []string) {
 func (b *Block) Body() *Body {
 	return b.body.content.(*Body)
 }
+
+// Type returns the type name of the block.
+func (b *Block) Type() string {
+	return string(b.typeName.content.(*identifier).token.Bytes)
+}
+
+// Labels returns the values of the labels of the block, in order.
+func (b *Block) Labels() []string {
+	var ret []string
+	for n := b.children.first; n != nil; n = n.after {
+		if _, isLabel := b.labels[n]; !isLabel {
+			continue
+		}
+		ret = append(ret, labelValue(n.content.(*quoted).tokens))
+	}
+	return ret
+}
+
+// SetLeadComment replaces any comments on the lines before the block with
+// the given text, written as one "#" comment per line. An empty string
+// removes the comments.
+func (b *Block) SetLeadComment(text string) {
+	b.leadComments = b.leadComments.ReplaceWith(newComments(commentTokens(text)))
+}
+
+// labelValue returns the string value of the given quoted label tokens.
+func labelValue(tokens Tokens) string {
+	var src []byte
+	for _, tok := range tokens {
+		if tok.Type == hclsyntax.TokenQuotedLit {
+			src = append(src, tok.Bytes...)
+		}
+	}
+	quoted := append(append([]byte{'"'}, src...), '"')
+	expr, diags := hclsyntax.ParseExpression(quoted, "", hcl.Pos{Line: 1, Column: 1})
+	if !diags.HasErrors() {
+		if val, diags := expr.Value(nil); !diags.HasErrors() && val.Type() == cty.String && val.IsKnown() && !val.IsNull() {
+			return val.AsString()
+		}
+	}
+	// Should never happen for a valid label, so we'll just return the
+	// raw source.
+	return string(src)
+}
@@ -35,6 +35,7 @@ func (b *Body) appendItemNode(nn *node) *node {
 // Clear removes all of the items from the body, making it empty.
 func (b *Body) Clear() {
 	b.children.Clear()
+	b.items = newNodeSet()
 }
 
 func (b *Body) AppendUnstructuredTokens(ts Tokens) {
@@ -55,10 +56,14 @@ func (b *Body) Attributes() map[string]*Attribute {
 	return ret
 }
 
-// Blocks returns a new slice of all the blocks in the body.
+// Blocks returns a new slice of all the blocks in the body, in the order
+// they appear.
 func (b *Body) Blocks() []*Block {
 	ret := make([]*Block, 0, len(b.items))
-	for n := range b.items {
+	for n := b.children.first; n != nil; n = n.after {
+		if _, isItem := b.items[n]; !isItem {
+			continue
+		}
 		if block, isBlock := n.content.(*Block); isBlock {
 			ret = append(ret, block)
 		}
@@ -96,7 +101,7 @@ func (b *Body) SetAttributeValue(name string, val cty.Value) *Attribute {
 	if attr != nil {
 		attr.expr = attr.expr.ReplaceWith(expr)
 	} else {
-		attr := newAttribute()
+		attr = newAttribute()
 		attr.init(name, expr)
 		b.appendItem(attr)
 	}
@@ -117,13 +122,65 @@ func (b *Body) SetAttributeTraversal(name string, traversal hcl.Traversal) *Attr
 	if attr != nil {
 		attr.expr = attr.expr.ReplaceWith(expr)
 	} else {
-		attr := newAttribute()
+		attr = newAttribute()
 		attr.init(name, expr)
 		b.appendItem(attr)
 	}
 	return attr
 }
 
+// RemoveAttribute removes the attribute of the given name from the body,
+// along with its comments, and returns it. The result is nil if there is no
+// such attribute.
+func (b *Body) RemoveAttribute(name string) *Attribute {
+	attr := b.GetAttribute(name)
+	if attr == nil {
+		return nil
+	}
+	b.removeItem(attr)
+	return attr
+}
+
+// RemoveBlock removes the given block from the body, along with its
+// comments. The result is false if the block does not belong to the body.
+func (b *Body) RemoveBlock(block *Block) bool {
+	return b.removeItem(block)
+}
+
+func (b *Body) removeItem(c nodeContent) bool {
+	for n := range b.items {
+		if n.content == c {
+			// If the item was separated from its neighbors by blank lines
+			// then we remove one of them too, so that the removal doesn't
+			// leave a double gap behind.
+			if isBlankLines(n.before) && (n.after == nil || isBlankLines(n.after)) {
+				n.before.Detach()
+			}
+			n.Detach()
+			delete(b.items, n)
+			return true
+		}
+	}
+	return false
+}
+
+// isBlankLines returns true if the given node consists only of newlines.
+func isBlankLines(n *node) bool {
+	if n == nil {
+		return false
+	}
+	ts, isTokens := n.content.(Tokens)
+	if !isTokens || len(ts) == 0 {
+		return false
+	}
+	for _, t := range ts {
+		if t.Type != hclsyntax.TokenNewline {
+			return false
+		}
+	}
+	return true
+}
+
 // AppendBlock appends an existing block (which must not be already attached
 // to a body) to the end of the receiving body.
 func (b *Body) AppendBlock(block *Block) *Block {
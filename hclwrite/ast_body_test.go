@@ -882,3 +882,141 @@ func TestBodyAppendBlock(t *testing.T) {
 		})
 	}
 }
+
+func TestBodyRemoveAttribute(t *testing.T) {
+	tests := []struct {
+		src  string
+		name string
+		want string
+	}{
+		{
+			"a = 1\n",
+			"b",
+			"a = 1\n",
+		},
+		{
+			"a = 1\nb = 2\n",
+			"a",
+			"b = 2\n",
+		},
+		{
+			"a = 1\n# about b\nb = 2 # trailing\nc = 3\n",
+			"b",
+			"a = 1\nc = 3\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s in %s", test.name, test.src), func(t *testing.T) {
+			f, diags := ParseConfig([]byte(test.src), "", hcl.Pos{Line: 1, Column: 1})
+			if len(diags) != 0 {
+				for _, diag := range diags {
+					t.Logf("- %s", diag.Error())
+				}
+				t.Fatalf("unexpected diagnostics")
+			}
+
+			f.Body().RemoveAttribute(test.name)
+			if got := string(f.Bytes()); got != test.want {
+				t.Errorf("wrong result\ngot:  %q\nwant: %q", got, test.want)
+			}
+			if f.Body().GetAttribute(test.name) != nil {
+				t.Errorf("attribute %q still present", test.name)
+			}
+		})
+	}
+}
+
+func TestBodyRemoveBlock(t *testing.T) {
+	src := "a = 1\n\n# first\nfoo \"x\" {\n  b = 2\n}\nfoo \"y\" {\n}\n"
+	f, diags := ParseConfig([]byte(src), "", hcl.Pos{Line: 1, Column: 1})
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %s", diags.Error())
+	}
+	body := f.Body()
+
+	blocks := body.Blocks()
+	if len(blocks) != 2 {
+		t.Fatalf("wrong number of blocks %d; want 2", len(blocks))
+	}
+	if got, want := blocks[0].Labels(), []string{"x"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrong first block labels %#v; want %#v", got, want)
+	}
+
+	if !body.RemoveBlock(blocks[0]) {
+		t.Fatalf("RemoveBlock returned false for a block in the body")
+	}
+	if body.RemoveBlock(blocks[0]) {
+		t.Fatalf("RemoveBlock returned true for a block already removed")
+	}
+
+	got := string(f.Bytes())
+	want := "a = 1\n\nfoo \"y\" {\n}\n"
+	if got != want {
+		t.Errorf("wrong result\ngot:  %q\nwant: %q", got, want)
+	}
+	if got := len(body.Blocks()); got != 1 {
+		t.Errorf("wrong number of blocks %d after removal; want 1", got)
+	}
+
+	// Removing the last item also removes the blank line before it.
+	body.RemoveBlock(body.Blocks()[0])
+	got = string(f.Bytes())
+	want = "a = 1\n"
+	if got != want {
+		t.Errorf("wrong result after second removal\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestBlockTypeLabels(t *testing.T) {
+	src := "foo \"a\" \"b\\\"c\" {\n}\nbar {\n}\n"
+	f, diags := ParseConfig([]byte(src), "", hcl.Pos{Line: 1, Column: 1})
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %s", diags.Error())
+	}
+	f.Body().AppendNewBlock("baz", []string{"d${e}"})
+
+	type blockDesc struct {
+		Type   string
+		Labels []string
+	}
+	var got []blockDesc
+	for _, block := range f.Body().Blocks() {
+		got = append(got, blockDesc{block.Type(), block.Labels()})
+	}
+	want := []blockDesc{
+		{"foo", []string{"a", `b"c`}},
+		{"bar", nil},
+		{"baz", []string{"d${e}"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestSetLeadComment(t *testing.T) {
+	src := "# old\na = 1 # keep\n\nfoo {\n  b = 2\n}\n"
+	f, diags := ParseConfig([]byte(src), "", hcl.Pos{Line: 1, Column: 1})
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %s", diags.Error())
+	}
+	body := f.Body()
+
+	body.GetAttribute("a").SetLeadComment("new\ncomment")
+	body.Blocks()[0].SetLeadComment("block")
+	body.Blocks()[0].Body().GetAttribute("b").SetLeadComment("nested")
+	body.SetAttributeValue("c", cty.True).SetLeadComment("appended")
+
+	got := string(f.Bytes())
+	want := "# new\n# comment\na = 1 # keep\n\n# block\nfoo {\n  # nested\n  b = 2\n}\n# appended\nc = true\n"
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	body.GetAttribute("a").SetLeadComment("")
+	got = string(f.Bytes())
+	want = "a = 1 # keep\n\n# block\nfoo {\n  # nested\n  b = 2\n}\n# appended\nc = true\n"
+	if got != want {
+		t.Errorf("wrong result after removal\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
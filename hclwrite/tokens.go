@@ -3,6 +3,7 @@ package hclwrite
 import (
 	"bytes"
 	"io"
+	"strings"
 
 	"github.com/apparentlymart/go-textseg/textseg"
 	"github.com/hashicorp/hcl2/hcl"
@@ -120,3 +121,23 @@ func newIdentToken(name string) *Token {
 		Bytes: []byte(name),
 	}
 }
+
+// commentTokens returns tokens for a "#" comment on each line of the given
+// text, or no tokens at all if the text is empty.
+func commentTokens(text string) Tokens {
+	if text == "" {
+		return nil
+	}
+	var ret Tokens
+	for _, line := range strings.Split(text, "\n") {
+		src := "#"
+		if line != "" {
+			src += " " + line
+		}
+		ret = append(ret, &Token{
+			Type:  hclsyntax.TokenComment,
+			Bytes: []byte(src + "\n"),
+		})
+	}
+	return ret
+}
@@ -0,0 +1,23 @@
+// Package prose contains helpers for writing the English prose of diagnostic
+// messages, shared by the packages of this module.
+package prose
+
+import (
+	"fmt"
+	"strings"
+)
+
+// JoinAlternatives joins the given strings into a list in English prose,
+// with "or" before the last item.
+func JoinAlternatives(items []string) string {
+	switch len(items) {
+	case 0:
+		return ""
+	case 1:
+		return items[0]
+	case 2:
+		return items[0] + " or " + items[1]
+	default:
+		return fmt.Sprintf("%s, or %s", strings.Join(items[:len(items)-1], ", "), items[len(items)-1])
+	}
+}